@@ -0,0 +1,289 @@
+package main
+
+const etapInstructions = `
+We were unable to log into eTapestry. Please follow the instructions below to set up your eTapestry API key.
+
+If issues persist, please file bug.
+
+In order to connect to your eTapestry data, you'll need to generate (or use) an API key.
+
+Follow the instructions here to generate that API key:
+
+https://webfiles-sc1.blackbaud.com/support/howto/coveo/etapestry/etapapi.html
+
+Then, once you have it, replace the value of the /secrets/etapestry-api-key.txt file with it.
+
+Then, rerun this step.
+
+Details of the error:
+`
+
+const salesforceInstructions = `
+We were unable to log into salesforce. 
+
+In order to connect to your Salesforce data, you'll need to generate a security token, and enter your credentials into the right file.
+
+Follow these instructions:
+
+1 - Create a salesforce Sandbox (if you already have one, or if you are doing a final deployment to production, skip this step).
+    (Sandboxes are temporary copies of your configuration that allow you to experiment without worry. You can delete them without harming your data, and can create new ones at any time.)
+
+2 - Visit the sandbox and sign in (note you may have to have .sandboxname after your email)
+
+3 - Clicking your user in the upper right hand corner, then going to settings, create a new security token. Save this for next step (you'll have to do this once per sandbox you create.)
+
+4 - Enter your username, password, security token, and login URL in secrets/salesforce-sandbox-connection-config.txt
+
+5 - Rerun this step until you succeed. If you have any issues, please file a bug.
+
+`
+
+const exportAttachmentsInstructions = `
+
+You're likely getting this message because eTap authentication failed. 
+
+In order to retrieve all of your attachments/files, you'll need to authenticate to them as you would through a browser.
+You'll need to do this every ~2 hours that you spend downloading attachments, so you'll unfortunately have to do this frequently if you have many files.
+For this reason, if you find these instructions don't work well for you, make sure to document your own process for obtaining the authentication information.
+
+There are five values needed for authentication, each passed as a flag to 'etap2sf export-attachments'. They are:
+	- JSessionID (--jsessionid)
+	- UserDataSessionID (--user-data-session-id)
+	- AuthSVCToken (--auth-svc-token)
+	- SecurityToken (--security-token)
+	- MyEntityRoleRef (--entity-role-ref)
+Instructions for each is below. Once you have found them all, pass them as flags and rerun the command.
+
+To find the MyEntityRoleRef:
+1 - Navigate to the User entry for you in eTapestry.
+2 - In the network tab, the ID will be in the URL of the request to the user's page.
+3 - It will look something like 489.0.12345678
+
+To find JSessionID, UserDataSessionID, and AuthSVCToken:
+1 - Log into eTapestry in your browser as you normally would.
+2 - Navigate to a journal entry that has an attachment on it.
+3 - Open the Chrome Dev Tools (F12 or right click -> Inspect).
+4 - Go to the Cookies tab, and copy the three cookie's values into the corresponding flags.
+
+To find the SecurityToken:
+1 - On the same page as step 2 from the last set of instructions, open the javascript console in the developer tools.
+2 - Run this snippet to find the security token:  
+
+[...document.getElementsByTagName("input")].filter(i => i.name === "securityToken")[0].value
+
+Once you have all five of these values passed as flags, try to rerun this. If you're still having trouble, please file a bug. 
+
+`
+
+const modifyExistingSalesforceObjectsFmt = `
+
+These tasks unfortunately can't be automated, but this set of instructions should get you there quickly.
+
+First, make sure you adjust some NPSP settings to what you want them to be.
+
+1. Update the Opportunity:Donation stages to be what you want them to be.
+%s/lightning/setup/ObjectManager/Opportunity/FieldsAndRelationships/StageName/view
+
+2. Decide whether you want to have recurring donations auto-create installments or not. If you want to disable them, do so via
+NPSP Settings > Recurring Donations > Installment Opportunity Auto-Creation > Disable All Installments
+
+3. In order to allow activity tracking for eTapestry Contact + Note Types, you'll need to add them to the Task.Subject and Task.Type picklists in Salesforce.
+
+Open the following two URLs in your browser:
+
+%s
+
+%s
+
+On each, enter the following list:
+
+%s
+
+4. That is it! You can go to the next step once this is complete.
+
+`
+
+const apexClassForEditButton = `
+
+NOTE: IF YOU ARE DEPLOYING INTO PRODUCTION, YOU WILL NEED TO IMPORT THESE FROM A CHANGE SET INSTEAD.
+TO DO THAT, CREATE A CHANGE SET FROM THE SANDBOX THAT INCLUDES THE THREE COMPONENTS BELOW, 
+PUBLISH IT VIA OUTBOUND CHANGE SET, AND THEN IMPORT IT INTO PRODUCTION VIA INBOUND CHANGE SET.
+
+Salesforce doesn't allow API modification of APEX classes, so you'll need to create a class called EditButton manually.
+
+1. Create a new VisualForcePage for testing:
+
+Setup > Custom Code > Visualforce Pages > New
+
+Name: EditButtonTestComponent
+Code:
+
+<apex:page standardController="Account" extensions="EditButton">  
+    <p>
+        This tab includes data migrated automatically from eTapestry. Editing this data directly is not advised,
+        since these fields dont have integrations with other fields in Salesforce.
+    </p>
+    <apex:form id="etap-migration-form">
+        <apex:commandButton value="{!IF(isEditing, 'Cancel', 'Edit')}" action="{!toggleIsEditing}" rerender="etap-migration-form" />
+        <apex:commandButton value="Save" action="{!save}" rendered="{!isEditing}" rerender="etap-migration-form" />
+        <apex:pageBlock rendered="{!isEditing}">
+            <h1>You are not in editing mode</h1>
+        </apex:pageBlock>
+        <apex:pageBlock rendered="{!NOT(isEditing)}">
+            <h1>Note - you are not in editing mode, empty fields are omitted.</h1>
+        </apex:pageBlock>
+    </apex:form>
+</apex:page>
+
+
+2. Create the Edit Button Apex Class:
+
+Go to: Setup > Apex Classes > New, and paste the following code into the editor:
+
+public class EditButton {
+    public Boolean isEditing {get; set;}
+    public EditButton (ApexPages.StandardController controller) {
+        String modeStr = ApexPages.currentPage().getParameters().get('Mode');
+        if(modeStr == 'Edit') {
+            isEditing = true;
+        } else {
+            isEditing = false;
+        }
+    }
+    public void toggleIsEditing() {
+        isEditing = !isEditing;
+    }
+}
+
+3. Create another class called EditButtonTest (you'll need this when importing to production):
+
+@isTest
+private class EditButtonTest {
+    @isTest static void testConstructorEditMode() {
+        // Mock a page and set parameters
+        PageReference pageRef = Page.EditButtonTestComponent;
+        Test.setCurrentPage(pageRef);
+        ApexPages.currentPage().getParameters().put('Mode', 'Edit');
+
+        // Create a new controller instance
+        ApexPages.StandardController stdController = new ApexPages.StandardController(new Account()); // Replace 'YourObject' with the actual object
+        EditButton controller = new EditButton(stdController);
+
+        // Assert that isEditing is true
+        System.assertEquals(true, controller.isEditing, 'isEditing should be true when Mode is Edit');
+    }
+
+    @isTest static void testConstructorNonEditMode() {
+        PageReference pageRef = Page.EditButtonTestComponent;
+        Test.setCurrentPage(pageRef);
+
+        // Create a new controller instance
+        ApexPages.StandardController stdController = new ApexPages.StandardController(new Account()); // Replace 'YourObject' with the actual object
+        EditButton controller = new EditButton(stdController);
+
+        // Assert that isEditing is false
+        System.assertEquals(false, controller.isEditing, 'isEditing should be false when Mode is not Edit');
+    }
+
+    @isTest static void testToggleIsEditing() {
+        // Mock a page without parameters
+        PageReference pageRef = Page.EditButtonTestComponent;
+        Test.setCurrentPage(pageRef);
+
+        // Create a new controller instance
+        ApexPages.StandardController stdController = new ApexPages.StandardController(new Account()); // Replace 'YourObject' with the actual object
+        EditButton controller = new EditButton(stdController);
+
+        // Toggle isEditing and assert changes
+        Boolean initialEditMode = controller.isEditing;
+        controller.toggleIsEditing();
+        System.assertNotEquals(initialEditMode, controller.isEditing, 'isEditing should toggle its value');
+    }
+}
+
+Once you've created these Apex Classes, you can proceed to the next step.
+
+`
+
+const addVisualforceComponents = `
+
+MANUAL WORK NEEDED:
+The following can't be performed via the API, so you'll need to do each manually. Sorry!
+
+MANUAL INTERVETION 1: Adding Visualforce Pages to Layouts
+
+You'll need to manually add the following Visualforce 
+components to the following pages:
+
+- Account Soft Credit
+- ETap Additional Context
+- GAU Allocation
+- Partial Soft Credit
+- Task
+
+On each of these pages do the following:
+	0. Navigate to the Primary Page Layout
+	   Setup > Object Manager > [Name of Object Here] > Page Layouts > [Layout Name]
+	1. In the pallete at the top of the page, in the left hand side scroll down until you see "Visualforce Pages"
+	   Click this, there is a new visualforce page called "eTapestry Migrated Data [Name of Object Here]".
+	2. By dragging the "Section" button from the pallete, create a new section at the bottom of the page (typically below "Custom Links" or "System Information"). Select a 1 column layout and name the section "ETapesty" or something similar.
+	3. Drag the Visualforce Page from the pallete down into your newly created section.
+	4. Click the little wrench icon on the visualforce page you just added, set the height to 1000px, and check the "show scrollbars" box.
+	5. Press save (from the pallete), validating that the eTapestry Migrated Data component is at the bottom of the page.
+
+Do this for each page listed above, then go to the next task.
+
+
+MANUAL INTERVENTION 2: Add File Buttons
+NOTE: this is a different (but very similar) task over a DIFFERENT set of objects.
+
+- Account Soft Credit
+- ETap Additional Context
+- Partial Soft Credit
+- Payment
+- Task
+
+For each of these objects, do the following:
+	0. Navigate to the Primary Page Layout 
+		Setup > Object Manager > [Name of Object Here] > Page Layouts > [Layout Name]
+	1. In the pallete at the top of the page, in the left hand side scroll down until you see "Related Lists"
+	2. Drag the "Files" related list into the page layout.
+	3. Press Save. You will be promted to make this change for everyone, click "YES".
+
+MANUAL INTERVENTION 3: Create Content Version Page Layout
+
+0. Navigate to the Page Layouts page for Content Version 
+	Setup > Object Manager > Content Version > Page Layouts
+1. Click "New", give it a name like "Content Version Layout"
+2. Drag "ETap Additional Context" into the page layout somehwere.
+3. Save the page.
+
+MANUAL INTERVENTION 4: Add a Related List for Opportunities
+
+0. Navigate to the Page Layouts page for Opportunity
+	Setup > Object Manager > Opportunity > Page Layouts
+1. For EACH of the layouts on this page:
+2. Add an "Account Soft Credit" to the "related list" section at the bottom via drag and drop (in the pallete this is Related Lists > Account Soft Credits).
+3. Repeat for each layout.
+
+MANUAL INTERVENTION 5: Update the sort order for activity settings.
+
+0. Navigate to the Activity Settings page
+	Setup > Feature Settings > Sales > Activity Settings
+1. Uncheck "Sort past activities by the completed date", and press "Submit".
+
+Once you've done these manual interventions, you're done! You can now run the next step.
+`
+
+const allowUpdatesToCreatedDate = `MANUAL STEP NEEDED:
+
+Your LAST MANUAL STEP!!! YAY!
+This one matters a LOT THOUGH, so please do the entirety of this step very carefully.
+
+Read the full post, and only move on to the next step when you're on a Salesforce page showing that you have this permission.
+
+The real upload WILL FAIL if this is not done correctly.
+
+https://ongkrab.medium.com/salesforce-step-assign-value-createdbyid-fields-2f007469f5b4
+
+`
@@ -0,0 +1,357 @@
+// Command etap2sf runs each step of an eTapestry to Salesforce migration.
+//
+// Run `etap2sf next` to run the next step that hasn't been completed, or
+// `etap2sf status` to see where the migration is at.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Silicon-Ally/etap2sf/config"
+	"github.com/Silicon-Ally/etap2sf/salesforce/profile"
+	"github.com/Silicon-Ally/etap2sf/salesforce/upload"
+	"github.com/Silicon-Ally/etap2sf/salesforce/upload/diff_against_salesforce"
+	"github.com/Silicon-Ally/etap2sf/salesforce/upload/reconcile"
+	"github.com/Silicon-Ally/etap2sf/salesforce/upload/rollback"
+	"github.com/Silicon-Ally/etap2sf/salesforce/upload/upload_data_to_salesforce"
+	"github.com/Silicon-Ally/etap2sf/salesforce/upload/upload_errors"
+	"github.com/Silicon-Ally/etap2sf/utils"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(0)
+}
+
+func run(args []string) error {
+	global := flag.NewFlagSet("etap2sf", flag.ExitOnError)
+	profileName := global.String("profile", "", "the Salesforce profile (from the config) to use, instead of $"+profile.EnvVar+" or the default profile")
+	global.Usage = func() { fmt.Print(usage()) }
+	if err := global.Parse(args); err != nil {
+		return err
+	}
+	args = global.Args()
+	if len(args) == 0 {
+		fmt.Print(usage())
+		return fmt.Errorf("no command given")
+	}
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "help", "-h", "--help":
+		fmt.Print(usage())
+		return nil
+	}
+	if _, err := config.Load(); err != nil {
+		return err
+	}
+	if err := utils.CheckProjectRoot(); err != nil {
+		return err
+	}
+	if err := profile.Select(*profileName); err != nil {
+		return err
+	}
+	s, err := loadState()
+	if err != nil {
+		return fmt.Errorf("loading state: %w", err)
+	}
+
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	switch cmd {
+	case "status":
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		fmt.Print(status(s))
+		return nil
+	case "next":
+		addAttachmentFlags(fs)
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		st := s.next()
+		if st == nil {
+			fmt.Printf("All steps have been completed - the migration is done!\n")
+			return nil
+		}
+		return runStep(s, st)
+	case "run":
+		addAttachmentFlags(fs)
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		st, err := stepFromArgs(fs.Args())
+		if err != nil {
+			return err
+		}
+		return runStep(s, st)
+	case "done":
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		st, err := stepFromArgs(fs.Args())
+		if err != nil {
+			return err
+		}
+		if err := s.markDone(st); err != nil {
+			return fmt.Errorf("marking step done: %w", err)
+		}
+		fmt.Printf("Marked step %d (%s) as done.\n", st.number(), st.name)
+		return nil
+	case "reset":
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		st, err := stepFromArgs(fs.Args())
+		if err != nil {
+			return err
+		}
+		if err := s.reset(st); err != nil {
+			return fmt.Errorf("resetting step: %w", err)
+		}
+		fmt.Printf("Marked step %d (%s) as not done.\n", st.number(), st.name)
+		return nil
+	case "validate", "upload":
+		partial := fs.Bool("partial", false, "only use a sample of the data")
+		var filters *uploadFilterFlags
+		if cmd == "upload" {
+			filters = addUploadFilterFlags(fs)
+		}
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if filters.given() {
+			f, err := filters.parse()
+			if err != nil {
+				return err
+			}
+			// A selective upload doesn't complete the upload step.
+			return upload_data_to_salesforce.RunSelected(*partial, f)
+		}
+		name := cmd
+		if *partial {
+			name += "-partial"
+		}
+		st, err := lookupStep(name)
+		if err != nil {
+			return err
+		}
+		return runStep(s, st)
+	case "export":
+		delta := fs.Bool("delta", false, "only download what changed in eTapestry since the last export, and merge it into the existing export")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if *delta {
+			return exportDelta()
+		}
+		st, err := lookupStep(cmd)
+		if err != nil {
+			return err
+		}
+		return runStep(s, st)
+	case "diff":
+		partial := fs.Bool("partial", false, "only use a sample of the data")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		return diff_against_salesforce.Run(*partial)
+	case "rollback":
+		dryRun := fs.Bool("dry-run", false, "only report what would be deleted")
+		types := fs.String("types", "", "only roll back these object types, like Task,Opportunity")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		sots, err := upload.ParseObjectTypes(*types)
+		if err != nil {
+			return err
+		}
+		return rollback.Run(&rollback.Options{DryRun: *dryRun, ObjectTypes: sots})
+	case "upload-errors":
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		return upload_errors.Run()
+	case "reconcile":
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		return reconcile.Run()
+	case "export-attachments":
+		addAttachmentFlags(fs)
+	}
+
+	st, err := lookupStep(cmd)
+	if err != nil {
+		fmt.Print(usage())
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return runStep(s, st)
+}
+
+func runStep(s *state, st *step) error {
+	fmt.Printf("Step %d: %s\n", st.number(), st.description)
+	if st.isManual() {
+		msg, err := st.instructions()
+		if err != nil {
+			return fmt.Errorf("getting instructions for step %d (%s): %w", st.number(), st.name, err)
+		}
+		fmt.Print(msg)
+		fmt.Printf("\nThis step is manual. Once you've completed it, run `etap2sf done %s` to mark it as complete.\n", st.name)
+		return nil
+	}
+	if st.writes {
+		if err := profile.ConfirmWrite(strings.ToLower(st.description)); err != nil {
+			return err
+		}
+	}
+	if err := st.run(); err != nil {
+		return fmt.Errorf("running step %d (%s): %w", st.number(), st.name, err)
+	}
+	if err := s.markDone(st); err != nil {
+		return fmt.Errorf("marking step done: %w", err)
+	}
+	return nil
+}
+
+func stepFromArgs(args []string) (*step, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected exactly one step name or number, got %d", len(args))
+	}
+	return lookupStep(args[0])
+}
+
+func addAttachmentFlags(fs *flag.FlagSet) {
+	fs.StringVar(&attachmentsAuthn.JSessionID, "jsessionid", "", "the JSESSIONID cookie from a logged-in eTapestry browser session")
+	fs.StringVar(&attachmentsAuthn.UserDataSessionID, "user-data-session-id", "", "the UserDataSessionId cookie from a logged-in eTapestry browser session")
+	fs.StringVar(&attachmentsAuthn.AuthSVCToken, "auth-svc-token", "", "the AuthSvcToken cookie from a logged-in eTapestry browser session")
+	fs.StringVar(&attachmentsAuthn.SecurityToken, "security-token", "", "the securityToken from a journal entry page")
+	fs.StringVar(&attachmentsAuthn.MyEntityRoleRef, "entity-role-ref", "", "the entity role ref of your eTapestry user, like 489.0.12345678")
+}
+
+type uploadFilterFlags struct {
+	types, refs, from, to *string
+	hard                  *bool
+}
+
+func addUploadFilterFlags(fs *flag.FlagSet) *uploadFilterFlags {
+	return &uploadFilterFlags{
+		types: fs.String("types", "", "only upload these object types, like Opportunity,Payment"),
+		refs:  fs.String("refs", "", "only upload the records with the eTapestry refs in this file, one per line"),
+		from:  fs.String("from", "", "only upload records from journal entries dated on or after this date (YYYY-MM-DD)"),
+		to:    fs.String("to", "", "only upload records from journal entries dated on or before this date (YYYY-MM-DD)"),
+		hard:  fs.Bool("hard", false, "re-upload the selected records even if they've already been uploaded"),
+	}
+}
+
+func (f *uploadFilterFlags) given() bool {
+	return f != nil && (*f.types != "" || *f.refs != "" || *f.from != "" || *f.to != "" || *f.hard)
+}
+
+func (f *uploadFilterFlags) parse() (*upload_data_to_salesforce.Filters, error) {
+	if *f.types == "" && *f.refs == "" && *f.from == "" && *f.to == "" {
+		return nil, fmt.Errorf("--hard only applies to a selection - give --types, --refs, --from or --to too")
+	}
+	result := &upload_data_to_salesforce.Filters{}
+	result.Hard = *f.hard
+	sots, err := upload.ParseObjectTypes(*f.types)
+	if err != nil {
+		return nil, err
+	}
+	result.ObjectTypes = sots
+	if *f.refs != "" {
+		if result.Refs, err = upload.ReadRefsFile(*f.refs); err != nil {
+			return nil, err
+		}
+	}
+	for _, d := range []struct {
+		flag string
+		in   *string
+		out  *time.Time
+	}{{"from", f.from, &result.From}, {"to", f.to, &result.To}} {
+		if *d.in == "" {
+			continue
+		}
+		t, err := time.Parse("2006-01-02", *d.in)
+		if err != nil {
+			return nil, fmt.Errorf("parsing --%s: %w", d.flag, err)
+		}
+		*d.out = t
+	}
+	return result, nil
+}
+
+func status(s *state) string {
+	sb := strings.Builder{}
+	next := s.next()
+	for _, st := range allSteps {
+		mark := " "
+		when := ""
+		if t, ok := s.Completed[st.name]; ok {
+			mark = "x"
+			when = " (done " + t.Format("2006-01-02 15:04") + ")"
+		}
+		kind := ""
+		if st.isManual() {
+			kind = " [manual]"
+		}
+		pointer := "  "
+		if st == next {
+			pointer = "->"
+		}
+		fmt.Fprintf(&sb, "%s [%s] %2d %-28s %s%s%s\n", pointer, mark, st.number(), st.name, st.description, kind, when)
+	}
+	if next == nil {
+		sb.WriteString("\nAll steps have been completed.\n")
+	} else {
+		fmt.Fprintf(&sb, "\nNext up: `etap2sf next` will run step %d (%s).\n", next.number(), next.name)
+	}
+	return sb.String()
+}
+
+func usage() string {
+	sb := strings.Builder{}
+	sb.WriteString(`Usage: etap2sf [--profile name] <command> [flags]
+
+The profile picks which Salesforce org (from salesforce.profiles in the config)
+the command talks to. It defaults to $ETAP2SF_PROFILE, then to
+salesforce.default_profile.
+
+Commands:
+  status                   show which steps have been completed
+  next                     run the next step that hasn't been completed
+  run <step>               run a specific step, by name or number
+  done <step>              mark a (manual) step as complete
+  reset <step>             mark a step as not complete
+  validate [--partial]     validate the conversion locally
+  upload [--partial]       upload converted data to Salesforce
+    [--types Opportunity,...] [--refs file] [--from YYYY-MM-DD] [--to YYYY-MM-DD] [--hard]
+                           only upload the selected records, and with --hard, re-upload them
+  export [--delta]         download data from eTapestry, or only what changed since the last export
+  diff [--partial]         compare converted data with what's in Salesforce, without uploading
+  rollback [--dry-run] [--types Task,...]
+                           delete the records that the upload created, and nothing else
+  upload-errors            report the records that failed to upload, grouped by status code and field
+  reconcile                compare gift counts and totals in eTapestry with what's in Salesforce
+  <step>                   run a specific step, by name
+
+Steps:
+`)
+	for _, st := range allSteps {
+		kind := ""
+		if st.isManual() {
+			kind = " [manual]"
+		}
+		fmt.Fprintf(&sb, "  %2d %-28s %s%s\n", st.number(), st.name, st.description, kind)
+	}
+	return sb.String()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Silicon-Ally/etap2sf/salesforce/profile"
+)
+
+// state records which steps of the migration have been completed, so that
+// `etap2sf next` can pick up where the last run left off. Each profile's org
+// is migrated separately, so it's kept in the active profile's data directory.
+type state struct {
+	Completed map[string]time.Time
+
+	path string
+}
+
+const stateFile = "etap2sf-state.json"
+
+func loadState() (*state, error) {
+	path, err := profile.DataPath(stateFile)
+	if err != nil {
+		return nil, fmt.Errorf("getting state path: %w", err)
+	}
+	s := &state{Completed: map[string]time.Time{}, path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading state from %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("unmarshalling state: %w", err)
+	}
+	if s.Completed == nil {
+		s.Completed = map[string]time.Time{}
+	}
+	return s, nil
+}
+
+func (s *state) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0777); err != nil {
+		return fmt.Errorf("creating data dir: %w", err)
+	}
+	if err := os.WriteFile(s.path, data, 0777); err != nil {
+		return fmt.Errorf("writing state to %s: %w", s.path, err)
+	}
+	return nil
+}
+
+func (s *state) isDone(st *step) bool {
+	_, ok := s.Completed[st.name]
+	return ok
+}
+
+func (s *state) markDone(st *step) error {
+	s.Completed[st.name] = time.Now()
+	return s.save()
+}
+
+func (s *state) reset(st *step) error {
+	delete(s.Completed, st.name)
+	return s.save()
+}
+
+// next returns the first step that hasn't been completed, or nil if the migration is done.
+func (s *state) next() *step {
+	for _, st := range allSteps {
+		if !s.isDone(st) {
+			return st
+		}
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Silicon-Ally/etap2sf/conv/conversion/validate_conversion_locally"
+	"github.com/Silicon-Ally/etap2sf/conv/generate_converters"
+	"github.com/Silicon-Ally/etap2sf/conv/validate_fields_to_generate"
+	"github.com/Silicon-Ally/etap2sf/etap/attachments/exportfiles"
+	etap "github.com/Silicon-Ally/etap2sf/etap/client"
+	"github.com/Silicon-Ally/etap2sf/etap/data"
+	"github.com/Silicon-Ally/etap2sf/etap/data/download_all_data_from_etap"
+	"github.com/Silicon-Ally/etap2sf/salesforce"
+	esfutils "github.com/Silicon-Ally/etap2sf/salesforce/clients/enterprise/utils"
+	sf "github.com/Silicon-Ally/etap2sf/salesforce/clients/generic/utils"
+	msfutils "github.com/Silicon-Ally/etap2sf/salesforce/clients/metadata/utils"
+	"github.com/Silicon-Ally/etap2sf/salesforce/create_sf_fields"
+	"github.com/Silicon-Ally/etap2sf/salesforce/create_sf_layouts"
+	"github.com/Silicon-Ally/etap2sf/salesforce/generate_sf_enterprise_structs"
+	"github.com/Silicon-Ally/etap2sf/salesforce/generate_sf_metadata_structs"
+	"github.com/Silicon-Ally/etap2sf/salesforce/upload/upload_data_to_salesforce"
+)
+
+// step is a single phase of the migration. Automated steps have a run function,
+// manual steps instead provide instructions, and are marked as complete by the
+// user (via `etap2sf done`) once they've followed them. Steps that write to
+// Salesforce need a typed confirmation when the profile is production.
+type step struct {
+	name         string
+	description  string
+	run          func() error
+	instructions func() (string, error)
+	writes       bool
+}
+
+func (s *step) isManual() bool {
+	return s.instructions != nil
+}
+
+func (s *step) number() int {
+	for i, st := range allSteps {
+		if st == s {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+var allSteps = []*step{
+	{name: "validate-access", description: "Validate API access to eTapestry and Salesforce", run: validateAPIAccess},
+	{name: "export", description: "Download data from eTapestry", run: download_all_data_from_etap.Run},
+	{name: "export-attachments", description: "Download attachments from eTapestry", run: exportAttachments},
+	{name: "generate-metadata-structs", description: "Generate Salesforce metadata structs", run: generateMetadataStructs},
+	{name: "modify-salesforce-settings", description: "Modify existing Salesforce objects and NPSP settings", instructions: modifyExistingSalesforceObjects},
+	{name: "create-objects", description: "Create new Salesforce objects", run: createNewSalesforceObjects, writes: true},
+	{name: "validate-fields", description: "Validate the fields that will be generated", run: validate_fields_to_generate.Run},
+	{name: "create-fields", description: "Create Salesforce fields for the migration", run: create_sf_fields.Run, writes: true},
+	{name: "add-apex-class", description: "Add the custom Apex class", instructions: static(apexClassForEditButton)},
+	{name: "create-layouts", description: "Create Salesforce Visualforce components", run: create_sf_layouts.Run, writes: true},
+	{name: "add-visualforce-components", description: "Manually add Visualforce components where needed", instructions: static(addVisualforceComponents)},
+	{name: "generate-enterprise-structs", description: "Generate Salesforce enterprise structs", run: generate_sf_enterprise_structs.Run},
+	{name: "convert", description: "Generate converters", run: generate_converters.Run},
+	{name: "validate-partial", description: "Validate a partial conversion locally", run: func() error { return validate_conversion_locally.Run(true) }},
+	{name: "validate", description: "Validate the full conversion locally", run: func() error { return validate_conversion_locally.Run(false) }},
+	{name: "allow-created-date", description: "Allow updates to CreatedDate", instructions: static(allowUpdatesToCreatedDate)},
+	{name: "fix-triggers", description: "Correct duplicate NPSP triggers", run: fixNPSPTriggers, writes: true},
+	{name: "upload-partial", description: "Upload a partial data set", run: uploadPartial, writes: true},
+	{name: "upload", description: "Upload the full data set", run: func() error { return upload_data_to_salesforce.Run(false) }, writes: true},
+	{name: "cleanup-relationships", description: "Clean up duplicated relationships", run: cleanUpDuplicatedRelationships, writes: true},
+}
+
+// lookupStep finds a step by name or by number (i.e. "13" or "convert").
+func lookupStep(nameOrNumber string) (*step, error) {
+	if n, err := strconv.Atoi(nameOrNumber); err == nil {
+		if n < 1 || n > len(allSteps) {
+			return nil, fmt.Errorf("step number %d is out of range [1, %d]", n, len(allSteps))
+		}
+		return allSteps[n-1], nil
+	}
+	for _, st := range allSteps {
+		if st.name == nameOrNumber {
+			return st, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown step %q", nameOrNumber)
+}
+
+func static(s string) func() (string, error) {
+	return func() (string, error) { return s, nil }
+}
+
+func validateAPIAccess() error {
+	_, err := etap.WithClient(func(c *etap.Client) ([]byte, error) {
+		_, err := c.GetAllApproaches()
+		if err != nil {
+			return nil, err
+		}
+		return nil, nil
+	})
+	if err != nil {
+		fmt.Print(etapInstructions)
+		return fmt.Errorf("trouble logging into eTapestry: %w", err)
+	}
+
+	_, err = sf.NewGenericClient()
+	if err != nil {
+		fmt.Print(salesforceInstructions)
+		return fmt.Errorf("trouble logging into Salesforce : %w", err)
+	}
+	fmt.Printf("Success - able to log into both clients! You can proceed to the next step\n")
+	return nil
+}
+
+// attachmentsAuthn is populated from the flags of the `export-attachments` command.
+var attachmentsAuthn = &exportfiles.Authn{}
+
+func exportAttachments() error {
+	if err := exportfiles.Run(attachmentsAuthn); err != nil {
+		fmt.Print(exportAttachmentsInstructions)
+		return err
+	}
+	return nil
+}
+
+func exportDelta() error {
+	delta, err := data.ExportDelta()
+	if err != nil {
+		return fmt.Errorf("exporting changes from eTapestry: %w", err)
+	}
+	fmt.Print(delta.Summary())
+	fmt.Printf("\nA record of what changed has been saved in data/etap-deltas. Re-run `etap2sf convert` and the later steps to pick up the changes.\n")
+	return nil
+}
+
+func generateMetadataStructs() error {
+	if err := generate_sf_metadata_structs.Run(); err != nil {
+		return err
+	}
+	fmt.Printf("Done generating Salesforce metadata structs. Proceed to next step.\n")
+	return nil
+}
+
+func modifyExistingSalesforceObjects() (string, error) {
+	client, err := msfutils.NewMetadataSandboxClient()
+	if err != nil {
+		return "", fmt.Errorf("getting client: %w", err)
+	}
+	customTypes, err := getCustomTaskTypes()
+	if err != nil {
+		return "", fmt.Errorf("getting custom task types: %w", err)
+	}
+	baseSOAPUrl, err := url.Parse(client.GetURL())
+	if err != nil {
+		return "", fmt.Errorf("parsing url: %w", err)
+	}
+	base := baseSOAPUrl.Scheme + "://" + baseSOAPUrl.Host
+	taskSubjectURL := fmt.Sprintf("%s/lightning/setup/ObjectManager/Task/FieldsAndRelationships/Subject/addPicklistValues?tid=00T&pt=7", base)
+	taskTypeURL := fmt.Sprintf("%s/lightning/setup/ObjectManager/Task/FieldsAndRelationships/Type/addPicklistValues?tid=00T&pt=7", base)
+	return fmt.Sprintf(modifyExistingSalesforceObjectsFmt, base, taskSubjectURL, taskTypeURL, strings.Join(customTypes, "\n")), nil
+}
+
+func getCustomTaskTypes() ([]string, error) {
+	jes, err := data.GetJournalEntries()
+	if err != nil {
+		return nil, fmt.Errorf("getting jes: %w", err)
+	}
+	taskTypes := map[string]bool{"Note": true}
+	for _, je := range jes {
+		if je.Contact == nil {
+			continue
+		}
+		taskTypes[*je.Contact.Method] = true
+	}
+	result := make([]string, 0, len(taskTypes))
+	for tt := range taskTypes {
+		result = append(result, tt)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func createNewSalesforceObjects() error {
+	client, err := msfutils.NewMetadataSandboxClient()
+	if err != nil {
+		return fmt.Errorf("getting client: %w", err)
+	}
+	for _, sot := range salesforce.ObjectTypes {
+		if sot.IsCustomToMigration() {
+			if err := client.CreateCustomObject(sot); err != nil {
+				return fmt.Errorf("creating object: %w", err)
+			}
+		}
+	}
+	fmt.Print("Done creating new Salesforce objects. Proceed to next step.\n")
+	return nil
+}
+
+func fixNPSPTriggers() error {
+	client, err := esfutils.NewSandboxClient()
+	if err != nil {
+		return fmt.Errorf("creating client: %w", err)
+	}
+	err = client.FixNPSPTriggers()
+	if err != nil {
+		return fmt.Errorf("listing triggers: %w", err)
+	}
+	fmt.Printf("Done fixing NPSP triggers. Proceed to next step.\n")
+	return nil
+}
+
+func uploadPartial() error {
+	if err := upload_data_to_salesforce.Run(true); err != nil {
+		return err
+	}
+	fmt.Print("\nNote: This is a partial upload. Please proceed to the next step to upload the full data set.\n\n")
+	return nil
+}
+
+func cleanUpDuplicatedRelationships() error {
+	client, err := esfutils.NewSandboxClient()
+	if err != nil {
+		return fmt.Errorf("creating client: %w", err)
+	}
+	err = client.DeleteRelationshipsNotCreatedThroughETap()
+	if err != nil {
+		return fmt.Errorf("deleting relationships not created through etap: %w", err)
+	}
+	fmt.Printf("Done cleaning up duplicated relationships. This concludes the migration. Well done!\n")
+	return nil
+}
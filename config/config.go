@@ -0,0 +1,239 @@
+// Package config loads the per-migration configuration file, which allows
+// settings that differ between migrations to live outside of the code.
+//
+// The file is read from the path in the ETAP2SF_CONFIG environment variable,
+// or from etap2sf.yaml in the current working directory if that isn't set.
+// A missing file is not an error - every setting has a default in code.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ConfigPathEnvVar  = "ETAP2SF_CONFIG"
+	ProjectRootEnvVar = "ETAP2SF_PROJECT_ROOT"
+	defaultConfigPath = "etap2sf.yaml"
+)
+
+type Config struct {
+	// ProjectRoot is the root of the git repo on your local machine. A relative
+	// path is resolved relative to the config file. It's overridden by the
+	// ETAP2SF_PROJECT_ROOT environment variable.
+	ProjectRoot string     `yaml:"project_root"`
+	ETapestry   ETapestry  `yaml:"etapestry"`
+	Conversion  Conversion `yaml:"conversion"`
+	Salesforce  Salesforce `yaml:"salesforce"`
+	Upload      Upload     `yaml:"upload"`
+	Logging     Logging    `yaml:"logging"`
+}
+
+// Salesforce configures the orgs that the migration can talk to, see
+// salesforce/profile.
+type Salesforce struct {
+	// DefaultProfile is used when a command doesn't pick a profile.
+	DefaultProfile string                       `yaml:"default_profile"`
+	Profiles       map[string]SalesforceProfile `yaml:"profiles"`
+}
+
+type SalesforceProfile struct {
+	// ConnectionConfig is the file in secrets/ with the org's credentials. It
+	// defaults to salesforce-<profile>-connection-config.txt.
+	ConnectionConfig string `yaml:"connection_config"`
+	// Production marks an org with real data in it. Writes to it need a typed
+	// confirmation, and destructive operations (like deleting everything) are
+	// blocked.
+	Production bool `yaml:"production"`
+	// Auth is how the profile logs in. Without it, the username, password and
+	// security token in the connection config are used.
+	Auth SalesforceAuth `yaml:"auth"`
+}
+
+// SalesforceAuth configures logging in through a connected app, see
+// salesforce/clients/oauth.
+type SalesforceAuth struct {
+	// Flow is "password" (the default), "jwt" (the OAuth 2.0 JWT bearer flow)
+	// or "client_credentials".
+	Flow string `yaml:"flow"`
+	// ClientID is the consumer key of the connected app.
+	ClientID string `yaml:"client_id"`
+	// Username is the user that the JWT bearer flow logs in as.
+	Username string `yaml:"username"`
+	// PrivateKeyFile (for jwt) and ClientSecretFile (for client_credentials)
+	// are files in secrets/.
+	PrivateKeyFile   string `yaml:"private_key_file"`
+	ClientSecretFile string `yaml:"client_secret_file"`
+	// LoginURL is the org's login host, like yourorg.my.salesforce.com.
+	LoginURL string `yaml:"login_url"`
+	// TokenURL overrides the token endpoint, which is
+	// https://<login_url>/services/oauth2/token, i.e. to use a local stub.
+	TokenURL string `yaml:"token_url"`
+	// Audience overrides the aud claim of the JWT.
+	Audience string `yaml:"audience"`
+}
+
+// Logging configures the structured logger in the logging package.
+type Logging struct {
+	// Format is text (the default) or json.
+	Format string `yaml:"format"`
+	// Level is one of debug, info (the default), warn or error.
+	Level string `yaml:"level"`
+	// File is where logs are written, instead of stderr.
+	File string `yaml:"file"`
+	// ProgressIntervalSeconds is how often progress is logged during long phases.
+	ProgressIntervalSeconds float64 `yaml:"progress_interval_seconds"`
+}
+
+type ETapestry struct {
+	// AccountsQuery is the eTapestry query which exports all accounts, in the
+	// form "Folder::Query".
+	AccountsQuery string `yaml:"accounts_query"`
+	// ModifiedDateFields are the query fields that a delta export checks to
+	// find accounts that have changed since the last export.
+	ModifiedDateFields []string `yaml:"modified_date_fields"`
+	// RequestsPerSecond limits the rate of all calls to the eTapestry API, and
+	// Burst is how many calls can be made at once before the limit kicks in.
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+	Burst             int     `yaml:"burst"`
+	// MaxRetries is how many times a call that failed in a transient way (i.e.
+	// it was throttled, or the server or network had a problem) is retried.
+	MaxRetries int `yaml:"max_retries"`
+	// ExportWorkers is how many accounts have their journal entries downloaded
+	// at once, each with its own eTapestry session.
+	ExportWorkers int `yaml:"export_workers"`
+	// Trace controls the recording of SOAP requests and responses.
+	Trace Trace `yaml:"trace"`
+}
+
+// Trace configures etap/client/trace.
+type Trace struct {
+	// Mode is one of off (the default), errors, sampled or full.
+	Mode string `yaml:"mode"`
+	// SampleRate is the fraction of successful calls recorded in sampled mode.
+	SampleRate float64 `yaml:"sample_rate"`
+	// MaxSizeMB is how big the (uncompressed) log gets before it's rotated, and
+	// MaxFiles is how many logs are kept, including the current one.
+	MaxSizeMB int  `yaml:"max_size_mb"`
+	MaxFiles  int  `yaml:"max_files"`
+	Compress  bool `yaml:"compress"`
+	// Redact removes session cookies from the log. It defaults to true.
+	Redact *bool `yaml:"redact"`
+}
+
+// Conversion holds the settings in conv/conversionsettings. Any table that is
+// set here replaces the default table in code entirely.
+type Conversion struct {
+	AttributedUserEmail        string              `yaml:"attributed_user_email"`
+	CallerUserEmail            string              `yaml:"caller_user_email"`
+	ObjectTypeMap              map[string][]string `yaml:"object_type_map"`
+	CategoryNameSubstitutions  map[string]string   `yaml:"category_name_substitutions"`
+	CategoryLabelSubstitutions map[string]string   `yaml:"category_label_substitutions"`
+	FieldNameSubstitutions     map[string]string   `yaml:"field_name_substitutions"`
+	FieldLabelSubstitutions    map[string]string   `yaml:"field_label_substitutions"`
+	SectionLabelSubstitutions  map[string]string   `yaml:"section_label_substitutions"`
+	// LegacyHouseholds also puts each household's contacts in a legacy NPSP
+	// household (npo02__Household__c), for orgs that still use them.
+	LegacyHouseholds bool `yaml:"legacy_households"`
+}
+
+type Upload struct {
+	// Backends picks how each Salesforce object type (like "Task") is uploaded,
+	// either "soap" (one record per call, the default), "soap-batch" (up to 200
+	// records per call), "bulk" (Bulk API 2.0) or "rest" (REST sObject
+	// Collections, up to 200 records per call). Opportunity can also use
+	// "rest-graph", which uploads each opportunity along with its payments and
+	// allocations in one REST Composite Graph.
+	Backends map[string]string `yaml:"backends"`
+	// MaxParallelPhases is how many object types are uploaded at once, when
+	// they don't depend on each other.
+	MaxParallelPhases int `yaml:"max_parallel_phases"`
+	// NumThreads and MaxErrors override the uploader's saved settings: how
+	// many threads upload each object type, and how many errors (across all
+	// threads) stop it.
+	NumThreads int `yaml:"num_threads"`
+	MaxErrors  int `yaml:"max_errors"`
+	// MaxRetries is how many times a record that failed with a row lock,
+	// request limit or unavailable server is retried before it counts as an
+	// error. It defaults to 5.
+	MaxRetries int       `yaml:"max_retries"`
+	APILimits  APILimits `yaml:"api_limits"`
+}
+
+// APILimits keeps an upload from using up the org's daily API requests, which
+// everything else that talks to the org needs too.
+type APILimits struct {
+	// SlowDownAt is the share of the daily allowance (like 0.8 for 80%) past
+	// which calls are spaced out to one every SlowDownIntervalSeconds, across
+	// all threads. It defaults to 0.8.
+	SlowDownAt              float64 `yaml:"slow_down_at"`
+	SlowDownIntervalSeconds float64 `yaml:"slow_down_interval_seconds"`
+	// StopAt is the share of the daily allowance past which the upload stops,
+	// to be resumed once usage has dropped. It defaults to 0.9.
+	StopAt float64 `yaml:"stop_at"`
+	// MetricsAddr, if set, serves the API usage and call counts as JSON (with
+	// expvar) at this address, like "localhost:8123".
+	MetricsAddr string `yaml:"metrics_addr"`
+}
+
+var loadOnce = sync.OnceValues(load)
+
+// Load returns the configuration, reading it on first use. The command loads
+// it before doing anything else, so that a malformed config file stops it
+// rather than leaving it to silently do the wrong thing with the defaults.
+func Load() (*Config, error) {
+	c, err := loadOnce()
+	if err != nil {
+		return nil, fmt.Errorf("loading etap2sf config: %w", err)
+	}
+	return c, nil
+}
+
+// Get returns the configuration for code that can't return an error. If the
+// config file couldn't be loaded, it returns the defaults - the error is
+// returned by Load, which the command calls first.
+func Get() *Config {
+	c, err := loadOnce()
+	if err != nil {
+		return &Config{}
+	}
+	return c
+}
+
+// Path returns the path that the config file is read from.
+func Path() string {
+	if p := os.Getenv(ConfigPathEnvVar); p != "" {
+		return p
+	}
+	return defaultConfigPath
+}
+
+func load() (*Config, error) {
+	c := &Config{}
+	path := Path()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && os.Getenv(ConfigPathEnvVar) == "" {
+		// No config file is fine, everything has a default.
+	} else if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	} else {
+		if err := yaml.Unmarshal(data, c); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", path, err)
+		}
+		if c.ProjectRoot != "" && !filepath.IsAbs(c.ProjectRoot) {
+			abs, err := filepath.Abs(filepath.Join(filepath.Dir(path), c.ProjectRoot))
+			if err != nil {
+				return nil, fmt.Errorf("resolving project root %q: %w", c.ProjectRoot, err)
+			}
+			c.ProjectRoot = abs
+		}
+	}
+	if root := os.Getenv(ProjectRootEnvVar); root != "" {
+		c.ProjectRoot = root
+	}
+	return c, nil
+}
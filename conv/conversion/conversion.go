@@ -7,16 +7,24 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/Silicon-Ally/etap2sf/conv/conversionsettings"
+	"github.com/Silicon-Ally/etap2sf/etap"
 	"github.com/Silicon-Ally/etap2sf/etap/generated"
 	"github.com/Silicon-Ally/etap2sf/etap/generated/overrides"
+	"github.com/Silicon-Ally/etap2sf/logging"
+	"github.com/Silicon-Ally/etap2sf/salesforce"
 	"github.com/Silicon-Ally/etap2sf/salesforce/generated/sfenterprise"
 	"github.com/Silicon-Ally/etap2sf/utils"
 )
 
 func (in *Input) Convert() (*Output, error) {
+	logger := logging.For("conversion")
 	doConversion := func(name string, fn func() []error) error {
+		start := time.Now()
 		errors := fn()
+		logger.Info("converted", "stage", name, "errors", len(errors), "elapsed", time.Since(start).Round(time.Millisecond).String())
 		if len(errors) == 0 {
 			return nil
 		}
@@ -266,7 +274,7 @@ func (i *io) assignContactsToHouseholdAccounts() []error {
 			ok1 := generatesContact[a1]
 			ok2 := generatesContact[a2]
 			if !ok1 || !ok2 {
-				fmt.Printf("WARNING - Household Account Inclues Organization - %s and %s\n", *r.Account1Name, *r.Account2Name)
+				logging.For("conversion").Warn("household account includes an organization", "account1", *r.Account1Name, "account2", *r.Account2Name)
 				continue
 			}
 			if err := join(a1, a2); err != nil {
@@ -341,6 +349,21 @@ func (i *io) assignContactsToHouseholdAccounts() []error {
 			errors = append(errors, fmt.Errorf("generating placeholder account id: %w", err))
 			continue
 		}
+		// The legacy household needs a ref of its own, since refs share one ID map.
+		var placeholderLegacyHouseholdID *sfenterprise.ID
+		if conversionsettings.LegacyHouseholds {
+			legacyHouseholdRef := "SynthLegacyHH" + string(refs[0])
+			i.out.Households = append(i.out.Households, &sfenterprise.Npo02__Household__c{
+				Name:                         trimIfLongerThan(clonePtr(household.Name), 80),
+				Etap_MultiObject_EtapRef__c:  ptr(legacyHouseholdRef),
+				Etap_MigrationExplanation__c: clonePtr(household.Etap_MigrationExplanation__c),
+				Etap_MigrationTime__c:        NowXSD(),
+			})
+			if placeholderLegacyHouseholdID, err = idPlaceholderForRef(&legacyHouseholdRef); err != nil {
+				errors = append(errors, fmt.Errorf("generating placeholder household id: %w", err))
+				continue
+			}
+		}
 		for _, ref := range refs {
 			c, err := i.lookupSFContactByRef(string(ref))
 			if err != nil {
@@ -352,6 +375,9 @@ func (i *io) assignContactsToHouseholdAccounts() []error {
 			// "expected to need replacement, but was" error, because it was replaced from the
 			// conversion of another househol member.
 			c.AccountId = clonePtr(placeholderAccountID)
+			if placeholderLegacyHouseholdID != nil {
+				c.Npo02__Household__c = clonePtr(placeholderLegacyHouseholdID)
+			}
 		}
 	}
 	return errors
@@ -372,7 +398,7 @@ func (i *io) convertRelationships() []error {
 			}
 			i.out.Relationships = append(i.out.Relationships, out)
 		} else if accounts {
-			fmt.Printf("WARNING - relationship between accounts %s and %s - skipping\n", *r.Account1Name, *r.Account2Name)
+			logging.For("conversion").Warn("skipping relationship between accounts", "account1", *r.Account1Name, "account2", *r.Account2Name)
 		} else {
 			out, err := i.transformETAPRelationshipToSalesforceAffiliation(r)
 			if err != nil {
@@ -436,11 +462,19 @@ func (i *io) convertJournalEntry(je *overrides.JournalEntry) error {
 		return nil
 	}
 	if je.Note != nil {
-		task, err := i.transformETAPNoteToSalesforceTask(je.Note)
-		if err != nil {
-			return fmt.Errorf("converting note: %w", err)
+		if conversionsettings.ConvertsTo(etap.ObjectType_Note, salesforce.ObjectType_ContentNote) {
+			note, err := i.pureManualCreateNote(je.Note)
+			if err != nil {
+				return fmt.Errorf("converting note to content note: %w", err)
+			}
+			i.out.ContentNotes = append(i.out.ContentNotes, note)
+		} else {
+			task, err := i.transformETAPNoteToSalesforceTask(je.Note)
+			if err != nil {
+				return fmt.Errorf("converting note: %w", err)
+			}
+			i.out.Tasks = append(i.out.Tasks, task)
 		}
-		i.out.Tasks = append(i.out.Tasks, task)
 		context, err := i.transformETAPNoteToSalesforceEtapAdditionalContext(je.Note)
 		if err != nil {
 			return fmt.Errorf("converting note to additional context: %w", err)
@@ -508,7 +542,7 @@ func (i *io) convertContactSoftCredit(in *generated.SoftCredit) error {
 	out, err := i.transformETAPSoftCreditToSalesforcePartialSoftCredit(in)
 	if err != nil {
 		if errors.Is(err, &IsMissingHardCredit{}) {
-			fmt.Printf("WARNING - skipping a soft credit (%s) without a corresponding hard credit (%s).\n", *in.Ref, *in.HardCreditRef)
+			logging.For("conversion").Warn("skipping a soft credit without a corresponding hard credit", "ref", *in.Ref, "hard_credit_ref", *in.HardCreditRef)
 			return nil
 		}
 		return fmt.Errorf("converting soft credit to partial soft credit: %w", err)
@@ -521,7 +555,7 @@ func (i *io) convertAccountSoftCredit(in *generated.SoftCredit) error {
 	out, err := i.transformETAPSoftCreditToSalesforceAccountSoftCredit(in)
 	if err != nil {
 		if errors.Is(err, &IsMissingHardCredit{}) {
-			fmt.Printf("WARNING - skipping a soft credit (%s) without a corresponding hard credit (%s).\n", *in.Ref, *in.HardCreditRef)
+			logging.For("conversion").Warn("skipping a soft credit without a corresponding hard credit", "ref", *in.Ref, "hard_credit_ref", *in.HardCreditRef)
 			return nil
 		}
 		return fmt.Errorf("converting soft credit to account soft credit: %w", err)
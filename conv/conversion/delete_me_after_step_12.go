@@ -29,4 +29,6 @@ func (o *Output) ReplaceAllIDsInTasks(idMap map[string]string) []error { return
 
 func (o *Output) ReplaceAllIDsInContentDocumentLinks(idMap map[string]string) []error { return errs }
 
+func (o *Output) ReplaceAllIDsInContentNotes(idMap map[string]string) []error { return errs }
+
 func (i *Input) Convert() (*Output, error) { return nil, err }
@@ -62,6 +62,7 @@ func (o *Output) ReplaceAllIDsInContacts(idMap map[string]string) []error {
 	return replaceAllIDs(o.Contacts, idMap, func(c *sfenterprise.Contact) []*sfenterprise.ID {
 		return []*sfenterprise.ID{
 			c.AccountId,
+			c.Npo02__Household__c,
 		}
 	})
 }
@@ -162,6 +163,19 @@ func (o *Output) ReplaceAllIDsInContentDocumentLinks(idMap map[string]string) []
 	})
 }
 
+func (o *Output) ReplaceAllIDsInContentNotes(idMap map[string]string) []error {
+	links := make([]*sfenterprise.ContentDocumentLink, len(o.ContentNotes))
+	for i, n := range o.ContentNotes {
+		links[i] = n.Link
+	}
+	return replaceAllIDs(links, idMap, func(a *sfenterprise.ContentDocumentLink) []*sfenterprise.ID {
+		return []*sfenterprise.ID{
+			a.ContentDocumentId,
+			a.LinkedEntityId,
+		}
+	})
+}
+
 func replaceAllIDs[T any](ts []*T, idMap map[string]string, fieldsFn func(*T) []*sfenterprise.ID) []error {
 	errors := []error{}
 	for _, t := range ts {
@@ -42,7 +42,7 @@ type Output struct {
 	Contacts               []*sfenterprise.Contact
 	ContentDocumentLinks   []*sfenterprise.ContentDocumentLink
 	ContentVersions        []*sfenterprise.ContentVersion
-	ContentNotes           []*sfenterprise.ContentNote
+	ContentNotes           []*Note
 	GeneralAccountingUnits []*sfenterprise.Npsp__General_Accounting_Unit__c
 	GAUAllocations         []*sfenterprise.Npsp__Allocation__c
 	Households             []*sfenterprise.Npo02__Household__c
@@ -58,11 +58,26 @@ type Output struct {
 	contactsByRefs   map[string]*sfenterprise.Contact //nolint:unused // Used in files after step 12.
 }
 
+// Note is a journal entry that's uploaded as a Salesforce Note (ContentNote),
+// for migrations that would rather have notes than tasks. Notes can't have
+// custom fields, so the eTapestry ref is kept alongside the note, and the note
+// is created rather than upserted. Link attaches it to the record it's about
+// once it's been created - its ContentDocumentId is a placeholder for Ref, and
+// its LinkedEntityId is a placeholder for the record.
+type Note struct {
+	Ref         string
+	ContentNote *sfenterprise.ContentNote
+	Link        *sfenterprise.ContentDocumentLink
+}
+
 func GetInput() (*Input, error) {
 	client, err := utils.NewSandboxClient()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get sandbox client: %v", err)
 	}
+	if err := conversionsettings.Load(); err != nil {
+		return nil, err
+	}
 	attributedUserID, err := client.LookupUserByEmail(conversionsettings.AttributedUserEmail)
 	if err != nil {
 		return nil, fmt.Errorf("failed to lookup attributed user: %v", err)
@@ -5,6 +5,7 @@ package conversion
 import (
 	"encoding/base64"
 	"fmt"
+	"html"
 	"os"
 	"sort"
 	"strings"
@@ -1185,6 +1186,63 @@ func (i *io) pureManualCreateContentDocumentLink(je *overrides.JournalEntry, a *
 	return out, nil
 }
 
+// pureManualCreateNote converts a note into a Salesforce Note, for migrations
+// that map notes to ContentNote. Notes can't have custom fields, so there's no
+// generated converter for them, and the link to the account or contact that
+// the note is about is made once the note has been created.
+func (i *io) pureManualCreateNote(in *generated.Note) (*Note, error) {
+	if in.Ref == nil || *in.Ref == "" {
+		return nil, fmt.Errorf("note has no ref")
+	}
+	out := &sfenterprise.ContentNote{}
+	out.Title = ptr(fmt.Sprintf("eTapestry Note from %s", *in.Date))
+	out.OwnerId = &i.in.AttributedUserId
+	out.CreatedById = &i.in.AttributedUserId
+	out.LastModifiedById = &i.in.AttributedUserId
+	if date, err := AttemptToParseNilableDateTime(in.CreatedDate); err != nil {
+		return nil, fmt.Errorf("created date: %w", err)
+	} else {
+		out.CreatedDate = date
+	}
+	if date, err := AttemptToParseNilableDateTime(in.LastModifiedDate); err != nil {
+		return nil, fmt.Errorf("last modified date: %w", err)
+	} else {
+		out.LastModifiedDate = date
+	}
+	// Note content is HTML, so the text is escaped and its line breaks kept.
+	text := ""
+	if in.Note != nil {
+		text = strings.ReplaceAll(html.EscapeString(*in.Note), "\n", "<br>")
+	}
+	content := encodeToBase64([]byte(text))
+	out.Content = &content
+
+	link := &sfenterprise.ContentDocumentLink{}
+	if id, err := idPlaceholderForRef(in.Ref); err != nil {
+		return nil, fmt.Errorf("creating placeholder for note ref: %w", err)
+	} else {
+		link.ContentDocumentId = id
+	}
+	if makerContact, ok := i.out.contactsByRefs[*in.AccountRef]; ok {
+		id, err := idPlaceholderForRef(makerContact.Etap_Account_Ref__c)
+		if err != nil {
+			return nil, fmt.Errorf("creating placeholder for note contact: %w", err)
+		}
+		link.LinkedEntityId = id
+	} else if makerAccount, ok := i.out.accountsByRefs[*in.AccountRef]; ok {
+		id, err := idPlaceholderForRef(makerAccount.Etap_MultiObject_EtapRef__c)
+		if err != nil {
+			return nil, fmt.Errorf("creating placeholder for note account: %w", err)
+		}
+		link.LinkedEntityId = id
+	} else {
+		return nil, fmt.Errorf("could not find account for note: %q with ref %q", *in.Ref, *in.AccountRef)
+	}
+	link.Visibility = ptr(sfenterprise.ContentDocumentLink_Visibility_AllUsers)
+	link.ShareType = ptr(sfenterprise.ContentDocumentLink_ShareType_I)
+	return &Note{Ref: *in.Ref, ContentNote: out, Link: link}, nil
+}
+
 func (i *io) manualTransformETAPSegmentedDonationToSalesforceOpportunity(in *generated.SegmentedDonation, out *sfenterprise.Opportunity) error {
 	explanation := fmt.Sprintf("This additional context was generated from an eTapestry Segmented Donation from %s.", *in.Date)
 	if exp, err := errIfLongerThan(&explanation, 255); err != nil {
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/Silicon-Ally/etap2sf/etap/generated"
+	"github.com/Silicon-Ally/etap2sf/logging"
 	"github.com/Silicon-Ally/etap2sf/salesforce/generated/sfenterprise"
 	"github.com/Silicon-Ally/etap2sf/utils"
 	"github.com/hooklift/gowsdl/soap"
@@ -98,7 +99,7 @@ func dumpToTemporaryFile(fileName string, obj any) error {
 	if filePath, err := utils.WriteBytesToTempFile(data, fileName); err != nil {
 		return fmt.Errorf("writing %q: %w", fileName, err)
 	} else {
-		fmt.Printf("wrote to %s\n", filePath)
+		logging.For("conversion").Info("wrote file", "path", filePath)
 	}
 	return nil
 }
@@ -1,6 +1,7 @@
 package validate_conversion_locally
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/Silicon-Ally/etap2sf/conv/conversion"
@@ -28,7 +29,7 @@ func Run(partial bool) error {
 	if err != nil {
 		return fmt.Errorf("failed to get uploader: %v", err)
 	}
-	if err := uploader.Upload(output); err != nil {
+	if err := uploader.Upload(context.Background(), output); err != nil {
 		return fmt.Errorf("failed to upload: %v", err)
 	}
 	fmt.Printf("Conversion succeeded, you successfully (FAKE) uploaded %d records. You can proceed to the next step.\n", len(uploader.Succeeded))
@@ -2,8 +2,11 @@
 package conversionsettings
 
 import (
+	"fmt"
 	"strings"
+	"sync"
 
+	"github.com/Silicon-Ally/etap2sf/config"
 	"github.com/Silicon-Ally/etap2sf/etap"
 	"github.com/Silicon-Ally/etap2sf/salesforce"
 	"github.com/Silicon-Ally/etap2sf/salesforce/generated/sfmetadata"
@@ -12,6 +15,10 @@ import (
 var AttributedUserEmail = "user-to-attribute-to@your.org"
 var CallerUserEmail = "your-email@your.org"
 
+// LegacyHouseholds is whether contacts are also put in legacy NPSP households,
+// alongside their household accounts.
+var LegacyHouseholds = false
+
 var NovelObjectTypes = []salesforce.ObjectType{
 	salesforce.ObjectType_AdditionalContext,
 }
@@ -109,6 +116,97 @@ var SectionLabelSubstitutions = map[string]string{
 	"ETapestry: Org. Info": "ETapestry: Organization Information",
 }
 
+// Load overrides the defaults above with the `conversion` section of the
+// config file, so that they don't need to be edited here. It only reads the
+// config the first time it's called, and everything that reads the settings
+// calls it first.
+func Load() error {
+	return load()
+}
+
+var load = sync.OnceValue(func() error {
+	c, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if err := applyConfig(&c.Conversion); err != nil {
+		return fmt.Errorf("applying conversion settings from %s: %w", config.Path(), err)
+	}
+	return nil
+})
+
+func applyConfig(c *config.Conversion) error {
+	if c.AttributedUserEmail != "" {
+		AttributedUserEmail = c.AttributedUserEmail
+	}
+	if c.CallerUserEmail != "" {
+		CallerUserEmail = c.CallerUserEmail
+	}
+	if c.LegacyHouseholds {
+		LegacyHouseholds = true
+	}
+	if c.ObjectTypeMap != nil {
+		m, err := objectTypeMapFromConfig(c.ObjectTypeMap)
+		if err != nil {
+			return fmt.Errorf("object_type_map: %w", err)
+		}
+		ObjectTypeMap = m
+	}
+	if c.CategoryNameSubstitutions != nil {
+		CategoryNameSubstitutions = c.CategoryNameSubstitutions
+	}
+	if c.CategoryLabelSubstitutions != nil {
+		CategoryLabelSubstitutions = c.CategoryLabelSubstitutions
+	}
+	if c.FieldNameSubstitutions != nil {
+		FieldNameSubstitutions = c.FieldNameSubstitutions
+	}
+	if c.FieldLabelSubstitutions != nil {
+		FieldLabelSubstitutions = c.FieldLabelSubstitutions
+	}
+	if c.SectionLabelSubstitutions != nil {
+		SectionLabelSubstitutions = c.SectionLabelSubstitutions
+	}
+	return nil
+}
+
+// ConvertsTo is whether the eTapestry object type is converted into the
+// Salesforce object type.
+func ConvertsTo(eot etap.ObjectType, sot salesforce.ObjectType) bool {
+	for _, s := range ObjectTypeMap[eot] {
+		if s == sot {
+			return true
+		}
+	}
+	return false
+}
+
+func objectTypeMapFromConfig(in map[string][]string) (map[etap.ObjectType][]salesforce.ObjectType, error) {
+	eots := map[string]etap.ObjectType{}
+	for _, eot := range etap.ObjectTypes {
+		eots[string(eot)] = eot
+	}
+	sots := map[string]salesforce.ObjectType{}
+	for _, sot := range salesforce.ObjectTypes {
+		sots[string(sot)] = sot
+	}
+	result := map[etap.ObjectType][]salesforce.ObjectType{}
+	for k, vs := range in {
+		eot, ok := eots[k]
+		if !ok {
+			return nil, fmt.Errorf("unknown etap object type %q", k)
+		}
+		for _, v := range vs {
+			sot, ok := sots[v]
+			if !ok {
+				return nil, fmt.Errorf("unknown salesforce object type %q (for etap object type %q)", v, k)
+			}
+			result[eot] = append(result[eot], sot)
+		}
+	}
+	return result, nil
+}
+
 func OverrideFieldNameValue(name string) string {
 	return name
 }
@@ -20,6 +20,9 @@ func GetSalesforceFieldsDerivedFromETapestry(sot salesforce.ObjectType) ([]*sfme
 	if err != nil {
 		return nil, []error{fmt.Errorf("getting custom fields: %w", err)}
 	}
+	if err := conversionsettings.Load(); err != nil {
+		return nil, []error{err}
+	}
 	groupedByET := customFields.GroupedByETapObject()
 	fields := []*sfmetadata.CustomField{}
 	errors := []error{}
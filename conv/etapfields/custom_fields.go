@@ -27,6 +27,9 @@ func (f *CustomField) GoCodeAssignToVar(varname string) (string, error) {
 }
 
 func (f *CustomField) GoCodeAssignmentFromVar(varname string, sfot salesforce.ObjectType) (string, error) {
+	if err := conversionsettings.Load(); err != nil {
+		return "", err
+	}
 	cf, err := ETapCustomFieldToSFCustomField(f.Delegate)
 	if err != nil {
 		return "", fmt.Errorf("converting custom field to salesforce custom field: %w", err)
@@ -8,6 +8,9 @@ import (
 )
 
 func CreateSalesforceFieldNameAndLabel(etapCategoryName, etapFieldName string, isSystemField bool) (string, string, error) {
+	if err := conversionsettings.Load(); err != nil {
+		return "", "", err
+	}
 	sanitizedCategoryName := utils.AlphanumericOnly(etapCategoryName)
 	if sub, ok := conversionsettings.CategoryNameSubstitutions[sanitizedCategoryName]; ok {
 		sanitizedCategoryName = sub
@@ -59,6 +59,9 @@ func getStandardFields(eot etap.ObjectType, sot salesforce.ObjectType) ([]*conv.
 	if eot.IsString() || sot == salesforce.ObjectType_ContentDocumentLink {
 		return nil, nil
 	}
+	if err := conversionsettings.Load(); err != nil {
+		return nil, err
+	}
 	sfs := conversionsettings.ObjectTypeMap[eot]
 	eto, err := eot.Struct()
 	if err != nil {
@@ -219,10 +222,14 @@ func sfStruct(o salesforce.ObjectType) (any, error) {
 		return sfenterprise.Contact{}, nil
 	case salesforce.ObjectType_ContentDocumentLink:
 		return sfenterprise.ContentDocumentLink{}, nil
+	case salesforce.ObjectType_ContentNote:
+		return sfenterprise.ContentNote{}, nil
 	case salesforce.ObjectType_ContentVersion:
 		return sfenterprise.ContentVersion{}, nil
 	case salesforce.ObjectType_GeneralAccountingUnit:
 		return sfenterprise.Npsp__General_Accounting_Unit__c{}, nil
+	case salesforce.ObjectType_Household:
+		return sfenterprise.Npo02__Household__c{}, nil
 	case salesforce.ObjectType_Opportunity:
 		return sfenterprise.Opportunity{}, nil
 	case salesforce.ObjectType_Payment:
@@ -7,12 +7,21 @@ import (
 	"strings"
 
 	"github.com/Silicon-Ally/etap2sf/conv/conversionsettings"
+	"github.com/Silicon-Ally/etap2sf/salesforce"
 	"github.com/Silicon-Ally/etap2sf/utils"
 )
 
 func Run() error {
+	if err := conversionsettings.Load(); err != nil {
+		return err
+	}
 	for eot, sots := range conversionsettings.ObjectTypeMap {
 		for _, sot := range sots {
+			// Notes can't have custom fields, so there's nothing to generate -
+			// they're converted by hand, in pureManualCreateNote.
+			if sot == salesforce.ObjectType_ContentNote {
+				continue
+			}
 			standard, custom, err := eTapToSalesforceFieldMappings(eot, sot)
 			if err != nil {
 				return fmt.Errorf("failed to construct mappings: %v", err)
@@ -37,7 +37,7 @@ func GetValidatedFieldsToGenerate() ([]*FieldToCreate, error) {
 	errors := []error{}
 	for _, sot := range salesforce.ObjectTypes {
 		// These cannot be modified.
-		if sot == salesforce.ObjectType_ContentDocumentLink {
+		if sot == salesforce.ObjectType_ContentDocumentLink || sot == salesforce.ObjectType_ContentNote {
 			continue
 		}
 		sotETapKey, err := sot.SalesforceObjectExternalFieldKey()
@@ -6,6 +6,8 @@ import (
 	"math/rand"
 	"time"
 
+	"github.com/Silicon-Ally/etap2sf/logging"
+
 	"go.uber.org/multierr"
 )
 
@@ -20,12 +22,17 @@ func Run(authn *Authn) (rErr error) {
 		}
 	}()
 
+	progress := logging.NewProgress("export files", "", len(m.Entries)-m.Success)
+	defer progress.Done()
 	hasMore := true
 	for hasMore {
+		before := m.Success
 		hm, err := m.Process(authn)
 		if err != nil {
+			progress.Failed()
 			return fmt.Errorf("failed to process manifest: %w", err)
 		}
+		progress.Add(m.Success-before, 0)
 		hasMore = hm
 		waitSoAsNotToOverloadTheServer()
 	}
@@ -38,13 +45,6 @@ func waitSoAsNotToOverloadTheServer() {
 	maxMs := 10000
 	rangeMs := maxMs - minMs
 	toWaitMs := int(float64(maxMs) - math.Pow(float64(rand.Intn(rangeMs*rangeMs*rangeMs)), .3333))
-	fmt.Printf("  wait for %d ms: ", toWaitMs)
-	i := 0
-	for toWaitMs-i >= 1000 {
-		time.Sleep(1 * time.Second)
-		i += 1000
-		fmt.Printf("%d... ", i/1000)
-	}
-	time.Sleep(time.Duration(toWaitMs-i) * time.Millisecond)
-	fmt.Println("done.")
+	logging.For("exportfiles").Debug("waiting before the next file", "ms", toWaitMs)
+	time.Sleep(time.Duration(toWaitMs) * time.Millisecond)
 }
@@ -11,6 +11,7 @@ import (
 	"github.com/Silicon-Ally/etap2sf/etap/data"
 	"github.com/Silicon-Ally/etap2sf/etap/generated"
 	"github.com/Silicon-Ally/etap2sf/etap/generated/overrides"
+	"github.com/Silicon-Ally/etap2sf/logging"
 	"github.com/Silicon-Ally/etap2sf/utils"
 )
 
@@ -85,11 +86,11 @@ type Manifest struct {
 }
 
 func (m *Manifest) Load() error {
-	fmt.Println("Loading manifest...")
+	logging.For("exportfiles").Info("loading manifest")
 	bytes, err := os.ReadFile(manifestPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			fmt.Println("Creating manifest...")
+			logging.For("exportfiles").Info("creating manifest")
 			jes, err := data.GetJournalEntries()
 			if err != nil {
 				return fmt.Errorf("failed to get journal entries: %w", err)
@@ -137,7 +138,7 @@ func (m *Manifest) Load() error {
 }
 
 func (m *Manifest) Save() error {
-	fmt.Println("Saving manifest...")
+	logging.For("exportfiles").Info("saving manifest")
 	b, err := json.MarshalIndent(m, "", "  ")
 	if err != nil {
 		return fmt.Errorf("unable to marshal manifest: %w", err)
@@ -165,7 +166,7 @@ func sanitizeFilename(s *string) *string {
 func (m *Manifest) Process(authn *Authn) (bool, error) {
 	for i, e := range m.Entries {
 		if !e.DoneSuccess {
-			fmt.Printf("%d/%d (%d OK) Processing %s\n", i, len(m.Entries), m.Success, *e.Attachment.Ref)
+			logging.For("exportfiles").Debug("processing attachment", "index", i, "total", len(m.Entries), "succeeded", m.Success, "ref", *e.Attachment.Ref)
 			if err := e.Process(authn); err != nil {
 				return true, fmt.Errorf("failed to process manifest entry: %w", err)
 			}
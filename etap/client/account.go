@@ -6,6 +6,7 @@ import (
 
 	"github.com/Silicon-Ally/etap2sf/etap/generated"
 	"github.com/Silicon-Ally/etap2sf/etap/generated/overrides"
+	"github.com/Silicon-Ally/etap2sf/logging"
 )
 
 func (c *Client) GetAccounts(queryName string, start int, count int) ([]*generated.Account, error) {
@@ -22,12 +23,9 @@ func (c *Client) GetAccounts(queryName string, start int, count int) ([]*generat
 		},
 	}
 	result := overrides.AccountBody{}
-	if err := generated.RoundTripWithAction(c.ms, "GetExistingQueryResults", request, &result); err != nil {
+	if err := c.roundTrip("GetExistingQueryResults", request, &result); err != nil {
 		return nil, fmt.Errorf("client error: %v", err)
 	}
-	if c.err != nil {
-		return nil, fmt.Errorf("fault code error: %v", c.err)
-	}
 	return ptrs(result.M.Result.Data.Items), nil
 }
 
@@ -45,7 +43,7 @@ func (c *Client) GetAllAccounts(queryName string) ([]*generated.Account, error)
 		if len(page) < count {
 			break
 		}
-		fmt.Printf("Successfully Processed %d Accounts\n", start)
+		logging.For("etap/client").Info("processed page", "records", "accounts", "so_far", start)
 		time.Sleep(time.Second * 5)
 	}
 	return accounts, nil
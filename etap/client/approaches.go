@@ -16,11 +16,8 @@ func (c *Client) GetAllApproaches() ([]string, error) {
 		},
 	}
 	result := overrides.ApproachesBody{}
-	if err := generated.RoundTripWithAction(c.ms, "GetApproaches", request, &result); err != nil {
+	if err := c.roundTrip("GetApproaches", request, &result); err != nil {
 		return nil, fmt.Errorf("client error: %v", err)
 	}
-	if c.err != nil {
-		return nil, fmt.Errorf("fault code error: %v", c.err)
-	}
 	return result.M.Result.Items, nil
 }
@@ -16,11 +16,8 @@ func (c *Client) GetAllCampaigns() ([]string, error) {
 		},
 	}
 	result := overrides.CampaignsBody{}
-	if err := generated.RoundTripWithAction(c.ms, "GetCampaigns", request, &result); err != nil {
+	if err := c.roundTrip("GetCampaigns", request, &result); err != nil {
 		return nil, fmt.Errorf("client error: %v", err)
 	}
-	if c.err != nil {
-		return nil, fmt.Errorf("fault code error: %v", c.err)
-	}
 	return result.M.Result.Items, nil
 }
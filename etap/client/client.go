@@ -4,12 +4,13 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
+	"time"
 
+	"github.com/Silicon-Ally/etap2sf/etap/client/trace"
 	"github.com/Silicon-Ally/etap2sf/etap/generated"
+	"github.com/Silicon-Ally/etap2sf/logging"
 	"github.com/Silicon-Ally/etap2sf/secrets"
-	"github.com/Silicon-Ally/etap2sf/utils"
 	"github.com/fiorix/wsdl2go/soap"
 	"go.uber.org/multierr"
 )
@@ -18,38 +19,40 @@ type Client struct {
 	ms      generated.MessagingService
 	cookies []*http.Cookie
 	err     error
+
+	// These are kept so that the client can log in again if its session expires.
+	dbName       string
+	secretAPIKey string
+	retry        *retryPolicy
+
+	tracer      trace.Tracer
+	lastRequest *trace.Exchange
 }
 
 const InitialUrl string = "https://sna.etapestry.com/v3messaging/service?WSDL"
 
 func NewClient(dbName, secretAPIKey string) (*Client, error) {
-	url := InitialUrl
-	redirectURL, cookies, err := attemptLogin(url, dbName, secretAPIKey)
+	url, cookies, err := login(dbName, secretAPIKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to perform initial login: %v", err)
-	}
-	if redirectURL != "" {
-		url = redirectURL
-		fmt.Printf("redirected to %s\n", url)
-		redirectURL, cookies, err = attemptLogin(url, dbName, secretAPIKey)
-		if err != nil {
-			return nil, fmt.Errorf("failed to perform secondary login: %v", err)
-		}
-		if redirectURL != "" {
-			return nil, fmt.Errorf("redirected login tried to redirect again, to %s", redirectURL)
-		}
-	}
-	if cookies == nil {
-		return nil, fmt.Errorf("failed to get JSESSIONID cookie")
+		return nil, err
 	}
 
-	c := &Client{cookies: cookies}
+	c := &Client{
+		cookies:      cookies,
+		dbName:       dbName,
+		secretAPIKey: secretAPIKey,
+		retry:        retryPolicyFromConfig(),
+		tracer:       trace.Default(),
+	}
 	sc := &soap.Client{
 		URL:       url,
 		Namespace: generated.Namespace,
 		Pre: func(r *http.Request) {
+			if err := limiter().Wait(r.Context()); err != nil {
+				logging.For("etap/client").Warn("failed to wait for rate limiter", "error", err)
+			}
 			if err := addRequiredSOAPEncodingStyle(r); err != nil {
-				log.Printf("failed to add required SOAP encoding style: %v", err)
+				logging.For("etap/client").Warn("failed to add required SOAP encoding style", "error", err)
 			}
 			for _, cookie := range c.cookies {
 				r.AddCookie(cookie)
@@ -59,22 +62,29 @@ func NewClient(dbName, secretAPIKey string) (*Client, error) {
 			// Restore the io.ReadCloser to its original state
 			r.Body = io.NopCloser(bytes.NewBuffer(body))
 
-			// Use the body content
-			file, _ := utils.WriteBytesToTempFile(body, "etap-request")
-			log.Printf("\n%s request: %s ", r.URL.Path, file)
+			// Keep the request so that it can be traced along with its response.
+			c.lastRequest = &trace.Exchange{
+				Time:           time.Now(),
+				Path:           r.URL.Path,
+				RequestHeaders: r.Header.Clone(),
+				Request:        body,
+			}
 		},
 		Post: func(resp *http.Response) {
 			body, _ := io.ReadAll(resp.Body)
-			if fileName, err := utils.WriteBytesToTempFile(body, "etap-response"); err != nil {
-				c.err = err
-			} else {
-				fmt.Printf("response: %s\n", fileName)
-			}
 			resp.Body = io.NopCloser(bytes.NewBuffer(body))
 
 			if err := checkForFaultCode(resp); err != nil {
 				c.err = err
 			}
+			if e := c.lastRequest; e != nil {
+				e.StatusCode = resp.StatusCode
+				e.ResponseHeaders = resp.Header
+				e.Response = body
+				e.Err = c.err
+				c.tracer.Trace(e)
+				c.lastRequest = nil
+			}
 			if err := resolveXmlHrefsHttpResponse(resp); err != nil {
 				c.err = err
 			}
@@ -109,6 +119,31 @@ func WithClient(fn func(c *Client) ([]byte, error)) (dat []byte, rErr error) {
 	return fn(c)
 }
 
+// login logs into eTapestry, following the redirect to the right data center
+// if there is one, and returns the URL to use along with the session cookies.
+func login(dbName, secretAPIKey string) (string, []*http.Cookie, error) {
+	url := InitialUrl
+	redirectURL, cookies, err := attemptLogin(url, dbName, secretAPIKey)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to perform initial login: %v", err)
+	}
+	if redirectURL != "" {
+		url = redirectURL
+		logging.For("etap/client").Info("redirected to another data center", "url", url)
+		redirectURL, cookies, err = attemptLogin(url, dbName, secretAPIKey)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to perform secondary login: %v", err)
+		}
+		if redirectURL != "" {
+			return "", nil, fmt.Errorf("redirected login tried to redirect again, to %s", redirectURL)
+		}
+	}
+	if cookies == nil {
+		return "", nil, fmt.Errorf("failed to get JSESSIONID cookie")
+	}
+	return url, cookies, nil
+}
+
 func attemptLogin(url string, dbName string, secretAPIKey string) (string, []*http.Cookie, error) {
 	var cookies []*http.Cookie
 	var e error
@@ -117,7 +152,7 @@ func attemptLogin(url string, dbName string, secretAPIKey string) (string, []*ht
 		Namespace: generated.Namespace,
 		Pre: func(r *http.Request) {
 			if err := addRequiredSOAPEncodingStyle(r); err != nil {
-				log.Printf("failed to add required SOAP encoding style: %v", err)
+				logging.For("etap/client").Warn("failed to add required SOAP encoding style", "error", err)
 			}
 		},
 		Post: func(resp *http.Response) {
@@ -6,6 +6,7 @@ import (
 
 	"github.com/Silicon-Ally/etap2sf/etap/generated"
 	"github.com/Silicon-Ally/etap2sf/etap/generated/overrides"
+	"github.com/Silicon-Ally/etap2sf/logging"
 )
 
 func (c *Client) GetDefinedFields(start, count int) ([]*generated.DefinedField, error) {
@@ -23,12 +24,9 @@ func (c *Client) GetDefinedFields(start, count int) ([]*generated.DefinedField,
 		},
 	}
 	result := overrides.DefinedFieldsBody{}
-	if err := generated.RoundTripWithAction(c.ms, "GetDefinedFields", request, &result); err != nil {
+	if err := c.roundTrip("GetDefinedFields", request, &result); err != nil {
 		return nil, fmt.Errorf("client error: %v", err)
 	}
-	if c.err != nil {
-		return nil, fmt.Errorf("fault code error: %v", c.err)
-	}
 	return ptrs(result.M.Result.Data.Items), nil
 }
 
@@ -46,7 +44,7 @@ func (c *Client) GetAllDefinedFields() ([]*generated.DefinedField, error) {
 		if len(page) < count {
 			break
 		}
-		fmt.Printf("Successfully Processed %d DefinedFields\n", start)
+		logging.For("etap/client").Info("processed page", "records", "definedfields", "so_far", start)
 		time.Sleep(time.Second * 1)
 	}
 	return dfs, nil
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Silicon-Ally/etap2sf/etap/generated"
+	"github.com/Silicon-Ally/etap2sf/etap/generated/overrides"
+	"github.com/Silicon-Ally/etap2sf/logging"
+)
+
+// eTapestry query criteria take dates in US format.
+const queryDateFormat = "01/02/2006"
+
+// GetAccountsModifiedSince returns the accounts in the given (existing) query
+// where any of the given date fields (i.e. "Account Last Modified Date") is on
+// or after the given time.
+func (c *Client) GetAccountsModifiedSince(baseQuery string, dateFields []string, since time.Time, start int, count int) ([]*generated.Account, error) {
+	criteria := &generated.ArrayOfSearchCriteria{}
+	for _, field := range dateFields {
+		criteria.Items = append(criteria.Items, &generated.SearchCriteria{
+			Field:  ptr(field),
+			Action: ptr(">="),
+			Values: &generated.ArrayOfstring{Items: []string{since.Format(queryDateFormat)}},
+		})
+	}
+	request := struct {
+		M generated.OperationMessagingService_getDynamicQueryResults `xml:"tns:getDynamicQueryResults"`
+	}{
+		generated.OperationMessagingService_getDynamicQueryResults{
+			PagedDynamicQueryResultsRequest_1: &generated.PagedDynamicQueryResultsRequest{
+				ClearCache:     ptr(true),
+				Start:          ptr(start),
+				Count:          ptr(count),
+				BaseQuery:      ptr(baseQuery),
+				MatchAny:       ptr(true),
+				SearchCriteria: criteria,
+			},
+		},
+	}
+	result := overrides.DynamicQueryAccountBody{}
+	if err := c.roundTrip("GetDynamicQueryResults", request, &result); err != nil {
+		return nil, fmt.Errorf("client error: %v", err)
+	}
+	if result.M.Result == nil || result.M.Result.Data == nil {
+		return nil, nil
+	}
+	return ptrs(result.M.Result.Data.Items), nil
+}
+
+func (c *Client) GetAllAccountsModifiedSince(baseQuery string, dateFields []string, since time.Time) ([]*generated.Account, error) {
+	accounts := []*generated.Account{}
+	start := 0
+	count := 100
+	for {
+		page, err := c.GetAccountsModifiedSince(baseQuery, dateFields, since, start, count)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get modified accounts: %v", err)
+		}
+		accounts = append(accounts, page...)
+		start += count
+		if len(page) < count {
+			break
+		}
+		logging.For("etap/client").Info("processed page", "records", "modified_accounts", "so_far", start)
+		time.Sleep(time.Second * 5)
+	}
+	return accounts, nil
+}
@@ -16,11 +16,8 @@ func (c *Client) GetAllFunds() ([]*generated.Fund, error) {
 		},
 	}
 	result := overrides.FundObjectsBody{}
-	if err := generated.RoundTripWithAction(c.ms, "GetFunds", request, &result); err != nil {
+	if err := c.roundTrip("GetFunds", request, &result); err != nil {
 		return nil, fmt.Errorf("client error: %v", err)
 	}
-	if c.err != nil {
-		return nil, fmt.Errorf("fault code error: %v", c.err)
-	}
 	return result.M.Result.Items, nil
 }
@@ -3,10 +3,10 @@ package client
 import (
 	"encoding/xml"
 	"fmt"
-	"time"
 
 	"github.com/Silicon-Ally/etap2sf/etap/generated"
 	"github.com/Silicon-Ally/etap2sf/etap/generated/overrides"
+	"github.com/Silicon-Ally/etap2sf/logging"
 )
 
 func (c *Client) GetJournalEntries(account *generated.Account, start, count int) ([]*overrides.JournalEntry, error) {
@@ -34,12 +34,9 @@ func (c *Client) GetJournalEntries(account *generated.Account, start, count int)
 		XmlnsXSD:     "http://www.w3.org/2001/XMLSchema",
 	}
 	result := overrides.JournalEntryBody{}
-	if err := generated.RoundTripWithAction(c.ms, "GetJournalEntries", request, &result); err != nil {
+	if err := c.roundTrip("GetJournalEntries", request, &result); err != nil {
 		return nil, fmt.Errorf("client error: %v", err)
 	}
-	if c.err != nil {
-		return nil, fmt.Errorf("fault code error: %v", c.err)
-	}
 	return ptrs(result.M.Result.Data.Items), nil
 }
 
@@ -57,8 +54,7 @@ func (c *Client) GetAllJournalEntries(account *generated.Account) ([]*overrides.
 		if len(page) < count {
 			break
 		}
-		fmt.Printf("Successfully Processed %d Journal Entries\n", start)
-		time.Sleep(time.Second * 1)
+		logging.For("etap/client").Info("processed page", "records", "journal_entries", "so_far", start)
 	}
 	return jes, nil
 }
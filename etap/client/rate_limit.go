@@ -0,0 +1,28 @@
+package client
+
+import (
+	"sync"
+
+	"github.com/Silicon-Ally/etap2sf/config"
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultRequestsPerSecond = 2
+	defaultBurst             = 1
+)
+
+// limiter is shared by every client, so that running several sessions at once
+// doesn't get us throttled (or worse) by eTapestry.
+var limiter = sync.OnceValue(func() *rate.Limiter {
+	c := config.Get().ETapestry
+	rps := c.RequestsPerSecond
+	if rps <= 0 {
+		rps = defaultRequestsPerSecond
+	}
+	burst := c.Burst
+	if burst <= 0 {
+		burst = defaultBurst
+	}
+	return rate.NewLimiter(rate.Limit(rps), burst)
+})
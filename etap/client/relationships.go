@@ -6,6 +6,7 @@ import (
 
 	"github.com/Silicon-Ally/etap2sf/etap/generated"
 	"github.com/Silicon-Ally/etap2sf/etap/generated/overrides"
+	"github.com/Silicon-Ally/etap2sf/logging"
 )
 
 func (c *Client) GetRelationships(account *generated.Account, start, count int) ([]*generated.Relationship, error) {
@@ -22,12 +23,9 @@ func (c *Client) GetRelationships(account *generated.Account, start, count int)
 		},
 	}
 	result := overrides.RelationshipsBody{}
-	if err := generated.RoundTripWithAction(c.ms, "GetRelationships", request, &result); err != nil {
+	if err := c.roundTrip("GetRelationships", request, &result); err != nil {
 		return nil, fmt.Errorf("client error: %v", err)
 	}
-	if c.err != nil {
-		return nil, fmt.Errorf("fault code error: %v", c.err)
-	}
 	return ptrs(result.M.Result.Data.Items), nil
 }
 
@@ -45,7 +43,7 @@ func (c *Client) GetAllRelationships(account *generated.Account) ([]*generated.R
 		if len(page) < count {
 			break
 		}
-		fmt.Printf("Successfully Processed %d Relationships\n", start)
+		logging.For("etap/client").Info("processed page", "records", "relationships", "so_far", start)
 		time.Sleep(time.Second * 1)
 	}
 	return rs, nil
@@ -0,0 +1,179 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Silicon-Ally/etap2sf/config"
+	"github.com/Silicon-Ally/etap2sf/etap/generated"
+	"github.com/Silicon-Ally/etap2sf/logging"
+	"github.com/fiorix/wsdl2go/soap"
+)
+
+const (
+	defaultMaxRetries = 5
+	baseBackoff       = time.Second
+	maxBackoff        = time.Minute
+)
+
+// FaultKind is what went wrong with a call to eTapestry, which determines
+// whether (and how) it's worth trying again.
+type FaultKind string
+
+const (
+	FaultKind_SessionExpired FaultKind = "session expired"
+	FaultKind_Throttled      FaultKind = "throttled"
+	FaultKind_ServerError    FaultKind = "server error"
+	FaultKind_Network        FaultKind = "network"
+	FaultKind_BadRequest     FaultKind = "bad request"
+)
+
+func (k FaultKind) transient() bool {
+	return k != FaultKind_BadRequest
+}
+
+// These are matched against the (lowercased) fault strings that eTapestry
+// returns, since it doesn't give us structured fault codes. They're kept
+// specific, since i.e. "maximum length exceeded" is a bad request, not a limit.
+var (
+	sessionExpiredFaults = []string{"session has expired", "session expired", "not logged in", "invalid session", "must login", "must log in"}
+	throttledFaults      = []string{"too many requests", "rate limit", "request limit", "try again later", "usage limit", "limit exceeded", "limit has been exceeded", "quota exceeded"}
+	serverFaults         = []string{"internal error", "internal server error", "service unavailable", "temporarily unavailable", "timed out", "timeout"}
+)
+
+type retryPolicy struct {
+	maxRetries int
+	base       time.Duration
+	max        time.Duration
+}
+
+func retryPolicyFromConfig() *retryPolicy {
+	n := config.Get().ETapestry.MaxRetries
+	if n <= 0 {
+		n = defaultMaxRetries
+	}
+	return &retryPolicy{maxRetries: n, base: baseBackoff, max: maxBackoff}
+}
+
+// backoff is the (exponential, with full jitter) delay before the given retry.
+func (p *retryPolicy) backoff(attempt int) time.Duration {
+	d := p.base << attempt
+	if d <= 0 || d > p.max {
+		d = p.max
+	}
+	return time.Duration(rand.Int63n(int64(d))) + p.base
+}
+
+// classifyError works out what kind of failure an error from a call is.
+func classifyError(err error) FaultKind {
+	var fe *FaultError
+	if errors.As(err, &fe) {
+		return classifyFault(fe.Fault)
+	}
+	var he *soap.HTTPError
+	if errors.As(err, &he) {
+		switch {
+		case he.StatusCode == http.StatusUnauthorized:
+			return FaultKind_SessionExpired
+		case he.StatusCode == http.StatusTooManyRequests:
+			return FaultKind_Throttled
+		case he.StatusCode >= 500:
+			if k := classifyFault(he.Msg); k != FaultKind_BadRequest {
+				return k
+			}
+			return FaultKind_ServerError
+		default:
+			return FaultKind_BadRequest
+		}
+	}
+	var ne net.Error
+	if errors.As(err, &ne) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) {
+		return FaultKind_Network
+	}
+	return FaultKind_BadRequest
+}
+
+func classifyFault(fault string) FaultKind {
+	f := strings.ToLower(fault)
+	for _, kind := range []struct {
+		kind    FaultKind
+		matches []string
+	}{
+		{FaultKind_SessionExpired, sessionExpiredFaults},
+		{FaultKind_Throttled, throttledFaults},
+		{FaultKind_ServerError, serverFaults},
+	} {
+		for _, m := range kind.matches {
+			if strings.Contains(f, m) {
+				return kind.kind
+			}
+		}
+	}
+	return FaultKind_BadRequest
+}
+
+// roundTrip makes a call to eTapestry, retrying with backoff if it fails in a
+// way that might succeed next time, and logging in again if the session has
+// expired (which happens during long exports).
+func (c *Client) roundTrip(action string, request, response soap.Message) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		c.err = nil
+		resetMessage(response)
+		err = generated.RoundTripWithAction(c.ms, action, request, response)
+		if err == nil {
+			err = c.err
+		}
+		if err == nil {
+			return nil
+		}
+		kind := classifyError(err)
+		if !kind.transient() {
+			return err
+		}
+		if attempt >= c.retry.maxRetries {
+			return fmt.Errorf("giving up on %s after %d attempts (%s): %w", action, attempt+1, kind, err)
+		}
+		if kind == FaultKind_SessionExpired {
+			logging.For("etap/client").Warn("session expired, logging in again", "action", action)
+			if err := c.relogin(); err != nil {
+				return fmt.Errorf("logging in again after session expired: %w", err)
+			}
+			continue
+		}
+		wait := c.retry.backoff(attempt)
+		logging.For("etap/client").Warn("call failed, retrying", "action", action, "kind", string(kind), "attempt", attempt+1, "wait", wait.Round(time.Millisecond).String(), "error", err)
+		time.Sleep(wait)
+	}
+}
+
+func (c *Client) relogin() error {
+	_, cookies, err := login(c.dbName, c.secretAPIKey)
+	if err != nil {
+		return err
+	}
+	c.cookies = cookies
+	return nil
+}
+
+// resetMessage zeroes out a response before it's decoded into again, since
+// decoding appends to any slices that are already populated.
+func resetMessage(m soap.Message) {
+	v := reflect.ValueOf(m)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return
+	}
+	v.Elem().Set(reflect.Zero(v.Elem().Type()))
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/fiorix/wsdl2go/soap"
+)
+
+func TestClassifyFault(t *testing.T) {
+	type test struct {
+		fault string
+		want  FaultKind
+	}
+	tests := []test{
+		{"Your session has expired, please log in again", FaultKind_SessionExpired},
+		{"Must Login first", FaultKind_SessionExpired},
+		{"API usage limit exceeded for today", FaultKind_Throttled},
+		{"Too Many Requests", FaultKind_Throttled},
+		{"Internal Server Error", FaultKind_ServerError},
+		{"The request timed out", FaultKind_ServerError},
+		// Exceeding a field's length isn't a usage limit.
+		{"Maximum length exceeded for field Note", FaultKind_BadRequest},
+		{"Invalid ref: 1.0.1", FaultKind_BadRequest},
+		{"", FaultKind_BadRequest},
+	}
+	// Every listed fault is matched, whatever its case or surrounding text.
+	for _, list := range []struct {
+		faults []string
+		kind   FaultKind
+	}{
+		{sessionExpiredFaults, FaultKind_SessionExpired},
+		{throttledFaults, FaultKind_Throttled},
+		{serverFaults, FaultKind_ServerError},
+	} {
+		for _, f := range list.faults {
+			tests = append(tests, test{"Error: " + strings.ToUpper(f) + ".", list.kind})
+		}
+	}
+	for _, test := range tests {
+		if got := classifyFault(test.fault); got != test.want {
+			t.Errorf("classifyFault(%q) = %q, want %q", test.fault, got, test.want)
+		}
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want FaultKind
+	}{
+		{"fault", fmt.Errorf("calling getAccount: %w", &FaultError{Fault: "Session has expired"}), FaultKind_SessionExpired},
+		{"bad request fault", &FaultError{Fault: "Maximum length exceeded"}, FaultKind_BadRequest},
+		{"unauthorized", &soap.HTTPError{StatusCode: http.StatusUnauthorized}, FaultKind_SessionExpired},
+		{"too many requests", &soap.HTTPError{StatusCode: http.StatusTooManyRequests}, FaultKind_Throttled},
+		{"server error", &soap.HTTPError{StatusCode: http.StatusBadGateway, Msg: "bad gateway"}, FaultKind_ServerError},
+		{"server error with a fault", &soap.HTTPError{StatusCode: http.StatusInternalServerError, Msg: "Rate limit reached"}, FaultKind_Throttled},
+		{"client error", &soap.HTTPError{StatusCode: http.StatusBadRequest, Msg: "timed out"}, FaultKind_BadRequest},
+		{"net error", &net.OpError{Op: "dial", Err: errors.New("no route to host")}, FaultKind_Network},
+		{"eof", fmt.Errorf("reading response: %w", io.ErrUnexpectedEOF), FaultKind_Network},
+		{"connection reset", fmt.Errorf("writing request: %w", syscall.ECONNRESET), FaultKind_Network},
+		{"other", errors.New("unmarshalling response"), FaultKind_BadRequest},
+	}
+	for _, test := range tests {
+		if got := classifyError(test.err); got != test.want {
+			t.Errorf("%s: classifyError(%v) = %q, want %q", test.name, test.err, got, test.want)
+		}
+		if got, want := classifyError(test.err).transient(), test.want != FaultKind_BadRequest; got != want {
+			t.Errorf("%s: transient = %t, want %t", test.name, got, want)
+		}
+	}
+}
@@ -110,6 +110,15 @@ func addRequiredSOAPEncodingStyle(req *http.Request) error {
 	return nil
 }
 
+// FaultError is a SOAP fault returned by eTapestry.
+type FaultError struct {
+	Fault string
+}
+
+func (e *FaultError) Error() string {
+	return fmt.Sprintf("fault code found: %q uncomment above to print detailed response", e.Fault)
+}
+
 func checkForFaultCode(resp *http.Response) error {
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -131,7 +140,7 @@ func checkForFaultCode(resp *http.Response) error {
 		 		panic(err)
 		}
 		*/
-		return fmt.Errorf("fault code found: %q uncomment above to print detailed response", body[start:end])
+		return &FaultError{Fault: string(body[start:end])}
 	}
 	/* If you're running into issues, uncomment the following lines to print the response
 
@@ -0,0 +1,30 @@
+package trace
+
+import (
+	"net/http"
+	"regexp"
+)
+
+const redacted = "REDACTED"
+
+var sessionRegexp = regexp.MustCompile(`(?i)(JSESSIONID=)[^;,\s]*`)
+
+// redact returns a copy of the exchange without session cookies. Logging in
+// (with the API key) doesn't go through the tracer, so there's no key to hide.
+func redact(e *Exchange) *Exchange {
+	out := *e
+	out.RequestHeaders = redactHeaders(e.RequestHeaders)
+	out.ResponseHeaders = redactHeaders(e.ResponseHeaders)
+	return &out
+}
+
+func redactHeaders(h http.Header) http.Header {
+	out := h.Clone()
+	for _, k := range []string{"Cookie", "Set-Cookie"} {
+		vs := out.Values(k)
+		for i, v := range vs {
+			vs[i] = sessionRegexp.ReplaceAllString(v, "${1}"+redacted)
+		}
+	}
+	return out
+}
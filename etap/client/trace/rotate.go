@@ -0,0 +1,135 @@
+package trace
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// rotatingWriter appends to a log file, moving it aside once it reaches the
+// max size and keeping only the most recent few. With compression on, each
+// file is gzipped, and is flushed after every write so that it can be read
+// while it's still open. Sizes are always bytes on disk, which with
+// compression on are only known once the data has been written, so a
+// compressed file is rotated once it reaches the max size rather than before.
+type rotatingWriter struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+	compress bool
+
+	f  *os.File
+	gz *gzip.Writer
+	w  io.Writer
+	// size is the number of bytes in the file on disk.
+	size int64
+}
+
+// countingWriter counts the bytes written to the file, after compression.
+type countingWriter struct {
+	w io.Writer
+	n *int64
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	*c.n += int64(n)
+	return n, err
+}
+
+func (r *rotatingWriter) fileName(n int) string {
+	name := r.path
+	if n > 0 {
+		ext := filepath.Ext(name)
+		name = fmt.Sprintf("%s.%d%s", strings.TrimSuffix(name, ext), n, ext)
+	}
+	if r.compress {
+		name += ".gz"
+	}
+	return name
+}
+
+func (r *rotatingWriter) write(data []byte) error {
+	if r.w == nil {
+		if err := r.open(); err != nil {
+			return err
+		}
+	}
+	next := r.size
+	if !r.compress {
+		next += int64(len(data))
+	}
+	if r.size > 0 && (next > r.maxSize || r.size >= r.maxSize) {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	if _, err := r.w.Write(data); err != nil {
+		return fmt.Errorf("writing to %s: %w", r.fileName(0), err)
+	}
+	if r.gz != nil {
+		if err := r.gz.Flush(); err != nil {
+			return fmt.Errorf("flushing %s: %w", r.fileName(0), err)
+		}
+	}
+	return nil
+}
+
+func (r *rotatingWriter) open() error {
+	name := r.fileName(0)
+	if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+		return fmt.Errorf("creating directory for %s: %w", name, err)
+	}
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", name, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("getting size of %s: %w", name, err)
+	}
+	r.f, r.size = f, info.Size()
+	r.w = countingWriter{w: f, n: &r.size}
+	if r.compress {
+		// Appending a new gzip member to an existing file is still a valid gzip file.
+		r.gz = gzip.NewWriter(r.w)
+		r.w = r.gz
+	}
+	return nil
+}
+
+func (r *rotatingWriter) close() error {
+	if r.gz != nil {
+		if err := r.gz.Close(); err != nil {
+			return fmt.Errorf("closing gzip writer: %w", err)
+		}
+		r.gz = nil
+	}
+	if r.f != nil {
+		if err := r.f.Close(); err != nil {
+			return fmt.Errorf("closing %s: %w", r.fileName(0), err)
+		}
+		r.f = nil
+	}
+	r.w = nil
+	return nil
+}
+
+func (r *rotatingWriter) rotate() error {
+	if err := r.close(); err != nil {
+		return err
+	}
+	if err := os.Remove(r.fileName(r.maxFiles - 1)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing oldest trace log: %w", err)
+	}
+	for n := r.maxFiles - 2; n >= 0; n-- {
+		if err := os.Rename(r.fileName(n), r.fileName(n+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("rotating trace log: %w", err)
+		}
+	}
+	return r.open()
+}
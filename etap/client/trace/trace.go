@@ -0,0 +1,161 @@
+// Package trace records the SOAP requests and responses exchanged with
+// eTapestry, for debugging. Since these contain donor PII, nothing is
+// recorded unless it's turned on in the config file, and what is recorded goes
+// to a single rotating log in the project's data/ directory, rather than to a
+// temp file per request.
+package trace
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Silicon-Ally/etap2sf/config"
+	"github.com/Silicon-Ally/etap2sf/logging"
+	"github.com/Silicon-Ally/etap2sf/utils"
+)
+
+type Mode string
+
+const (
+	Mode_Off Mode = "off"
+	// Mode_Errors only records exchanges where the call failed.
+	Mode_Errors Mode = "errors"
+	// Mode_Sampled records every failed exchange, and a fraction of the rest.
+	Mode_Sampled Mode = "sampled"
+	Mode_Full    Mode = "full"
+)
+
+const (
+	defaultSampleRate = 0.01
+	defaultMaxSizeMB  = 100
+	defaultMaxFiles   = 5
+	logFileName       = "etap-trace.log"
+)
+
+// Exchange is a single request to eTapestry and its response.
+type Exchange struct {
+	Time            time.Time
+	Path            string
+	RequestHeaders  http.Header
+	Request         []byte
+	StatusCode      int
+	ResponseHeaders http.Header
+	Response        []byte
+	// Err is set if the call failed, i.e. with a SOAP fault.
+	Err error
+}
+
+// Tracer is given every exchange with eTapestry, and decides whether and how to record it.
+type Tracer interface {
+	Trace(e *Exchange)
+}
+
+// Default is the tracer described by the config file, shared by every client.
+var Default = sync.OnceValue(func() Tracer {
+	t, err := New(&config.Get().ETapestry.Trace)
+	if err != nil {
+		log.Fatalf("setting up eTapestry tracing from %s: %v", config.Path(), err)
+	}
+	return t
+})
+
+// New creates a tracer with the given settings.
+func New(c *config.Trace) (Tracer, error) {
+	mode := Mode(c.Mode)
+	switch mode {
+	case "", Mode_Off:
+		return nop{}, nil
+	case Mode_Errors, Mode_Sampled, Mode_Full:
+	default:
+		return nil, fmt.Errorf("unknown trace mode %q, expected %q, %q, %q or %q", c.Mode, Mode_Off, Mode_Errors, Mode_Sampled, Mode_Full)
+	}
+	rate := c.SampleRate
+	if rate <= 0 {
+		rate = defaultSampleRate
+	}
+	if rate > 1 {
+		return nil, fmt.Errorf("sample rate must be at most 1, got %f", rate)
+	}
+	maxSize := c.MaxSizeMB
+	if maxSize <= 0 {
+		maxSize = defaultMaxSizeMB
+	}
+	maxFiles := c.MaxFiles
+	if maxFiles <= 0 {
+		maxFiles = defaultMaxFiles
+	}
+	redact := c.Redact == nil || *c.Redact
+	w := &rotatingWriter{
+		path:     filepath.Join(utils.ProjectRoot(), "data", logFileName),
+		maxSize:  int64(maxSize) << 20,
+		maxFiles: maxFiles,
+		compress: c.Compress,
+	}
+	return &logTracer{mode: mode, sampleRate: rate, redact: redact, w: w}, nil
+}
+
+type nop struct{}
+
+func (nop) Trace(*Exchange) {}
+
+type logTracer struct {
+	mode       Mode
+	sampleRate float64
+	redact     bool
+
+	mu sync.Mutex
+	w  *rotatingWriter
+}
+
+func (t *logTracer) Trace(e *Exchange) {
+	if !t.shouldTrace(e) {
+		return
+	}
+	if t.redact {
+		e = redact(e)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.w.write(format(e)); err != nil {
+		logging.For("etap/client/trace").Error("failed to write trace", "error", err)
+	}
+}
+
+func (t *logTracer) shouldTrace(e *Exchange) bool {
+	failed := e.Err != nil || e.StatusCode >= 400
+	switch t.mode {
+	case Mode_Full:
+		return true
+	case Mode_Errors:
+		return failed
+	case Mode_Sampled:
+		return failed || rand.Float64() < t.sampleRate
+	}
+	return false
+}
+
+func format(e *Exchange) []byte {
+	status := "ok"
+	if e.Err != nil {
+		status = fmt.Sprintf("error: %v", e.Err)
+	}
+	out := fmt.Sprintf("=== %s %s (%d, %s)\n--- request\n", e.Time.Format(time.RFC3339Nano), e.Path, e.StatusCode, status)
+	out += formatHeaders(e.RequestHeaders) + string(e.Request)
+	out += "\n--- response\n" + formatHeaders(e.ResponseHeaders) + string(e.Response) + "\n\n"
+	return []byte(out)
+}
+
+func formatHeaders(h http.Header) string {
+	out := ""
+	for _, k := range []string{"Cookie", "Set-Cookie", "Soapaction", "Content-Type"} {
+		for _, v := range h.Values(k) {
+			out += fmt.Sprintf("%s: %s\n", k, v)
+		}
+	}
+	return out
+}
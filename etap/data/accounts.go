@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/Silicon-Ally/etap2sf/config"
 	"github.com/Silicon-Ally/etap2sf/etap/client"
 	"github.com/Silicon-Ally/etap2sf/etap/generated"
 	"github.com/Silicon-Ally/etap2sf/utils"
@@ -28,25 +29,34 @@ func GetAccounts() ([]*generated.Account, error) {
 }
 
 // In order for this to work, you'll need to first create a query for all accounts you want.
+// The query is set with `etapestry.accounts_query` in the config file, this is the default.
 const GetAllAccountsQuery = "Folder::Query"
 const originalQuery = "Folder::Query"
 
+func getAllAccountsQuery() string {
+	if q := config.Get().ETapestry.AccountsQuery; q != "" {
+		return q
+	}
+	return GetAllAccountsQuery
+}
+
 func doGetAccountData() ([]byte, error) {
-	if GetAllAccountsQuery == originalQuery {
+	query := getAllAccountsQuery()
+	if query == originalQuery {
 		return nil, fmt.Errorf(`
 
 This step likely failed because there isn't a query in eTapestry to export all of your accounts.
 
 To do this, create a new report query in eTapestry which exports all of your accounts (only the ID is needed).
 
-Then, update the GetAllAccountsQuery constant in etap/data/accounts.go to use the name of your new query, noting that the folder will need to be specified before the ::.
+Then, set etapestry.accounts_query in your config file (see etap2sf.example.yaml) to the name of your new query, noting that the folder will need to be specified before the ::.
 
 If you have any problems with this, please file a bug.
 
 		`)
 	}
 	return client.WithClient(func(c *client.Client) ([]byte, error) {
-		accounts, err := c.GetAllAccounts(GetAllAccountsQuery)
+		accounts, err := c.GetAllAccounts(query)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get accounts: %v", err)
 		}
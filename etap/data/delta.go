@@ -0,0 +1,247 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Silicon-Ally/etap2sf/config"
+	"github.com/Silicon-Ally/etap2sf/etap/client"
+	"github.com/Silicon-Ally/etap2sf/etap/generated"
+	"github.com/Silicon-Ally/etap2sf/etap/generated/overrides"
+	"github.com/Silicon-Ally/etap2sf/logging"
+	"github.com/Silicon-Ally/etap2sf/utils"
+)
+
+var defaultModifiedDateFields = []string{
+	"Account Last Modified Date",
+	"Journal Entry Last Modified Date",
+}
+
+const deltaStateFile = "etap-delta-state.json"
+
+// Changes are the refs of records that a delta export added, changed or removed.
+type Changes struct {
+	Added   []string
+	Changed []string
+	Removed []string
+}
+
+// Delta is the record of what a delta export changed in the cached data.
+type Delta struct {
+	Since          time.Time
+	Until          time.Time
+	Accounts       Changes
+	JournalEntries Changes
+	Relationships  Changes
+}
+
+type deltaState struct {
+	LastExport time.Time
+}
+
+// ExportDelta refreshes the cached eTapestry data with only the accounts that
+// were modified (or whose journal entries were modified) since the last export,
+// rather than re-downloading everything. Accounts that were deleted in
+// eTapestry can't be found this way, and remain in the cached data.
+func ExportDelta() (*Delta, error) {
+	existing, err := GetAccounts()
+	if err != nil {
+		return nil, fmt.Errorf("getting previously exported accounts: %w", err)
+	}
+	since, err := lastExportTime()
+	if err != nil {
+		return nil, err
+	}
+	// Query criteria only have the granularity of a day, so we overlap by one to be safe.
+	since = since.Add(-24 * time.Hour).Truncate(24 * time.Hour)
+	delta := &Delta{Since: since, Until: time.Now()}
+
+	fields := config.Get().ETapestry.ModifiedDateFields
+	if len(fields) == 0 {
+		fields = defaultModifiedDateFields
+	}
+
+	accountsByRef := map[string]*generated.Account{}
+	for _, a := range existing {
+		accountsByRef[*a.Ref] = a
+	}
+	_, err = client.WithClient(func(c *client.Client) ([]byte, error) {
+		modified, err := c.GetAllAccountsModifiedSince(getAllAccountsQuery(), fields, since)
+		if err != nil {
+			return nil, fmt.Errorf("getting modified accounts: %w", err)
+		}
+		logging.For("etap/data").Info("found modified accounts", "count", len(modified), "since", since.Format("2006-01-02"))
+		progress := logging.NewProgress("delta export", "", len(modified))
+		defer progress.Done()
+		for _, account := range modified {
+			if account.Ref == nil || *account.Ref == "" {
+				return nil, fmt.Errorf("modified account has no ref: %+v", account)
+			}
+			ref := *account.Ref
+			if old, ok := accountsByRef[ref]; !ok {
+				delta.Accounts.Added = append(delta.Accounts.Added, ref)
+				existing = append(existing, account)
+			} else {
+				if !jsonEqual(old, account) {
+					delta.Accounts.Changed = append(delta.Accounts.Changed, ref)
+				}
+				*old = *account
+			}
+			accountsByRef[ref] = account
+
+			jes, err := c.GetAllJournalEntries(account)
+			if err != nil {
+				return nil, fmt.Errorf("getting journal entries for account %s: %w", ref, err)
+			}
+			if err := mergeDelta(fmt.Sprintf("journal-entries/%s.json", ref), jes, (*overrides.JournalEntry).Ref, &delta.JournalEntries); err != nil {
+				return nil, fmt.Errorf("merging journal entries for account %s: %w", ref, err)
+			}
+			rs, err := c.GetAllRelationships(account)
+			if err != nil {
+				return nil, fmt.Errorf("getting relationships for account %s: %w", ref, err)
+			}
+			if err := mergeDelta(fmt.Sprintf("relationships/%s.json", ref), rs, relationshipRef, &delta.Relationships); err != nil {
+				return nil, fmt.Errorf("merging relationships for account %s: %w", ref, err)
+			}
+			progress.Succeeded()
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(existing, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshalling accounts: %w", err)
+	}
+	if err := utils.OverwriteMemoization("etap-accounts.json", data); err != nil {
+		return nil, err
+	}
+	// The combined files are rebuilt from the per-account files, which are
+	// all cached, so this doesn't hit eTapestry again.
+	accounts, journalEntries, relationships = nil, nil, nil
+	if err := utils.ClearMemoization("etap-journal-entries.json"); err != nil {
+		return nil, err
+	}
+	if err := utils.ClearMemoization("etap-relationships.json"); err != nil {
+		return nil, err
+	}
+	if _, err := GetJournalEntries(); err != nil {
+		return nil, fmt.Errorf("rebuilding journal entries: %w", err)
+	}
+	if _, err := GetRelationships(); err != nil {
+		return nil, fmt.Errorf("rebuilding relationships: %w", err)
+	}
+
+	data, err = json.MarshalIndent(delta, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshalling delta: %w", err)
+	}
+	if err := utils.OverwriteMemoization(fmt.Sprintf("etap-deltas/%s.json", delta.Until.Format("2006-01-02T150405")), data); err != nil {
+		return nil, err
+	}
+	data, err = json.MarshalIndent(&deltaState{LastExport: delta.Until}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshalling delta state: %w", err)
+	}
+	if err := utils.OverwriteMemoization(deltaStateFile, data); err != nil {
+		return nil, err
+	}
+	return delta, nil
+}
+
+// lastExportTime is when the last delta export ran, or if there hasn't been
+// one, when the accounts were first exported.
+func lastExportTime() (time.Time, error) {
+	dataDir := filepath.Join(utils.ProjectRoot(), "data")
+	data, err := os.ReadFile(filepath.Join(dataDir, deltaStateFile))
+	if err == nil {
+		s := &deltaState{}
+		if err := json.Unmarshal(data, s); err != nil {
+			return time.Time{}, fmt.Errorf("unmarshalling delta state: %w", err)
+		}
+		return s.LastExport, nil
+	} else if !os.IsNotExist(err) {
+		return time.Time{}, fmt.Errorf("reading delta state: %w", err)
+	}
+	info, err := os.Stat(filepath.Join(dataDir, "etap-accounts.json"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("finding time of the last full export: %w", err)
+	}
+	return info.ModTime(), nil
+}
+
+// mergeDelta replaces the records in a cached per-account file with freshly
+// downloaded ones, recording which of them were added, changed or removed.
+func mergeDelta[T any](fileName string, fresh []*T, refFn func(*T) string, changes *Changes) error {
+	old := []*T{}
+	data, err := os.ReadFile(filepath.Join(utils.ProjectRoot(), "data", fileName))
+	if err == nil {
+		if err := json.Unmarshal(data, &old); err != nil {
+			return fmt.Errorf("unmarshalling %s: %w", fileName, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("reading %s: %w", fileName, err)
+	}
+	oldByRef := map[string]*T{}
+	for _, t := range old {
+		oldByRef[refFn(t)] = t
+	}
+	for _, t := range fresh {
+		ref := refFn(t)
+		if ref == "" {
+			continue
+		}
+		if o, ok := oldByRef[ref]; !ok {
+			changes.Added = append(changes.Added, ref)
+		} else if !jsonEqual(o, t) {
+			changes.Changed = append(changes.Changed, ref)
+		}
+		delete(oldByRef, ref)
+	}
+	removed := []string{}
+	for ref := range oldByRef {
+		if ref != "" {
+			removed = append(removed, ref)
+		}
+	}
+	sort.Strings(removed)
+	changes.Removed = append(changes.Removed, removed...)
+
+	data, err = json.MarshalIndent(fresh, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling %s: %w", fileName, err)
+	}
+	return utils.OverwriteMemoization(fileName, data)
+}
+
+func relationshipRef(r *generated.Relationship) string {
+	if r.Ref == nil {
+		return ""
+	}
+	return *r.Ref
+}
+
+func jsonEqual(a, b any) bool {
+	aData, aErr := json.Marshal(a)
+	bData, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && string(aData) == string(bData)
+}
+
+// Summary is a human readable version of the delta.
+func (d *Delta) Summary() string {
+	return fmt.Sprintf(`Delta export complete (changes since %s).
+
+Accounts:        %d added, %d changed
+Journal Entries: %d added, %d changed, %d removed
+Relationships:   %d added, %d changed, %d removed
+`, d.Since.Format("2006-01-02"),
+		len(d.Accounts.Added), len(d.Accounts.Changed),
+		len(d.JournalEntries.Added), len(d.JournalEntries.Changed), len(d.JournalEntries.Removed),
+		len(d.Relationships.Added), len(d.Relationships.Changed), len(d.Relationships.Removed))
+}
@@ -3,11 +3,13 @@ package data
 import (
 	"encoding/json"
 	"fmt"
-	"time"
+	"sync"
 
+	"github.com/Silicon-Ally/etap2sf/config"
 	"github.com/Silicon-Ally/etap2sf/etap/client"
 	"github.com/Silicon-Ally/etap2sf/etap/generated"
 	"github.com/Silicon-Ally/etap2sf/etap/generated/overrides"
+	"github.com/Silicon-Ally/etap2sf/logging"
 	"github.com/Silicon-Ally/etap2sf/utils"
 )
 
@@ -40,61 +42,116 @@ func GetJournalEntries() ([]*overrides.JournalEntry, error) {
 	return result, nil
 }
 
+const defaultExportWorkers = 4
+
 func doGetJournalEntryData() ([]byte, error) {
 	accounts, err := GetAccounts()
 	if err != nil {
 		return nil, fmt.Errorf("getting accounts: %w", err)
 	}
+	numWorkers := config.Get().ETapestry.ExportWorkers
+	if numWorkers <= 0 {
+		numWorkers = defaultExportWorkers
+	}
+	if numWorkers > len(accounts) {
+		numWorkers = len(accounts)
+	}
 
-	return client.WithClient(func(c *client.Client) ([]byte, error) {
-		getJournalEntries := func(account *generated.Account) ([]*overrides.JournalEntry, error) {
-			jeData, err := utils.MemoizeOperation(fmt.Sprintf("journal-entries/%s.json", *account.Ref), func() ([]byte, error) {
-				journalEntries, err := c.GetAllJournalEntries(account)
-				if err != nil {
-					return nil, fmt.Errorf("getting journal entries: %w", err)
-				}
-				data, err := json.MarshalIndent(journalEntries, "", "  ")
-				if err != nil {
-					return nil, fmt.Errorf("marshaling journal entries: %w", err)
+	// Each worker has its own session, and the rate of requests across all of
+	// them is limited in the client. Since the journal entries for each account
+	// are memoized, an interrupted export picks up where it left off.
+	perAccount := make([][]*overrides.JournalEntry, len(accounts))
+	indexes := make(chan int)
+	var (
+		mu       sync.Mutex
+		firstErr error
+	)
+	progress := logging.NewProgress("export journal entries", "", len(accounts))
+	defer progress.Done()
+	wg := sync.WaitGroup{}
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.WithClient(func(c *client.Client) ([]byte, error) {
+				for i := range indexes {
+					account := accounts[i]
+					jes, err := getJournalEntriesForAccount(c, account)
+					if err != nil {
+						return nil, fmt.Errorf("error getting journal entries for account %s: %v", *account.Ref, err)
+					}
+					perAccount[i] = jes
+					progress.Succeeded()
 				}
-				// Prevents us from destroying the server
-				time.Sleep(500 * time.Millisecond)
-				return data, nil
+				return nil, nil
 			})
 			if err != nil {
-				return nil, fmt.Errorf("failed to get journal entries: %v", err)
-			}
-			var journalEntries []*overrides.JournalEntry
-			err = json.Unmarshal(jeData, &journalEntries)
-			if err != nil {
-				return nil, fmt.Errorf("failed to unmarshal journal entries: %v", err)
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				// Keep draining so the other workers (and the producer) aren't blocked.
+				for range indexes {
+				}
 			}
-			return journalEntries, nil
+		}()
+	}
+	for i := range accounts {
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			break
 		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
 
-		jes := []*overrides.JournalEntry{}
-		for i, account := range accounts {
-			journalEntries, err := getJournalEntries(account)
-			if err != nil {
-				return nil, fmt.Errorf("error getting journal entries for account %s: %v", *account.Ref, err)
+	jes := []*overrides.JournalEntry{}
+	for i, journalEntries := range perAccount {
+		for _, je := range journalEntries {
+			if !je.Empty && je.Ref() == "" {
+				return nil, fmt.Errorf("early - journal entry for account %s has no ref: %+v", *accounts[i].Ref, je)
 			}
-			for _, je := range journalEntries {
-				if !je.Empty && je.Ref() == "" {
-					return nil, fmt.Errorf("early - journal entry for account %s has no ref: %+v", *account.Ref, je)
-				}
-				if !je.Empty {
-					jes = append(jes, je)
-				}
+			if !je.Empty {
+				jes = append(jes, je)
 			}
-			fmt.Printf("Account %d/%d: %s\n", i+1, len(accounts), *account.Name)
 		}
+	}
+
+	result, err := json.MarshalIndent(jes, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal journal entries: %v", err)
+	}
+	return result, nil
+}
 
-		result, err := json.MarshalIndent(jes, "", "  ")
+func getJournalEntriesForAccount(c *client.Client, account *generated.Account) ([]*overrides.JournalEntry, error) {
+	jeData, err := utils.MemoizeOperation(fmt.Sprintf("journal-entries/%s.json", *account.Ref), func() ([]byte, error) {
+		journalEntries, err := c.GetAllJournalEntries(account)
 		if err != nil {
-			return nil, fmt.Errorf("failed to marshal journal entries: %v", err)
+			return nil, fmt.Errorf("getting journal entries: %w", err)
 		}
-		return result, nil
+		data, err := json.MarshalIndent(journalEntries, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("marshaling journal entries: %w", err)
+		}
+		return data, nil
 	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get journal entries: %v", err)
+	}
+	var journalEntries []*overrides.JournalEntry
+	err = json.Unmarshal(jeData, &journalEntries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal journal entries: %v", err)
+	}
+	return journalEntries, nil
 }
 
 func GetContacts() ([]*generated.Contact, error) {
@@ -8,6 +8,7 @@ import (
 
 	"github.com/Silicon-Ally/etap2sf/etap/client"
 	"github.com/Silicon-Ally/etap2sf/etap/generated"
+	"github.com/Silicon-Ally/etap2sf/logging"
 	"github.com/Silicon-Ally/etap2sf/utils"
 	"github.com/google/go-cmp/cmp"
 )
@@ -84,14 +85,15 @@ func doGetRelationshipData() ([]byte, error) {
 			return rs, nil
 		}
 
+		progress := logging.NewProgress("export relationships", "", len(accounts))
 		rss := []*generated.Relationship{}
-		for i, account := range accounts {
+		for _, account := range accounts {
 			rs, err := getRelationships(account)
 			if err != nil {
 				return nil, fmt.Errorf("getting relationships: %w", err)
 			}
 			rss = append(rss, rs...)
-			fmt.Printf("Relationships for account %d/%d: %s: #=%d\n", i+1, len(accounts), *account.Name, len(rs))
+			progress.Succeeded()
 		}
 
 		result, err := json.MarshalIndent(rss, "", "  ")
@@ -99,7 +101,7 @@ func doGetRelationshipData() ([]byte, error) {
 			return nil, fmt.Errorf("failed to marshal relationships: %v", err)
 		}
 
-		fmt.Printf("Completed downloading relationship data!\n")
+		progress.Done()
 		return result, nil
 	})
 }
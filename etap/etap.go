@@ -28,6 +28,26 @@ const (
 	ObjectType_SoftCredit            ObjectType = "SoftCredit"
 )
 
+var ObjectTypes = []ObjectType{
+	ObjectType_Account,
+	ObjectType_Approach,
+	ObjectType_Attachment,
+	ObjectType_Campaign,
+	ObjectType_Contact,
+	ObjectType_Disbursement,
+	ObjectType_Fund,
+	ObjectType_Gift,
+	ObjectType_Note,
+	ObjectType_Payment,
+	ObjectType_Pledge,
+	ObjectType_Purchase,
+	ObjectType_RecurringGift,
+	ObjectType_RecurringGiftSchedule,
+	ObjectType_Relationship,
+	ObjectType_SegmentedDonation,
+	ObjectType_SoftCredit,
+}
+
 func (o ObjectType) String() string {
 	return string(o)
 }
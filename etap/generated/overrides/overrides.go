@@ -32,6 +32,14 @@ type AccountBody struct {
 	M OperationMessagingService_getExistingQueryResultsResponse[generated.Account] `xml:"getExistingQueryResultsResponse"`
 }
 
+type OperationMessagingService_getDynamicQueryResultsResponse[T any] struct {
+	Result *PagedQueryResultsResponse[T] `xml:"result,omitempty" json:"result,omitempty" yaml:"result,omitempty"`
+}
+
+type DynamicQueryAccountBody struct {
+	M OperationMessagingService_getDynamicQueryResultsResponse[generated.Account] `xml:"getDynamicQueryResultsResponse"`
+}
+
 type JournalEntry struct {
 	Empty bool
 	// Used In Our Data
@@ -169,6 +177,47 @@ func (j *JournalEntry) AccountRef() string {
 	return ""
 }
 
+// Date is the date that the journal entry is for, like the date of a gift.
+func (j *JournalEntry) Date() *generated.DateTime {
+	if j.Note != nil && j.Note.Date != nil {
+		return j.Note.Date
+	}
+	if j.Contact != nil && j.Contact.Date != nil {
+		return j.Contact.Date
+	}
+	if j.Declaration != nil && j.Declaration.Date != nil {
+		return j.Declaration.Date
+	}
+	if j.Gift != nil && j.Gift.Date != nil {
+		return j.Gift.Date
+	}
+	if j.Pledge != nil && j.Pledge.Date != nil {
+		return j.Pledge.Date
+	}
+	if j.Payment != nil && j.Payment.Date != nil {
+		return j.Payment.Date
+	}
+	if j.RecurringGiftSchedule != nil && j.RecurringGiftSchedule.Date != nil {
+		return j.RecurringGiftSchedule.Date
+	}
+	if j.RecurringGift != nil && j.RecurringGift.Date != nil {
+		return j.RecurringGift.Date
+	}
+	if j.Disbursement != nil && j.Disbursement.Date != nil {
+		return j.Disbursement.Date
+	}
+	if j.Purchase != nil && j.Purchase.Date != nil {
+		return j.Purchase.Date
+	}
+	if j.SoftCredit != nil && j.SoftCredit.Date != nil {
+		return j.SoftCredit.Date
+	}
+	if j.SegmentedDonation != nil && j.SegmentedDonation.Date != nil {
+		return j.SegmentedDonation.Date
+	}
+	return nil
+}
+
 func (j *JournalEntry) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	data, err := innerXML(d, start)
 	if err != nil {
@@ -37,6 +37,9 @@ func (f *StandardField) GoCodeAssignToVar(varname string) (string, error) {
 }
 
 func (f *StandardField) GoCodeAssignmentFromVar(varname string) (string, error) {
+	if err := conversionsettings.Load(); err != nil {
+		return "", err
+	}
 	outFieldName := "etap_" + f.ObjectType.String() + "_" + f.FullName + "__c"
 	if sub := conversionsettings.FieldNameSubstitutions[outFieldName]; sub != "" {
 		outFieldName = sub
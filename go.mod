@@ -9,12 +9,15 @@ require (
 	github.com/hooklift/gowsdl v0.5.0
 	github.com/tzmfreedom/go-metaforce v0.0.0-20240311131847-5b8d28315641
 	github.com/tzmfreedom/go-soapforce v0.1.6
+	go.etcd.io/bbolt v1.3.11
 	go.uber.org/multierr v1.11.0
 	golang.org/x/exp v0.0.0-20230905200255-921286631fa9
 	golang.org/x/text v0.12.0
+	golang.org/x/time v0.5.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
-	github.com/stretchr/testify v1.8.1 // indirect
 	golang.org/x/net v0.14.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
 )
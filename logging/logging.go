@@ -0,0 +1,62 @@
+// Package logging is the structured (slog) logger shared by every step of the
+// migration, and a progress reporter for long running phases. Logs are text by
+// default, or JSON if configured, so that long runs can be parsed afterwards.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Silicon-Ally/etap2sf/config"
+)
+
+const (
+	Format_Text = "text"
+	Format_JSON = "json"
+)
+
+// Logger returns the shared logger, which is also set as the slog default.
+var Logger = sync.OnceValue(func() *slog.Logger {
+	l, err := newLogger(&config.Get().Logging)
+	if err != nil {
+		log.Fatalf("setting up logging from %s: %v", config.Path(), err)
+	}
+	slog.SetDefault(l)
+	return l
+})
+
+// For returns the shared logger, tagged with the part of the migration that's logging.
+func For(component string) *slog.Logger {
+	return Logger().With("component", component)
+}
+
+func newLogger(c *config.Logging) (*slog.Logger, error) {
+	level := slog.LevelInfo
+	if c.Level != "" {
+		if err := level.UnmarshalText([]byte(c.Level)); err != nil {
+			return nil, fmt.Errorf("parsing log level: %w", err)
+		}
+	}
+	var w io.Writer = os.Stderr
+	if c.File != "" {
+		f, err := os.OpenFile(c.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+		if err != nil {
+			return nil, fmt.Errorf("opening log file: %w", err)
+		}
+		w = f
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	switch strings.ToLower(c.Format) {
+	case "", Format_Text:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case Format_JSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q, expected %q or %q", c.Format, Format_Text, Format_JSON)
+	}
+}
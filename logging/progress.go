@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Silicon-Ally/etap2sf/config"
+)
+
+const defaultProgressInterval = 10 * time.Second
+
+// Progress reports how far through a phase (i.e. exporting journal entries, or
+// uploading one object type) we are, with the rate and an estimate of the time
+// remaining. It logs at most once per interval, so it's safe to call on every
+// record, from many goroutines.
+type Progress struct {
+	logger   *slog.Logger
+	interval time.Duration
+
+	mu         sync.Mutex
+	start      time.Time
+	lastReport time.Time
+	total      int
+	done       int
+	failed     int
+}
+
+// NewProgress starts reporting progress through a phase with the given number
+// of items. The object type can be empty if the phase only has one.
+func NewProgress(phase, objectType string, total int) *Progress {
+	l := Logger().With("phase", phase)
+	if objectType != "" {
+		l = l.With("object_type", objectType)
+	}
+	interval := defaultProgressInterval
+	if s := config.Get().Logging.ProgressIntervalSeconds; s > 0 {
+		interval = time.Duration(s * float64(time.Second))
+	}
+	now := time.Now()
+	return &Progress{logger: l, interval: interval, start: now, lastReport: now, total: total}
+}
+
+// Succeeded records that an item was completed.
+func (p *Progress) Succeeded() {
+	p.Add(1, 0)
+}
+
+// Failed records that an item failed.
+func (p *Progress) Failed() {
+	p.Add(0, 1)
+}
+
+// Add records that some items were completed, and some failed.
+func (p *Progress) Add(succeeded, failed int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done += succeeded + failed
+	p.failed += failed
+	if time.Since(p.lastReport) >= p.interval {
+		p.report("progress")
+	}
+}
+
+// Done logs the final counts for the phase.
+func (p *Progress) Done() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.report("done")
+}
+
+func (p *Progress) report(msg string) {
+	now := time.Now()
+	p.lastReport = now
+	elapsed := now.Sub(p.start)
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(p.done) / elapsed.Seconds()
+	}
+	attrs := []any{
+		"done", p.done,
+		"failed", p.failed,
+		"total", p.total,
+		"elapsed", elapsed.Round(time.Second).String(),
+		"per_second", float64(int(rate*100)) / 100,
+	}
+	if p.total > 0 {
+		attrs = append(attrs, "percent", float64(p.done*1000/p.total)/10)
+	}
+	if remaining := p.total - p.done; remaining > 0 && rate > 0 {
+		eta := time.Duration(float64(remaining) / rate * float64(time.Second))
+		attrs = append(attrs, "eta", eta.Round(time.Second).String())
+	}
+	p.logger.Info(msg, attrs...)
+}
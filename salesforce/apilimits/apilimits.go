@@ -0,0 +1,153 @@
+// Package apilimits keeps track of how much of the org's daily API request
+// allowance has been used, as Salesforce reports it on the responses to our
+// calls, and how many calls each client has made. Both are published with
+// expvar, under "salesforce_api", so they can be watched while an upload runs.
+package apilimits
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Silicon-Ally/etap2sf/logging"
+)
+
+// Usage is how many API requests the org has made in the last 24 hours, out of
+// how many it's allowed.
+type Usage struct {
+	Used int
+	Max  int
+	// At is when Salesforce reported it.
+	At time.Time
+}
+
+func (u Usage) Known() bool {
+	return u.Max > 0
+}
+
+func (u Usage) Remaining() int {
+	if u.Used >= u.Max {
+		return 0
+	}
+	return u.Max - u.Used
+}
+
+// Fraction is the share of the allowance that's been used, from 0 to 1 (or
+// more, since Salesforce lets orgs go a little over).
+func (u Usage) Fraction() float64 {
+	if u.Max <= 0 {
+		return 0
+	}
+	return float64(u.Used) / float64(u.Max)
+}
+
+func (u Usage) String() string {
+	if !u.Known() {
+		return "unknown"
+	}
+	return fmt.Sprintf("%d of %d (%.1f%%)", u.Used, u.Max, u.Fraction()*100)
+}
+
+var (
+	mu      sync.Mutex
+	current Usage
+
+	metrics = expvar.NewMap("salesforce_api")
+	calls   = new(expvar.Map).Init()
+	phases  = new(expvar.Map).Init()
+)
+
+func init() {
+	metrics.Set("calls", calls)
+	metrics.Set("estimated_calls_remaining", phases)
+	metrics.Set("used", expvar.Func(func() any { return Current().Used }))
+	metrics.Set("max", expvar.Func(func() any { return Current().Max }))
+	metrics.Set("remaining", expvar.Func(func() any { return Current().Remaining() }))
+	metrics.Set("fraction", expvar.Func(func() any { return Current().Fraction() }))
+}
+
+// Observe records the usage reported on a response. Responses can come back
+// out of order, so it never goes down within the same allowance.
+func Observe(used, max int) {
+	if max <= 0 {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if max == current.Max && used < current.Used && time.Since(current.At) < time.Minute {
+		return
+	}
+	current = Usage{Used: used, Max: max, At: time.Now()}
+}
+
+// Current is the most recently reported usage, which isn't Known until a call
+// has reported it.
+func Current() Usage {
+	mu.Lock()
+	defer mu.Unlock()
+	return current
+}
+
+// CountCall records a call made through the given API, like "soap" or "bulk".
+func CountCall(api string) {
+	calls.Add(api, 1)
+}
+
+// SetPhaseEstimate publishes the estimated number of calls that a phase of
+// the upload has left.
+func SetPhaseEstimate(phase string, remaining int) {
+	v := new(expvar.Int)
+	v.Set(int64(remaining))
+	phases.Set(phase, v)
+}
+
+// limitInfoHeader is the header that the REST and bulk APIs report usage in,
+// like "api-usage=18/5000".
+const limitInfoHeader = "Sforce-Limit-Info"
+
+// ObserveHeader records the usage reported in the headers of a REST response,
+// if there is any.
+func ObserveHeader(h http.Header) {
+	if used, max, ok := ParseLimitInfo(h.Get(limitInfoHeader)); ok {
+		Observe(used, max)
+	}
+}
+
+// ParseLimitInfo parses the value of the Sforce-Limit-Info header, which can
+// list other limits alongside the API usage, separated by commas.
+func ParseLimitInfo(v string) (used, max int, ok bool) {
+	for _, part := range strings.Split(v, ",") {
+		k, usage, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found || k != "api-usage" {
+			continue
+		}
+		u, m, found := strings.Cut(usage, "/")
+		if !found {
+			return 0, 0, false
+		}
+		used, err := strconv.Atoi(u)
+		if err != nil {
+			return 0, 0, false
+		}
+		max, err := strconv.Atoi(m)
+		if err != nil {
+			return 0, 0, false
+		}
+		return used, max, true
+	}
+	return 0, 0, false
+}
+
+// Serve serves the metrics (along with the rest of expvar's) as JSON at the
+// given address, like "localhost:8123", until the process exits.
+func Serve(addr string) {
+	go func() {
+		if err := http.ListenAndServe(addr, expvar.Handler()); err != nil {
+			logging.For("apilimits").Error("serving api metrics", "addr", addr, "error", err)
+		}
+	}()
+}
@@ -0,0 +1,108 @@
+// Package bulkclient uploads records through the Salesforce Bulk API 2.0,
+// which takes CSV files of many records at a time rather than one record per
+// SOAP call. It's much faster for large object types (i.e. tasks and
+// opportunities), at the cost of less immediate feedback.
+package bulkclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Silicon-Ally/etap2sf/salesforce/apilimits"
+	client "github.com/Silicon-Ally/etap2sf/salesforce/clients/enterprise"
+)
+
+// Client embeds the enterprise client, which is used for anything the bulk API
+// doesn't do well - single records, binary content and lookups.
+type Client struct {
+	*client.Client
+
+	// PollInterval is how long to wait between checks on the status of a job.
+	PollInterval time.Duration
+	// JobTimeout is how long a job can take before it's aborted.
+	JobTimeout time.Duration
+	// BatchSize is the number of records that are sent in each job.
+	BatchSize int
+
+	httpClient  *http.Client
+	instanceURL string
+	sessionID   string
+	apiVersion  string
+}
+
+func New(ec *client.Client) (*Client, error) {
+	instanceURL, err := ec.InstanceURL()
+	if err != nil {
+		return nil, fmt.Errorf("getting instance url: %w", err)
+	}
+	if ec.SessionID() == "" {
+		return nil, fmt.Errorf("enterprise client has no session id")
+	}
+	return &Client{
+		Client:       ec,
+		PollInterval: 5 * time.Second,
+		JobTimeout:   time.Hour,
+		BatchSize:    10000,
+		httpClient:   &http.Client{Timeout: 5 * time.Minute},
+		instanceURL:  instanceURL,
+		sessionID:    ec.SessionID(),
+		apiVersion:   ec.APIVersion(),
+	}, nil
+}
+
+func (c *Client) MaxBatchSize() int {
+	return c.BatchSize
+}
+
+// CallsPerBatch is roughly how many API calls a batch takes: creating, filling
+// and closing its job, a few polls, and fetching the three kinds of results.
+func (c *Client) CallsPerBatch() int {
+	return 10
+}
+
+type apiError struct {
+	ErrorCode string `json:"errorCode"`
+	Message   string `json:"message"`
+}
+
+// do issues a request against the REST API, and if out is non-nil, unmarshals
+// the JSON response into it. The raw response body is returned either way.
+func (c *Client) do(method, path, contentType string, body io.Reader, out any) ([]byte, error) {
+	url := fmt.Sprintf("%s/services/data/v%s%s", c.instanceURL, c.apiVersion, path)
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.sessionID)
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("issuing %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	apilimits.CountCall("bulk")
+	apilimits.ObserveHeader(resp.Header)
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response to %s %s: %w", method, path, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErrs := []apiError{}
+		if err := json.Unmarshal(data, &apiErrs); err == nil && len(apiErrs) > 0 {
+			return nil, fmt.Errorf("%s %s returned %d: %s: %s", method, path, resp.StatusCode, apiErrs[0].ErrorCode, apiErrs[0].Message)
+		}
+		return nil, fmt.Errorf("%s %s returned %d: %q", method, path, resp.StatusCode, string(data))
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return nil, fmt.Errorf("unmarshalling response to %s %s: %w", method, path, err)
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,150 @@
+package bulkclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Silicon-Ally/etap2sf/logging"
+)
+
+type jobState string
+
+const (
+	jobState_Open           jobState = "Open"
+	jobState_UploadComplete jobState = "UploadComplete"
+	jobState_InProgress     jobState = "InProgress"
+	jobState_JobComplete    jobState = "JobComplete"
+	jobState_Failed         jobState = "Failed"
+	jobState_Aborted        jobState = "Aborted"
+)
+
+type jobRequest struct {
+	Object              string `json:"object"`
+	ExternalIDFieldName string `json:"externalIdFieldName,omitempty"`
+	ContentType         string `json:"contentType"`
+	Operation           string `json:"operation"`
+	LineEnding          string `json:"lineEnding"`
+}
+
+type jobInfo struct {
+	ID                     string   `json:"id"`
+	Object                 string   `json:"object"`
+	Operation              string   `json:"operation"`
+	State                  jobState `json:"state"`
+	ErrorMessage           string   `json:"errorMessage"`
+	NumberRecordsProcessed int      `json:"numberRecordsProcessed"`
+	NumberRecordsFailed    int      `json:"numberRecordsFailed"`
+}
+
+// runJob creates an ingest job, uploads the CSV to it, and waits for it to be
+// processed. The returned job info reflects its final state. If the context
+// ends (or the job takes longer than JobTimeout) first, the job is aborted.
+func (c *Client) runJob(ctx context.Context, req *jobRequest, csvData []byte) (*jobInfo, error) {
+	job, err := c.createJob(req)
+	if err != nil {
+		return nil, fmt.Errorf("creating job: %w", err)
+	}
+	if _, err := c.do(http.MethodPut, "/jobs/ingest/"+job.ID+"/batches", "text/csv", bytes.NewReader(csvData), nil); err != nil {
+		c.abortJob(job.ID)
+		return nil, fmt.Errorf("uploading data to job %s: %w", job.ID, err)
+	}
+	if err := c.setJobState(job.ID, jobState_UploadComplete); err != nil {
+		c.abortJob(job.ID)
+		return nil, fmt.Errorf("closing job %s: %w", job.ID, err)
+	}
+	if c.JobTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.JobTimeout)
+		defer cancel()
+	}
+	return c.waitForJob(ctx, job.ID)
+}
+
+func (c *Client) createJob(req *jobRequest) (*jobInfo, error) {
+	body, err := jsonBody(req)
+	if err != nil {
+		return nil, err
+	}
+	job := &jobInfo{}
+	if _, err := c.do(http.MethodPost, "/jobs/ingest", "application/json", body, job); err != nil {
+		return nil, err
+	}
+	if job.ID == "" {
+		return nil, fmt.Errorf("no job id in response")
+	}
+	return job, nil
+}
+
+func (c *Client) setJobState(id string, state jobState) error {
+	body, err := jsonBody(map[string]jobState{"state": state})
+	if err != nil {
+		return err
+	}
+	_, err = c.do(http.MethodPatch, "/jobs/ingest/"+id, "application/json", body, nil)
+	return err
+}
+
+func (c *Client) abortJob(id string) {
+	if err := c.setJobState(id, jobState_Aborted); err != nil {
+		logging.For("bulk").Error("failed to abort job", "job", id, "error", err)
+	}
+}
+
+func (c *Client) waitForJob(ctx context.Context, id string) (*jobInfo, error) {
+	logger := logging.For("bulk").With("job", id)
+	for {
+		job := &jobInfo{}
+		if _, err := c.do(http.MethodGet, "/jobs/ingest/"+id, "", nil, job); err != nil {
+			return nil, fmt.Errorf("getting status of job %s: %w", id, err)
+		}
+		switch job.State {
+		case jobState_JobComplete:
+			return job, nil
+		case jobState_Failed, jobState_Aborted:
+			return job, fmt.Errorf("job %s ended in state %s: %s", id, job.State, job.ErrorMessage)
+		}
+		logger.Info("waiting for job", "operation", job.Operation, "object", job.Object, "state", string(job.State), "processed", job.NumberRecordsProcessed)
+		select {
+		case <-ctx.Done():
+			logger.Warn("aborting job", "cause", context.Cause(ctx))
+			c.abortJob(id)
+			return nil, fmt.Errorf("job %s was aborted: %w", id, context.Cause(ctx))
+		case <-time.After(c.PollInterval):
+		}
+	}
+}
+
+// jobResults fetches one of the results of a job (successfulResults,
+// failedResults or unprocessedrecords) as CSV rows keyed by column name.
+func (c *Client) jobResults(id, kind string) ([]map[string]string, error) {
+	data, err := c.do(http.MethodGet, "/jobs/ingest/"+id+"/"+kind+"/", "", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("getting %s for job %s: %w", kind, id, err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s for job %s: %w", kind, id, err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	header := rows[0]
+	result := []map[string]string{}
+	for _, row := range rows[1:] {
+		m := map[string]string{}
+		for i, v := range row {
+			if i < len(header) {
+				m[header[i]] = v
+			}
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
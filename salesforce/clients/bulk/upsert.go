@@ -0,0 +1,190 @@
+package bulkclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/Silicon-Ally/etap2sf/salesforce"
+	client "github.com/Silicon-Ally/etap2sf/salesforce/clients/enterprise"
+	"github.com/Silicon-Ally/etap2sf/utils"
+)
+
+// UpsertBatch upserts the given records (all of the given type) in a single
+// bulk job. The returned IDs and errors line up with the records - each record
+// either has a Salesforce ID or an error. The overall error is only non-nil if
+// the job itself couldn't be run, or was aborted because the context ended.
+func (c *Client) UpsertBatch(ctx context.Context, sot salesforce.ObjectType, records []any) ([]string, []error, error) {
+	if sot == salesforce.ObjectType_ContentVersion {
+		return nil, nil, fmt.Errorf("content versions contain binary data, which the bulk API can't upload - use the soap backend for them")
+	}
+	ots, err := sot.SalesforceName()
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting salesforce name: %w", err)
+	}
+	req := &jobRequest{
+		Object:      ots,
+		ContentType: "CSV",
+		Operation:   "upsert",
+		LineEnding:  "LF",
+	}
+	// Content document links have no external ID, so they're always inserted,
+	// which matches UpsertContentDocumentLink in the enterprise client.
+	if sot == salesforce.ObjectType_ContentDocumentLink {
+		req.Operation = "insert"
+	} else {
+		req.ExternalIDFieldName, err = sot.SalesforceObjectExternalFieldKey()
+		if err != nil {
+			return nil, nil, fmt.Errorf("getting salesforce object external field key: %w", err)
+		}
+	}
+
+	ids := make([]string, len(records))
+	errs := make([]error, len(records))
+	rows := make([]map[string]string, len(records))
+	columns := map[string]bool{}
+	for i, record := range records {
+		fields, err := client.StructToFieldsMap(record)
+		if err != nil {
+			errs[i] = fmt.Errorf("converting struct to map: %w", err)
+			continue
+		}
+		row := map[string]string{}
+		for k, v := range fields {
+			s, ok := v.(string)
+			if !ok {
+				errs[i] = fmt.Errorf("field %s is a %T, not a string", k, v)
+				break
+			}
+			row[k] = s
+			columns[k] = true
+		}
+		if errs[i] != nil {
+			continue
+		}
+		if req.ExternalIDFieldName != "" && row[req.ExternalIDFieldName] == "" {
+			errs[i] = fmt.Errorf("field key %s is empty", req.ExternalIDFieldName)
+			continue
+		}
+		rows[i] = row
+	}
+	header := make([]string, 0, len(columns))
+	for col := range columns {
+		header = append(header, col)
+	}
+	sort.Strings(header)
+
+	keyFn := func(row map[string]string) string {
+		if req.ExternalIDFieldName != "" {
+			return row[req.ExternalIDFieldName]
+		}
+		// Without an external ID, the record is identified by all of its values,
+		// which the results echo back.
+		vals := make([]string, len(header))
+		for i, col := range header {
+			vals[i] = row[col]
+		}
+		return strings.Join(vals, "\x00")
+	}
+
+	indexByKey := map[string]int{}
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	if err := w.Write(header); err != nil {
+		return nil, nil, fmt.Errorf("writing csv header: %w", err)
+	}
+	for i, row := range rows {
+		if row == nil {
+			continue
+		}
+		key := keyFn(row)
+		if _, ok := indexByKey[key]; ok {
+			errs[i] = fmt.Errorf("record is a duplicate of another record in the same bulk job")
+			continue
+		}
+		indexByKey[key] = i
+		line := make([]string, len(header))
+		for j, col := range header {
+			line[j] = row[col]
+		}
+		if err := w.Write(line); err != nil {
+			return nil, nil, fmt.Errorf("writing csv row: %w", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, nil, fmt.Errorf("writing csv: %w", err)
+	}
+	if len(indexByKey) == 0 {
+		return ids, errs, nil
+	}
+
+	job, err := c.runJob(ctx, req, buf.Bytes())
+	if err != nil {
+		return nil, nil, fmt.Errorf("running bulk %s job for %s: %w", req.Operation, sot, err)
+	}
+
+	successes, err := c.jobResults(job.ID, "successfulResults")
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, r := range successes {
+		i, ok := indexByKey[keyFn(r)]
+		if !ok {
+			continue
+		}
+		if r["sf__Id"] == "" {
+			errs[i] = fmt.Errorf("bulk job %s reported success but returned no id", job.ID)
+		} else {
+			ids[i] = r["sf__Id"]
+		}
+		delete(indexByKey, keyFn(r))
+	}
+	failures, err := c.jobResults(job.ID, "failedResults")
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, r := range failures {
+		i, ok := indexByKey[keyFn(r)]
+		if !ok {
+			continue
+		}
+		errs[i] = fmt.Errorf("bulk job %s failed to %s %s: %w", job.ID, req.Operation, sot, salesforce.ParseBulkError(r["sf__Error"]))
+		delete(indexByKey, keyFn(r))
+	}
+	unprocessed, err := c.jobResults(job.ID, "unprocessedrecords")
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, r := range unprocessed {
+		i, ok := indexByKey[keyFn(r)]
+		if !ok {
+			continue
+		}
+		errs[i] = fmt.Errorf("bulk job %s did not process record", job.ID)
+		delete(indexByKey, keyFn(r))
+	}
+	if len(indexByKey) > 0 {
+		tmpFile, err := utils.WriteValueToTempJSONFile(job, fmt.Sprintf("bulk-%s-job", sot))
+		if err != nil {
+			return nil, nil, fmt.Errorf("writing job info: %w", err)
+		}
+		for _, i := range indexByKey {
+			errs[i] = fmt.Errorf("record not found in results of bulk job %s - see %s", job.ID, tmpFile)
+		}
+	}
+	return ids, errs, nil
+}
+
+func jsonBody(v any) (io.Reader, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling request: %w", err)
+	}
+	return bytes.NewReader(data), nil
+}
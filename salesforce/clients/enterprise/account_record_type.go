@@ -7,7 +7,7 @@ import (
 )
 
 func (c *Client) GetAccountRecordTypes() (orgID, hhID sfenterprise.ID, err error) {
-	resp, err := c.gc.EnterpriseClient.QueryAll("SELECT Id, Name, SobjectType FROM RecordType")
+	resp, err := c.soap.QueryAll("SELECT Id, Name, SobjectType FROM RecordType")
 	if err != nil {
 		err = fmt.Errorf("querying content document by version: %w", err)
 		return
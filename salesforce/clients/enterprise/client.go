@@ -2,12 +2,17 @@ package client
 
 import (
 	"fmt"
+	"net/url"
 
 	genericclient "github.com/Silicon-Ally/etap2sf/salesforce/clients/generic"
 )
 
 type Client struct {
 	gc *genericclient.Client
+	// soap is gc's SOAP client, which records API usage after each call.
+	soap *soapClient
+	// production blocks operations that delete records the migration didn't create.
+	production bool
 }
 
 type ConnConfig interface {
@@ -21,6 +26,8 @@ type Config struct {
 	ConnConfig ConnConfig
 	APIVersion string
 	Debug      bool
+	// Production is set when the client is logged into a production org.
+	Production bool
 }
 
 func New(c *Config) (*Client, error) {
@@ -32,5 +39,23 @@ func New(c *Config) (*Client, error) {
 	if err != nil {
 		return nil, fmt.Errorf("creating generic client: %w", err)
 	}
-	return &Client{gc: gc}, nil
+	return &Client{gc: gc, soap: &soapClient{Client: gc.EnterpriseClient}, production: c.Production}, nil
+}
+
+// InstanceURL is the base URL of the Salesforce instance that the client is
+// logged into, like https://yourorg.my.salesforce.com.
+func (c *Client) InstanceURL() (string, error) {
+	u, err := url.Parse(c.gc.ServerURL)
+	if err != nil {
+		return "", fmt.Errorf("parsing server url %q: %w", c.gc.ServerURL, err)
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
+func (c *Client) SessionID() string {
+	return c.gc.SessionID
+}
+
+func (c *Client) APIVersion() string {
+	return c.gc.APIVersion
 }
@@ -17,7 +17,7 @@ func (c *Client) deleteAllIDs(ids []string) error {
 		batches = append(batches, ids)
 	}
 	for i, batch := range batches {
-		resp, err := c.gc.EnterpriseClient.Delete(batch)
+		resp, err := c.soap.Delete(batch)
 		if err != nil {
 			return fmt.Errorf("deleting batch %d/%d: %w", i+1, len(batches), err)
 		}
@@ -41,7 +41,7 @@ func (c *Client) getAllIDs(sot salesforce.ObjectType) ([]string, error) {
 	}
 	ids := []string{}
 	query := "SELECT Id FROM " + sn
-	response, err := c.gc.EnterpriseClient.Query(query)
+	response, err := c.soap.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("querying: %w", err)
 	}
@@ -53,7 +53,7 @@ func (c *Client) getAllIDs(sot salesforce.ObjectType) ([]string, error) {
 	done := response.Done
 	cursor := response.QueryLocator
 	for !done {
-		response, err := c.gc.EnterpriseClient.QueryMore(cursor)
+		response, err := c.soap.QueryMore(cursor)
 		if err != nil {
 			return nil, fmt.Errorf("query more: %w", err)
 		}
@@ -75,6 +75,9 @@ func (c *Client) deleteAll(sot salesforce.ObjectType) error {
 }
 
 func (c *Client) DeleteAllExistingRelationships() error {
+	if c.production {
+		return fmt.Errorf("deleting every relationship is blocked on production orgs")
+	}
 	ids, err := c.getAllIDs(salesforce.ObjectType_Relationship)
 	if err != nil {
 		return fmt.Errorf("getting all ids: %w", err)
@@ -85,7 +88,7 @@ func (c *Client) DeleteAllExistingRelationships() error {
 func (c *Client) DeleteRelationshipsNotCreatedThroughETap() error {
 	ids := []string{}
 	query := "SELECT Id, Etap_Relationship_Ref__c FROM Npe4__Relationship__c WHERE Etap_Relationship_Ref__c = NULL"
-	response, err := c.gc.EnterpriseClient.Query(query)
+	response, err := c.soap.Query(query)
 	if err != nil {
 		return fmt.Errorf("querying relationships: %w", err)
 	}
@@ -96,7 +99,7 @@ func (c *Client) DeleteRelationshipsNotCreatedThroughETap() error {
 	cursor := response.QueryLocator
 
 	for !done {
-		response, err := c.gc.EnterpriseClient.QueryMore(cursor)
+		response, err := c.soap.QueryMore(cursor)
 		if err != nil {
 			return fmt.Errorf("querying relationships: %w", err)
 		}
@@ -109,7 +112,12 @@ func (c *Client) DeleteRelationshipsNotCreatedThroughETap() error {
 	return c.deleteAllIDs(ids)
 }
 
+// DeleteAll deletes every record of the migrated types, whether or not the
+// migration created it. It's blocked on production orgs - use rollback there.
 func (c *Client) DeleteAll() error {
+	if c.production {
+		return fmt.Errorf("deleting everything is blocked on production orgs, use `etap2sf rollback` to delete only the migrated records")
+	}
 	order := []salesforce.ObjectType{
 		// salesforce.ObjectType_ContentDocumentLink,
 		// salesforce.ObjectType_ContentVersion,
@@ -124,6 +132,7 @@ func (c *Client) DeleteAll() error {
 		salesforce.ObjectType_Affiliation,
 		salesforce.ObjectType_Relationship,
 		salesforce.ObjectType_Contact,
+		salesforce.ObjectType_Household,
 		salesforce.ObjectType_Account,
 		salesforce.ObjectType_GAUAllocation,
 		salesforce.ObjectType_Campaign,
@@ -137,3 +146,35 @@ func (c *Client) DeleteAll() error {
 	}
 	return nil
 }
+
+// DeleteBatch deletes up to 200 records in a single call. The returned errors
+// line up with the IDs, and records that were already deleted count as
+// deleted. The overall error is only non-nil if the call itself failed.
+func (c *Client) DeleteBatch(ids []string) ([]error, error) {
+	if len(ids) > maxSOAPBatchSize {
+		return nil, fmt.Errorf("can delete at most %d records per call, got %d", maxSOAPBatchSize, len(ids))
+	}
+	resp, err := c.soap.Delete(ids)
+	if err != nil {
+		return nil, fmt.Errorf("deleting batch of %d: %w", len(ids), err)
+	}
+	if len(resp) != len(ids) {
+		return nil, fmt.Errorf("expected %d results, got %d", len(ids), len(resp))
+	}
+	errs := make([]error, len(ids))
+	for i, result := range resp {
+		if result.Success {
+			continue
+		}
+		if len(result.Errors) == 0 {
+			errs[i] = fmt.Errorf("failed to delete %s - see response", ids[i])
+			continue
+		}
+		re := toRecordError(result.Errors)
+		if re.StatusCode == "ENTITY_IS_DELETED" {
+			continue
+		}
+		errs[i] = re
+	}
+	return errs, nil
+}
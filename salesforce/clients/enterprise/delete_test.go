@@ -0,0 +1,40 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/Silicon-Ally/etap2sf/salesforce"
+	"github.com/Silicon-Ally/etap2sf/salesforce/fakesf"
+)
+
+func TestDeleteBatch(t *testing.T) {
+	s, c := newFakeClient(t)
+	deleted := s.Insert("Account", map[string]string{salesforce.MultiObjectExternalFieldKey: "1.0.1"})
+	live := s.Insert("Account", map[string]string{salesforce.MultiObjectExternalFieldKey: "1.0.2"})
+	failing := s.Insert("Account", map[string]string{salesforce.MultiObjectExternalFieldKey: "1.0.3"})
+	if errs, err := c.DeleteBatch([]string{deleted}); err != nil || errs[0] != nil {
+		t.Fatalf("DeleteBatch = %v, %v", errs, err)
+	}
+	// Only the status code says whether a record was already deleted.
+	s.Inject(&fakesf.Injection{
+		Operation:  "delete",
+		ObjectType: "Account",
+		Match:      func(fields map[string]string) bool { return fields[salesforce.MultiObjectExternalFieldKey] == "1.0.3" },
+		StatusCode: "DELETE_FAILED",
+		Message:    "its parent entity is deleted, but it's still referenced",
+	})
+
+	errs, err := c.DeleteBatch([]string{deleted, live, failing})
+	if err != nil {
+		t.Fatalf("DeleteBatch: %v", err)
+	}
+	if errs[0] != nil || errs[1] != nil {
+		t.Errorf("errors = %v, want the deleted and live records to count as deleted", errs)
+	}
+	if re, ok := salesforce.AsRecordError(errs[2]); !ok || re.StatusCode != "DELETE_FAILED" {
+		t.Errorf("error = %v, want DELETE_FAILED", errs[2])
+	}
+	if got := len(s.Records("Account")); got != 1 {
+		t.Errorf("account records = %d, want only the one that failed", got)
+	}
+}
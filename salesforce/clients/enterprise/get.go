@@ -2,12 +2,16 @@ package client
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 
+	"github.com/Silicon-Ally/etap2sf/salesforce"
 	"github.com/Silicon-Ally/etap2sf/salesforce/generated/sfenterprise"
+	"github.com/tzmfreedom/go-soapforce"
 )
 
 func (c *Client) LookupContentDocumentByVersion(id sfenterprise.ID) (sfenterprise.ID, error) {
-	response, err := c.gc.EnterpriseClient.QueryAll("SELECT ContentDocumentId FROM ContentVersion WHERE Id = '" + string(id) + "'")
+	response, err := c.soap.QueryAll("SELECT ContentDocumentId FROM ContentVersion WHERE Id = '" + string(id) + "'")
 	if err != nil {
 		return "", fmt.Errorf("querying content document by version: %w", err)
 	}
@@ -29,7 +33,7 @@ func (c *Client) LookupContentDocumentByVersion(id sfenterprise.ID) (sfenterpris
 }
 
 func (c *Client) LookupUserByEmail(email string) (sfenterprise.ID, error) {
-	response, err := c.gc.EnterpriseClient.QueryAll("SELECT Id FROM User WHERE Email = '" + email + "'")
+	response, err := c.soap.QueryAll("SELECT Id FROM User WHERE Email = '" + email + "'")
 	if err != nil {
 		return "", fmt.Errorf("querying user by email: %w", err)
 	}
@@ -49,3 +53,69 @@ func (c *Client) LookupUserByEmail(email string) (sfenterprise.ID, error) {
 	}
 	return sfenterprise.ID(ids[0]), nil
 }
+
+// GetRecordsByExternalKey fetches the given fields of every record of the given
+// type that has an external key, keyed by the value of that key. Field names
+// are lowercased, since Salesforce doesn't preserve the case they're queried in.
+func (c *Client) GetRecordsByExternalKey(sot salesforce.ObjectType, fields []string) (map[string]map[string]string, error) {
+	sn, err := sot.SalesforceName()
+	if err != nil {
+		return nil, fmt.Errorf("getting salesforce name: %w", err)
+	}
+	fieldKey, err := sot.SalesforceObjectExternalFieldKey()
+	if err != nil {
+		return nil, fmt.Errorf("getting salesforce object external field key: %w", err)
+	}
+	toSelect := []string{"Id"}
+	for _, f := range fields {
+		if !strings.EqualFold(f, "Id") {
+			toSelect = append(toSelect, f)
+		}
+	}
+	if !slices.ContainsFunc(toSelect, func(f string) bool { return strings.EqualFold(f, fieldKey) }) {
+		toSelect = append(toSelect, fieldKey)
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s != NULL", strings.Join(toSelect, ", "), sn, fieldKey)
+	result := map[string]map[string]string{}
+	addRecords := func(records []*soapforce.SObject) error {
+		for _, record := range records {
+			values := map[string]string{"id": record.Id}
+			for k, v := range record.Fields {
+				switch vv := v.(type) {
+				case nil:
+				case string:
+					values[strings.ToLower(k)] = vv
+				default:
+					values[strings.ToLower(k)] = fmt.Sprint(vv)
+				}
+			}
+			key := values[strings.ToLower(fieldKey)]
+			if key == "" {
+				return fmt.Errorf("record %s has no value for %s", record.Id, fieldKey)
+			}
+			result[key] = values
+		}
+		return nil
+	}
+	response, err := c.soap.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("querying %s: %w", sn, err)
+	}
+	if err := addRecords(response.Records); err != nil {
+		return nil, err
+	}
+	done := response.Done
+	cursor := response.QueryLocator
+	for !done {
+		response, err := c.soap.QueryMore(cursor)
+		if err != nil {
+			return nil, fmt.Errorf("query more: %w", err)
+		}
+		if err := addRecords(response.Records); err != nil {
+			return nil, err
+		}
+		done = response.Done
+		cursor = response.QueryLocator
+	}
+	return result, nil
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Silicon-Ally/etap2sf/salesforce/apilimits"
+	"github.com/tzmfreedom/go-soapforce"
+)
+
+// soapClient wraps the SOAP client so that each call records the API usage
+// that Salesforce reports in the LimitInfoHeader of its response. go-soapforce
+// keeps that header on the client, overwriting it on every call, so calls are
+// made one at a time, with the header read before the next call is made.
+type soapClient struct {
+	*soapforce.Client
+	mu sync.Mutex
+}
+
+// call makes the call with fn, then records the usage that its response
+// reported.
+func call[T any](s *soapClient, fn func() (T, error)) (T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, err := fn()
+	apilimits.CountCall("soap")
+	info := s.GetInfo()
+	if info != nil && info.LimitInfo != nil && info.LimitInfo.Type_ == "API REQUESTS" {
+		apilimits.Observe(int(info.LimitInfo.Current), int(info.LimitInfo.Limit))
+	}
+	return t, err
+}
+
+func (s *soapClient) Create(sobjs []*soapforce.SObject) ([]*soapforce.SaveResult, error) {
+	return call(s, func() ([]*soapforce.SaveResult, error) { return s.Client.Create(sobjs) })
+}
+
+func (s *soapClient) Update(sobjs []*soapforce.SObject) ([]*soapforce.SaveResult, error) {
+	return call(s, func() ([]*soapforce.SaveResult, error) { return s.Client.Update(sobjs) })
+}
+
+func (s *soapClient) Upsert(sobjs []*soapforce.SObject, key string) ([]*soapforce.UpsertResult, error) {
+	return call(s, func() ([]*soapforce.UpsertResult, error) { return s.Client.Upsert(sobjs, key) })
+}
+
+func (s *soapClient) Delete(ids []string) ([]*soapforce.DeleteResult, error) {
+	return call(s, func() ([]*soapforce.DeleteResult, error) { return s.Client.Delete(ids) })
+}
+
+func (s *soapClient) Query(q string) (*soapforce.QueryResult, error) {
+	return call(s, func() (*soapforce.QueryResult, error) { return s.Client.Query(q) })
+}
+
+func (s *soapClient) QueryAll(q string) (*soapforce.QueryResult, error) {
+	return call(s, func() (*soapforce.QueryResult, error) { return s.Client.QueryAll(q) })
+}
+
+func (s *soapClient) QueryMore(ql string) (*soapforce.QueryResult, error) {
+	return call(s, func() (*soapforce.QueryResult, error) { return s.Client.QueryMore(ql) })
+}
+
+type limitsResponse struct {
+	DailyAPIRequests struct {
+		Max       int `json:"Max"`
+		Remaining int `json:"Remaining"`
+	} `json:"DailyApiRequests"`
+}
+
+// FetchAPIUsage asks the REST limits resource how much of the org's daily API
+// allowance has been used, and records it. Unlike the usage reported on other
+// calls, it doesn't need a call to have been made first.
+func (c *Client) FetchAPIUsage() (apilimits.Usage, error) {
+	instanceURL, err := c.InstanceURL()
+	if err != nil {
+		return apilimits.Usage{}, fmt.Errorf("getting instance url: %w", err)
+	}
+	url := fmt.Sprintf("%s/services/data/v%s/limits", instanceURL, c.APIVersion())
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return apilimits.Usage{}, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.SessionID())
+	req.Header.Set("Accept", "application/json")
+	resp, err := (&http.Client{Timeout: time.Minute}).Do(req)
+	if err != nil {
+		return apilimits.Usage{}, fmt.Errorf("getting limits: %w", err)
+	}
+	defer resp.Body.Close()
+	apilimits.CountCall("rest")
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return apilimits.Usage{}, fmt.Errorf("reading limits: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return apilimits.Usage{}, fmt.Errorf("getting limits returned %d: %q", resp.StatusCode, string(data))
+	}
+	lr := &limitsResponse{}
+	if err := json.Unmarshal(data, lr); err != nil {
+		return apilimits.Usage{}, fmt.Errorf("unmarshalling limits: %w", err)
+	}
+	max := lr.DailyAPIRequests.Max
+	if max <= 0 {
+		return apilimits.Usage{}, fmt.Errorf("limits have no daily api requests: %q", string(data))
+	}
+	apilimits.Observe(max-lr.DailyAPIRequests.Remaining, max)
+	return apilimits.Current(), nil
+}
@@ -1,10 +1,14 @@
 package client
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/Silicon-Ally/etap2sf/salesforce/profile"
 	"github.com/tzmfreedom/go-soapforce"
 )
 
@@ -49,7 +53,7 @@ func (c *Client) FixNPSPTriggers() error {
 	}
 	batches = append(batches, ids)
 	for _, batch := range batches {
-		resp, err := c.gc.EnterpriseClient.Delete(batch)
+		resp, err := c.soap.Delete(batch)
 		if err != nil {
 			return fmt.Errorf("deleting triggers: %w", err)
 		}
@@ -77,15 +81,113 @@ func (c *Client) DisableNPSPRelationshipTriggers() (func() error, error) {
 			toDisable = append(toDisable, trigger)
 		}
 	}
+	// The journal is written before anything changes, so that if we're killed
+	// before undoing this, the next run knows what to re-enable.
+	if err := addToNPSPTriggerJournal(toDisable); err != nil {
+		return nil, fmt.Errorf("writing npsp trigger journal: %w", err)
+	}
 	if err := c.setNPSPTriggerActivityState(toDisable, false); err != nil {
 		return nil, fmt.Errorf("setting npsp trigger activity state to inactive: %w", err)
 	}
+	undone := false
 	undoFn := func() error {
-		return c.setNPSPTriggerActivityState(toDisable, true)
+		if undone {
+			return nil
+		}
+		if err := c.setNPSPTriggerActivityState(toDisable, true); err != nil {
+			return err
+		}
+		undone = true
+		return clearNPSPTriggerJournal()
 	}
 	return undoFn, nil
 }
 
+// NPSPTriggerJournal records the NPSP trigger handlers that we've disabled and
+// not yet re-enabled.
+type NPSPTriggerJournal struct {
+	DisabledAt time.Time
+	TriggerIDs []string
+}
+
+func npspTriggerJournalPath() (string, error) {
+	return profile.DataPath("npsp-trigger-journal.json")
+}
+
+// PendingNPSPTriggerJournal returns the journal of triggers left disabled by
+// a previous run that didn't finish cleanly, or nil if there are none.
+func PendingNPSPTriggerJournal() (*NPSPTriggerJournal, error) {
+	path, err := npspTriggerJournalPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading npsp trigger journal: %w", err)
+	}
+	j := &NPSPTriggerJournal{}
+	if err := json.Unmarshal(data, j); err != nil {
+		return nil, fmt.Errorf("unmarshalling npsp trigger journal: %w", err)
+	}
+	if len(j.TriggerIDs) == 0 {
+		return nil, nil
+	}
+	return j, nil
+}
+
+// RestoreNPSPTriggers re-enables the triggers in the journal, and clears it.
+func (c *Client) RestoreNPSPTriggers(j *NPSPTriggerJournal) error {
+	triggers := []*npspTrigger{}
+	for _, id := range j.TriggerIDs {
+		triggers = append(triggers, &npspTrigger{ID: id})
+	}
+	if err := c.setNPSPTriggerActivityState(triggers, true); err != nil {
+		return fmt.Errorf("setting npsp trigger activity state to active: %w", err)
+	}
+	return clearNPSPTriggerJournal()
+}
+
+func addToNPSPTriggerJournal(triggers []*npspTrigger) error {
+	j, err := PendingNPSPTriggerJournal()
+	if err != nil {
+		return err
+	}
+	if j == nil {
+		j = &NPSPTriggerJournal{DisabledAt: time.Now()}
+	}
+	seen := map[string]bool{}
+	for _, id := range j.TriggerIDs {
+		seen[id] = true
+	}
+	for _, t := range triggers {
+		if !seen[t.ID] {
+			j.TriggerIDs = append(j.TriggerIDs, t.ID)
+		}
+	}
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling npsp trigger journal: %w", err)
+	}
+	path, err := npspTriggerJournalPath()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0777)
+}
+
+func clearNPSPTriggerJournal() error {
+	path, err := npspTriggerJournalPath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing npsp trigger journal: %w", err)
+	}
+	return nil
+}
+
 func (c *Client) setNPSPTriggerActivityState(triggers []*npspTrigger, active bool) error {
 	sobjs := []*soapforce.SObject{}
 	for _, trigger := range triggers {
@@ -96,7 +198,7 @@ func (c *Client) setNPSPTriggerActivityState(triggers []*npspTrigger, active boo
 			Type:   "npsp__Trigger_Handler__c",
 		})
 	}
-	resp, err := c.gc.EnterpriseClient.Update(sobjs)
+	resp, err := c.soap.Update(sobjs)
 	if err != nil {
 		return fmt.Errorf("updating npsp triggers: %w", err)
 	}
@@ -120,7 +222,7 @@ type npspTrigger struct {
 }
 
 func (c *Client) getAllNPSPTriggers() ([]*npspTrigger, error) {
-	response, err := c.gc.EnterpriseClient.QueryAll("SELECT Id, Name, npsp__Class__c, CreatedDate FROM npsp__Trigger_Handler__c")
+	response, err := c.soap.QueryAll("SELECT Id, Name, npsp__Class__c, CreatedDate FROM npsp__Trigger_Handler__c")
 	if err != nil {
 		return nil, fmt.Errorf("querying npsp triggers: %w", err)
 	}
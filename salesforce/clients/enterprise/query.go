@@ -0,0 +1,57 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tzmfreedom/go-soapforce"
+)
+
+// QueryRows runs a query to the end of its results, and returns the fields of
+// each row. As with GetRecordsByExternalKey, field names are lowercased.
+// Fields of related records are flattened into dotted names, like
+// "account.name", and the fields of aggregate queries are named by their
+// aliases (or expr0, expr1 and so on). Null values are empty.
+//
+// Aggregate queries with a GROUP BY can't be paged through, so Salesforce
+// fails them if they have more than 2,000 rows.
+func (c *Client) QueryRows(query string) ([]map[string]string, error) {
+	rows := []map[string]string{}
+	addRecords := func(records []*soapforce.SObject) {
+		for _, record := range records {
+			row := map[string]string{}
+			flattenFields(row, "", record)
+			rows = append(rows, row)
+		}
+	}
+	response, err := c.soap.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("querying: %w", err)
+	}
+	addRecords(response.Records)
+	for !response.Done {
+		if response, err = c.soap.QueryMore(response.QueryLocator); err != nil {
+			return nil, fmt.Errorf("query more: %w", err)
+		}
+		addRecords(response.Records)
+	}
+	return rows, nil
+}
+
+func flattenFields(row map[string]string, prefix string, record *soapforce.SObject) {
+	if record.Id != "" {
+		row[prefix+"id"] = record.Id
+	}
+	for k, v := range record.Fields {
+		name := prefix + strings.ToLower(k)
+		switch vv := v.(type) {
+		case nil:
+		case string:
+			row[name] = vv
+		case *soapforce.SObject:
+			flattenFields(row, name+".", vv)
+		default:
+			row[name] = fmt.Sprint(vv)
+		}
+	}
+}
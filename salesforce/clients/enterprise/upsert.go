@@ -6,7 +6,6 @@ import (
 
 	"github.com/Silicon-Ally/etap2sf/salesforce"
 	"github.com/Silicon-Ally/etap2sf/salesforce/generated/sfenterprise"
-	"github.com/Silicon-Ally/etap2sf/utils"
 	"github.com/tzmfreedom/go-soapforce"
 )
 
@@ -66,6 +65,42 @@ func (c *Client) UpsertAdditionalContext(ac *sfenterprise.Etap_AdditionalContext
 	return c.upsert(salesforce.ObjectType_AdditionalContext, ac)
 }
 
+func (c *Client) UpsertHousehold(h *sfenterprise.Npo02__Household__c) (string, error) {
+	return c.upsert(salesforce.ObjectType_Household, h)
+}
+
+// CreateContentNote creates a note. Notes can't have an external ID, so unlike
+// the other types they can't be upserted - it's up to the caller not to create
+// the same note twice.
+func (c *Client) CreateContentNote(note *sfenterprise.ContentNote) (string, error) {
+	sn, err := salesforce.ObjectType_ContentNote.SalesforceName()
+	if err != nil {
+		return "", fmt.Errorf("getting salesforce name: %w", err)
+	}
+	fields, err := StructToFieldsMap(note)
+	if err != nil {
+		return "", fmt.Errorf("converting struct to map: %w", err)
+	}
+	results, err := c.soap.Create([]*soapforce.SObject{{Type: sn, Fields: fields}})
+	if err != nil {
+		return "", fmt.Errorf("generally creating note: %w", err)
+	}
+	if len(results) != 1 {
+		return "", fmt.Errorf("expected 1 result, got %d", len(results))
+	}
+	result := results[0]
+	if len(result.Errors) > 0 {
+		return "", toRecordError(result.Errors)
+	}
+	if !result.Success {
+		return "", fmt.Errorf("failed to create note, with no errors in the response")
+	}
+	if result.Id == "" {
+		return "", fmt.Errorf("created note but the response has no id")
+	}
+	return result.Id, nil
+}
+
 func (c *Client) UpsertContentVersion(cv *sfenterprise.ContentVersion) (string, error) {
 	return c.upsert(salesforce.ObjectType_ContentVersion, cv)
 }
@@ -83,7 +118,7 @@ func (c *Client) UpsertContentDocumentLink(value *sfenterprise.ContentDocumentLi
 		Type:   ots,
 		Fields: fields,
 	}
-	results, err := c.gc.EnterpriseClient.Create([]*soapforce.SObject{sobj})
+	results, err := c.soap.Create([]*soapforce.SObject{sobj})
 	if err != nil {
 		return "", fmt.Errorf("generally creating cdl: %w", err)
 	}
@@ -92,7 +127,7 @@ func (c *Client) UpsertContentDocumentLink(value *sfenterprise.ContentDocumentLi
 	}
 	result := results[0]
 	if len(result.Errors) > 0 {
-		return "", fmt.Errorf("errors found in response - see %+v", result.Errors)
+		return "", toRecordError(result.Errors)
 	}
 	if !result.Success {
 		return "", fmt.Errorf("failed to upsert field - see response")
@@ -108,67 +143,96 @@ func (c *Client) upsert(sot salesforce.ObjectType, value any) (string, error) {
 }
 
 func (c *Client) upsertWithOptionalRetry(sot salesforce.ObjectType, value any, optionalRetry bool, deleteCreates bool) (string, error) {
+	sobj, fieldKey, err := toSObject(sot, value, deleteCreates)
+	if err != nil {
+		return "", err
+	}
+	results, err := c.soap.Upsert([]*soapforce.SObject{sobj}, fieldKey)
+	if err != nil {
+		return "", fmt.Errorf("generally upserting %s: %w", sot, err)
+	}
+	if len(results) != 1 {
+		return "", fmt.Errorf("expected 1 result, got %d", len(results))
+	}
+	return c.handleUpsertResult(sot, value, results[0], optionalRetry)
+}
+
+// toSObject converts a struct to the SObject that's sent to Salesforce, along
+// with the name of the external ID field that it's upserted on.
+func toSObject(sot salesforce.ObjectType, value any, deleteCreates bool) (*soapforce.SObject, string, error) {
 	ots, err := sot.SalesforceName()
 	if err != nil {
-		return "", fmt.Errorf("getting salesforce name: %w", err)
+		return nil, "", fmt.Errorf("getting salesforce name: %w", err)
 	}
 	fieldKey, err := sot.SalesforceObjectExternalFieldKey()
 	if err != nil {
-		return "", fmt.Errorf("getting salesforce object external field key: %w", err)
+		return nil, "", fmt.Errorf("getting salesforce object external field key: %w", err)
 	}
 	fields, err := StructToFieldsMap(value)
 	if err != nil {
-		return "", fmt.Errorf("converting struct to map: %w", err)
+		return nil, "", fmt.Errorf("converting struct to map: %w", err)
 	}
 	if deleteCreates {
-		delete(fields, "CreatedDate")
-		delete(fields, "CreatedById")
-		delete(fields, "LastModifiedDate")
-		delete(fields, "LastModifiedById")
-		delete(fields, "CompletedDateTime")
-		delete(fields, "npe4__Contact__c")
-		delete(fields, "npe4__RelatedContact__c")
-		delete(fields, "npe5__Organization__c")
-		delete(fields, "npe5__Contact__c")
-		delete(fields, "ContactId")
+		DeleteCreateOnlyFields(fields)
 	}
 	key, ok := fields[fieldKey]
 	if !ok {
-		return "", fmt.Errorf("field key %s not found in fields", fieldKey)
+		return nil, "", fmt.Errorf("field key %s not found in fields", fieldKey)
 	}
 	keyAsStr, ok := key.(string)
 	if !ok {
-		return "", fmt.Errorf("field key %s is not a string, it is a %T", fieldKey, key)
+		return nil, "", fmt.Errorf("field key %s is not a string, it is a %T", fieldKey, key)
 	}
 	if keyAsStr == "" {
-		return "", fmt.Errorf("field key %s is empty", fieldKey)
+		return nil, "", fmt.Errorf("field key %s is empty", fieldKey)
 	}
 	sobj := &soapforce.SObject{
 		Type:   ots,
 		Fields: fields,
 	}
-	results, err := c.gc.EnterpriseClient.Upsert([]*soapforce.SObject{sobj}, fieldKey)
-	if err != nil {
-		return "", fmt.Errorf("generally upserting %s: %w", sot, err)
-	}
-	if len(results) != 1 {
-		return "", fmt.Errorf("expected 1 result, got %d", len(results))
-	}
-	tmpFile, err := utils.WriteValueToTempJSONFile(results, fmt.Sprintf("upsert-%s-error", sot))
-	if err != nil {
-		return "", fmt.Errorf("writing response message: %w", err)
+	return sobj, fieldKey, nil
+}
+
+// createOnlyFields can be set when a record is created, but not when it's
+// updated, so they're left out when an upsert of an existing record fails
+// because of them.
+var createOnlyFields = []string{
+	"CreatedDate",
+	"CreatedById",
+	"LastModifiedDate",
+	"LastModifiedById",
+	"CompletedDateTime",
+	"npe4__Contact__c",
+	"npe4__RelatedContact__c",
+	"npe5__Organization__c",
+	"npe5__Contact__c",
+	"ContactId",
+}
+
+// DeleteCreateOnlyFields removes the fields that can't be updated from the
+// fields of a record.
+func DeleteCreateOnlyFields(fields map[string]any) {
+	for _, f := range createOnlyFields {
+		delete(fields, f)
 	}
-	result := results[0]
+}
+
+// handleUpsertResult interprets the result of upserting a single record, which
+// can mean deleting duplicates and retrying, or retrying without the fields
+// that can only be set on creation.
+func (c *Client) handleUpsertResult(sot salesforce.ObjectType, value any, result *soapforce.UpsertResult, optionalRetry bool) (string, error) {
 	if len(result.Errors) > 0 {
 		err0 := result.Errors[0]
-		if *err0.StatusCode == "DUPLICATE_EXTERNAL_ID" {
-			m := err0.Message
-			m = m[strings.Index(m, "[")+1 : strings.Index(m, "]")]
+		// Salesforce lists the records that share the key, unless the key was
+		// sent more than once in the same call, which can't be fixed here.
+		start, end := strings.Index(err0.Message, "["), strings.Index(err0.Message, "]")
+		if *err0.StatusCode == "DUPLICATE_EXTERNAL_ID" && start >= 0 && end > start {
+			m := err0.Message[start+1 : end]
 			splits := strings.Split(m, ",")
 			for i, split := range splits {
 				splits[i] = strings.TrimSpace(split)
 			}
-			resp, err := c.gc.EnterpriseClient.Delete(splits)
+			resp, err := c.soap.Delete(splits)
 			if err != nil {
 				return "", fmt.Errorf("trying to delete duplicates: %w", err)
 			}
@@ -186,7 +250,7 @@ func (c *Client) upsertWithOptionalRetry(sot salesforce.ObjectType, value any, o
 			/*
 					if strings.Contains(err0.Message, "Unable to create/update fields") && (strings.Contains(err0.Message, "CreatedDate") || strings.Contains(err0.Message, "LastModifiedDate")) {
 
-					results, err := c.gc.EnterpriseClient.Create([]*soapforce.SObject{sobj})
+					results, err := c.soap.Create([]*soapforce.SObject{sobj})
 					if err != nil {
 						return "", fmt.Errorf("generally creating %s: %w", sot, err)
 					}
@@ -200,13 +264,29 @@ func (c *Client) upsertWithOptionalRetry(sot salesforce.ObjectType, value any, o
 				return c.upsertWithOptionalRetry(sot, value, false, true)
 			}
 		}
-		return "", fmt.Errorf("errors found in response - see %s", tmpFile)
+		return "", toRecordError(result.Errors)
 	}
 	if !result.Success {
-		return "", fmt.Errorf("failed to upsert field - see %s", tmpFile)
+		return "", fmt.Errorf("failed to upsert %s, with no errors in the response", sot)
 	}
 	if result.Id == "" {
-		return "", fmt.Errorf("id is empty - see %s", tmpFile)
+		return "", fmt.Errorf("upserted %s but the response has no id", sot)
 	}
 	return result.Id, nil
 }
+
+// toRecordError converts the errors that Salesforce returned for a record into
+// a RecordError, so that they can be grouped by status code and field.
+func toRecordError(errs []*soapforce.Error) *salesforce.RecordError {
+	converted := make([]*salesforce.RecordError, len(errs))
+	for i, e := range errs {
+		re := &salesforce.RecordError{Message: e.Message, Fields: e.Fields}
+		if e.StatusCode != nil {
+			re.StatusCode = string(*e.StatusCode)
+		}
+		converted[i] = re
+	}
+	result := converted[0]
+	result.More = converted[1:]
+	return result
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Silicon-Ally/etap2sf/salesforce"
+	"github.com/tzmfreedom/go-soapforce"
+)
+
+// maxSOAPBatchSize is the most records that the SOAP API accepts in a single
+// create or upsert call.
+const maxSOAPBatchSize = 200
+
+func (c *Client) MaxBatchSize() int {
+	return maxSOAPBatchSize
+}
+
+// UpsertBatch upserts up to 200 records of the same type in a single call. The
+// returned IDs and errors line up with the records - each record either has a
+// Salesforce ID or an error. Records that fail in ways that upsert handles
+// (duplicate external IDs, fields that can't be updated) are retried on their
+// own, exactly as they would be if they were upserted one at a time. The
+// overall error is only non-nil if the call itself failed. SOAP calls can't be
+// cancelled, so the context is only checked before the call is made.
+func (c *Client) UpsertBatch(ctx context.Context, sot salesforce.ObjectType, records []any) ([]string, []error, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, context.Cause(ctx)
+	}
+	if len(records) > maxSOAPBatchSize {
+		return nil, nil, fmt.Errorf("can upsert at most %d records per call, got %d", maxSOAPBatchSize, len(records))
+	}
+	if sot == salesforce.ObjectType_ContentDocumentLink {
+		return c.createContentDocumentLinkBatch(records)
+	}
+	ids := make([]string, len(records))
+	errs := make([]error, len(records))
+	sobjs := []*soapforce.SObject{}
+	indexes := []int{}
+	fieldKey := ""
+	for i, record := range records {
+		sobj, fk, err := toSObject(sot, record, false)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		fieldKey = fk
+		sobjs = append(sobjs, sobj)
+		indexes = append(indexes, i)
+	}
+	if len(sobjs) == 0 {
+		return ids, errs, nil
+	}
+	results, err := c.soap.Upsert(sobjs, fieldKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generally upserting batch of %d %s: %w", len(sobjs), sot, err)
+	}
+	if len(results) != len(sobjs) {
+		return nil, nil, fmt.Errorf("expected %d results, got %d", len(sobjs), len(results))
+	}
+	for j, result := range results {
+		i := indexes[j]
+		ids[i], errs[i] = c.handleUpsertResult(sot, records[i], result, true)
+	}
+	return ids, errs, nil
+}
+
+func (c *Client) createContentDocumentLinkBatch(records []any) ([]string, []error, error) {
+	ots, err := salesforce.ObjectType_ContentDocumentLink.SalesforceName()
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting salesforce name: %w", err)
+	}
+	ids := make([]string, len(records))
+	errs := make([]error, len(records))
+	sobjs := []*soapforce.SObject{}
+	indexes := []int{}
+	for i, record := range records {
+		fields, err := StructToFieldsMap(record)
+		if err != nil {
+			errs[i] = fmt.Errorf("converting struct to map: %w", err)
+			continue
+		}
+		sobjs = append(sobjs, &soapforce.SObject{
+			Type:   ots,
+			Fields: fields,
+		})
+		indexes = append(indexes, i)
+	}
+	if len(sobjs) == 0 {
+		return ids, errs, nil
+	}
+	results, err := c.soap.Create(sobjs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generally creating batch of %d cdls: %w", len(sobjs), err)
+	}
+	if len(results) != len(sobjs) {
+		return nil, nil, fmt.Errorf("expected %d results, got %d", len(sobjs), len(results))
+	}
+	for j, result := range results {
+		i := indexes[j]
+		if len(result.Errors) > 0 {
+			errs[i] = toRecordError(result.Errors)
+		} else if !result.Success {
+			errs[i] = fmt.Errorf("failed to create cdl - see response")
+		} else if result.Id == "" {
+			errs[i] = fmt.Errorf("id is empty")
+		} else {
+			ids[i] = result.Id
+		}
+	}
+	return ids, errs, nil
+}
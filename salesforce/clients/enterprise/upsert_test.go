@@ -0,0 +1,340 @@
+package client
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Silicon-Ally/etap2sf/salesforce"
+	"github.com/Silicon-Ally/etap2sf/salesforce/fakesf"
+	"github.com/Silicon-Ally/etap2sf/salesforce/generated/sfenterprise"
+	"github.com/hooklift/gowsdl/soap"
+)
+
+func newFakeClient(t *testing.T) (*fakesf.Server, *Client) {
+	t.Helper()
+	s := fakesf.New()
+	t.Cleanup(s.Close)
+	c, err := New(&Config{ConnConfig: s.ConnConfig(), APIVersion: "58.0"})
+	if err != nil {
+		t.Fatalf("creating client: %v", err)
+	}
+	return s, c
+}
+
+func ptr[T any](t T) *T {
+	return &t
+}
+
+// defineRelationship declares relationships with a contact lookup that can
+// only be set when they're created, as NPSP does.
+func defineRelationship(s *fakesf.Server) {
+	s.Define(&fakesf.Object{
+		Name: "npe4__Relationship__c",
+		Fields: []*fakesf.Field{
+			{Name: "etap_Relationship_Ref__c", ExternalID: true},
+			{Name: "npe4__Contact__c", ReferenceTo: "Contact", CreateOnly: true},
+		},
+	})
+}
+
+func TestUpsert_DeletesDuplicatesAndRetries(t *testing.T) {
+	s, c := newFakeClient(t)
+	// Two records with the same external ID, as a half-finished earlier run
+	// without the field being unique could leave behind.
+	first := s.Insert("Account", map[string]string{salesforce.MultiObjectExternalFieldKey: "1.0.1"})
+	second := s.Insert("Account", map[string]string{salesforce.MultiObjectExternalFieldKey: "1.0.1"})
+
+	id, err := c.UpsertAccount(&sfenterprise.Account{Etap_MultiObject_EtapRef__c: ptr("1.0.1")})
+	if err != nil {
+		t.Fatalf("UpsertAccount: %v", err)
+	}
+	if id == first || id == second {
+		t.Errorf("UpsertAccount = %s, want a new record in place of the duplicates", id)
+	}
+	records := s.Records("Account")
+	if len(records) != 1 || records[0]["Id"] != id {
+		t.Errorf("accounts = %v, want just %s", records, id)
+	}
+	if got := s.Calls("delete"); got != 1 {
+		t.Errorf("delete calls = %d, want 1", got)
+	}
+}
+
+func TestUpsert_InjectedDuplicateIsDeleted(t *testing.T) {
+	s, c := newFakeClient(t)
+	dupe := s.Insert("Account", map[string]string{salesforce.MultiObjectExternalFieldKey: "1.0.2-old"})
+	s.Inject(&fakesf.Injection{
+		Operation:  "upsert",
+		ObjectType: "Account",
+		StatusCode: "DUPLICATE_EXTERNAL_ID",
+		Message:    fmt.Sprintf("etap_MultiObject_EtapRef__c: more than one record found for external id field: [%s]", dupe),
+		Times:      1,
+	})
+
+	if _, err := c.UpsertAccount(&sfenterprise.Account{Etap_MultiObject_EtapRef__c: ptr("1.0.2")}); err != nil {
+		t.Fatalf("UpsertAccount: %v", err)
+	}
+	if _, ok := s.Record(dupe); ok {
+		t.Errorf("duplicate %s wasn't deleted", dupe)
+	}
+	if got := s.Calls("upsert"); got != 2 {
+		t.Errorf("upsert calls = %d, want 2", got)
+	}
+}
+
+func TestUpsert_RetriesWithoutCreateOnlyFields(t *testing.T) {
+	s, c := newFakeClient(t)
+	defineRelationship(s)
+	contact := s.Insert("Contact", map[string]string{"etap_Account_Ref__c": "1.0.3"})
+	rel := &sfenterprise.Npe4__Relationship__c{
+		Etap_Relationship_Ref__c: ptr("1.0.4-1of2"),
+		Npe4__Contact__c:         ptr(sfenterprise.ID(contact)),
+	}
+
+	created, err := c.UpsertRelationship(rel)
+	if err != nil {
+		t.Fatalf("creating relationship: %v", err)
+	}
+	updated, err := c.UpsertRelationship(rel)
+	if err != nil {
+		t.Fatalf("updating relationship: %v", err)
+	}
+	if updated != created {
+		t.Errorf("updated %s, want %s", updated, created)
+	}
+	// The update fails because of the contact, and is retried without it.
+	if got := s.Calls("upsert"); got != 3 {
+		t.Errorf("upsert calls = %d, want 3", got)
+	}
+	record, _ := s.Record(created)
+	if record["npe4__Contact__c"] != contact {
+		t.Errorf("contact = %q, want %q", record["npe4__Contact__c"], contact)
+	}
+}
+
+func TestUpsertBatch_RetriesWithoutCreateOnlyFields(t *testing.T) {
+	s, c := newFakeClient(t)
+	defineRelationship(s)
+	contact := s.Insert("Contact", map[string]string{"etap_Account_Ref__c": "1.0.5"})
+	existing := &sfenterprise.Npe4__Relationship__c{
+		Etap_Relationship_Ref__c: ptr("1.0.6-1of2"),
+		Npe4__Contact__c:         ptr(sfenterprise.ID(contact)),
+	}
+	existingID, err := c.UpsertRelationship(existing)
+	if err != nil {
+		t.Fatalf("creating relationship: %v", err)
+	}
+	created := &sfenterprise.Npe4__Relationship__c{
+		Etap_Relationship_Ref__c: ptr("1.0.6-2of2"),
+		Npe4__Contact__c:         ptr(sfenterprise.ID(contact)),
+	}
+
+	ids, errs, err := c.UpsertBatch(context.Background(), salesforce.ObjectType_Relationship, []any{existing, created})
+	if err != nil {
+		t.Fatalf("UpsertBatch: %v", err)
+	}
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("record %d: %v", i, err)
+		}
+	}
+	if ids[0] != existingID || ids[1] == "" {
+		t.Errorf("ids = %v, want %s and a new id", ids, existingID)
+	}
+	if got := len(s.Records("npe4__Relationship__c")); got != 2 {
+		t.Errorf("relationships = %d, want 2", got)
+	}
+}
+
+func TestUpsertBatch_ExternalKeyCollisions(t *testing.T) {
+	s, c := newFakeClient(t)
+	// External IDs are case insensitive, so these are the same record to
+	// Salesforce, and can't both be upserted in one call.
+	records := []any{
+		&sfenterprise.Campaign{Etap_MultiObject_EtapRef__c: ptr("ABC")},
+		&sfenterprise.Campaign{Etap_MultiObject_EtapRef__c: ptr("abc")},
+		&sfenterprise.Campaign{Etap_MultiObject_EtapRef__c: ptr("def")},
+	}
+
+	ids, errs, err := c.UpsertBatch(context.Background(), salesforce.ObjectType_Campaign, records)
+	if err != nil {
+		t.Fatalf("UpsertBatch: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		re, ok := salesforce.AsRecordError(errs[i])
+		if !ok || re.StatusCode != "DUPLICATE_EXTERNAL_ID" {
+			t.Errorf("record %d: error = %v, want DUPLICATE_EXTERNAL_ID", i, errs[i])
+		}
+	}
+	if errs[2] != nil || ids[2] == "" {
+		t.Errorf("record 2: id %q, error %v, want it upserted", ids[2], errs[2])
+	}
+	if got := len(s.Records("Campaign")); got != 1 {
+		t.Errorf("campaigns = %d, want 1", got)
+	}
+}
+
+func TestUpsertBatch_InjectedRecordErrors(t *testing.T) {
+	s, c := newFakeClient(t)
+	s.Inject(&fakesf.Injection{
+		Operation:  "upsert",
+		ObjectType: "Campaign",
+		Match:      func(fields map[string]string) bool { return fields[salesforce.MultiObjectExternalFieldKey] == "2" },
+		StatusCode: "FIELD_CUSTOM_VALIDATION_EXCEPTION",
+		Message:    "campaigns need a name",
+	})
+	records := []any{
+		&sfenterprise.Campaign{Etap_MultiObject_EtapRef__c: ptr("1")},
+		&sfenterprise.Campaign{Etap_MultiObject_EtapRef__c: ptr("2")},
+	}
+
+	ids, errs, err := c.UpsertBatch(context.Background(), salesforce.ObjectType_Campaign, records)
+	if err != nil {
+		t.Fatalf("UpsertBatch: %v", err)
+	}
+	if errs[0] != nil || ids[0] == "" {
+		t.Errorf("record 0: id %q, error %v, want it upserted", ids[0], errs[0])
+	}
+	re, ok := salesforce.AsRecordError(errs[1])
+	if !ok || re.StatusCode != "FIELD_CUSTOM_VALIDATION_EXCEPTION" || re.Message != "campaigns need a name" {
+		t.Errorf("record 1: error = %v, want the injected error", errs[1])
+	}
+}
+
+func TestGetRecordsByExternalKey_PagesThroughQueryMore(t *testing.T) {
+	s, c := newFakeClient(t)
+	s.QueryBatchSize = 2
+	ids := map[string]string{}
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("1.0.%d", i)
+		ids[key] = s.Insert("Account", map[string]string{salesforce.MultiObjectExternalFieldKey: key})
+	}
+	// Records without an external key aren't the migration's.
+	s.Insert("Account", map[string]string{"Name": "Someone else's"})
+
+	records, err := c.GetRecordsByExternalKey(salesforce.ObjectType_Account, nil)
+	if err != nil {
+		t.Fatalf("GetRecordsByExternalKey: %v", err)
+	}
+	if len(records) != len(ids) {
+		t.Errorf("got %d records, want %d", len(records), len(ids))
+	}
+	for key, id := range ids {
+		if records[key]["id"] != id {
+			t.Errorf("record %s = %v, want id %s", key, records[key], id)
+		}
+	}
+	if got := s.Calls("queryMore"); got != 2 {
+		t.Errorf("queryMore calls = %d, want 2", got)
+	}
+}
+
+func TestQueryRows_PagesThroughQueryMore(t *testing.T) {
+	s, c := newFakeClient(t)
+	s.QueryBatchSize = 2
+	for i := 0; i < 3; i++ {
+		s.Insert("Campaign", map[string]string{"Name": fmt.Sprintf("Campaign %d", i)})
+	}
+
+	rows, err := c.QueryRows("SELECT Id, Name FROM Campaign")
+	if err != nil {
+		t.Fatalf("QueryRows: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(rows))
+	}
+	for i, row := range rows {
+		if want := fmt.Sprintf("Campaign %d", i); row["name"] != want || row["id"] == "" {
+			t.Errorf("row %d = %v, want name %q and an id", i, row, want)
+		}
+	}
+	if got := s.Calls("queryMore"); got != 1 {
+		t.Errorf("queryMore calls = %d, want 1", got)
+	}
+}
+
+func TestStructToFieldsMap(t *testing.T) {
+	type record struct {
+		XMLName     xml.Name                 `xml:"urn:sobject.enterprise.soap.sforce.com Account"`
+		Name        *string                  `xml:"Name,omitempty"`
+		Empty       *string                  `xml:"Description,omitempty"`
+		ParentId    *sfenterprise.ID         `xml:"ParentId,omitempty"`
+		Active      *bool                    `xml:"Active__c,omitempty"`
+		Inactive    *bool                    `xml:"Inactive__c,omitempty"`
+		Amount      *float64                 `xml:"Amount,omitempty"`
+		Count       *int32                   `xml:"Count__c,omitempty"`
+		CloseDate   *soap.XSDDate            `xml:"CloseDate,omitempty"`
+		CreatedDate *soap.XSDDateTime        `xml:"CreatedDate,omitempty"`
+		VersionData []byte                   `xml:"VersionData,omitempty"`
+		RecordType  *sfenterprise.RecordType `xml:"RecordType,omitempty"`
+		Untagged    *string
+		unexported  string
+	}
+	created := time.Date(2023, 4, 5, 6, 7, 8, 0, time.UTC)
+	in := &record{
+		Name:        ptr("Smith Household"),
+		ParentId:    ptr(sfenterprise.ID("001000000000001AAA")),
+		Active:      ptr(true),
+		Inactive:    ptr(false),
+		Amount:      ptr(12.5),
+		Count:       ptr(int32(3)),
+		CloseDate:   ptr(soap.CreateXsdDate(time.Date(2023, 4, 5, 0, 0, 0, 0, time.UTC), false)),
+		CreatedDate: ptr(soap.CreateXsdDateTime(created, true)),
+		VersionData: []byte("aGVsbG8="),
+		RecordType:  &sfenterprise.RecordType{Id: ptr(sfenterprise.ID("012000000000001AAA"))},
+		Untagged:    ptr("untagged"),
+		unexported:  "unexported",
+	}
+
+	got, err := StructToFieldsMap(in)
+	if err != nil {
+		t.Fatalf("StructToFieldsMap: %v", err)
+	}
+	want := map[string]any{
+		"Name":         "Smith Household",
+		"ParentId":     "001000000000001AAA",
+		"Active__c":    "true",
+		"Inactive__c":  "false",
+		"Amount":       "12.500000",
+		"Count__c":     "3",
+		"CloseDate":    "2023-04-05T00:00:00Z",
+		"CreatedDate":  "2023-04-05T06:07:08Z",
+		"VersionData":  "aGVsbG8=",
+		"RecordTypeId": "012000000000001AAA",
+		"Untagged":     "untagged",
+	}
+	if len(got) != len(want) {
+		t.Errorf("got fields %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("field %s = %v, want %v", k, got[k], v)
+		}
+	}
+
+	if _, err := StructToFieldsMap("not a struct"); err == nil || !strings.Contains(err.Error(), "not a struct") {
+		t.Errorf("StructToFieldsMap of a string: error = %v, want one saying it isn't a struct", err)
+	}
+}
+
+func TestUpsert_SendsFieldsByTheirAPINames(t *testing.T) {
+	s, c := newFakeClient(t)
+	// Declaring the object makes fakesf reject fields it doesn't have.
+	s.Define(&fakesf.Object{
+		Name:   "Campaign",
+		Fields: []*fakesf.Field{{Name: salesforce.MultiObjectExternalFieldKey, ExternalID: true}},
+	})
+
+	id, err := c.UpsertCampaign(&sfenterprise.Campaign{Etap_MultiObject_EtapRef__c: ptr("1.0.7")})
+	if err != nil {
+		t.Fatalf("UpsertCampaign: %v", err)
+	}
+	record, ok := s.Record(id)
+	if !ok || record[salesforce.MultiObjectExternalFieldKey] != "1.0.7" {
+		t.Errorf("campaign = %v, want its external key in %s", record, salesforce.MultiObjectExternalFieldKey)
+	}
+}
@@ -3,19 +3,29 @@ package utils
 import (
 	"fmt"
 
+	"github.com/Silicon-Ally/etap2sf/logging"
 	client "github.com/Silicon-Ally/etap2sf/salesforce/clients/enterprise"
+	"github.com/Silicon-Ally/etap2sf/salesforce/profile"
 	"github.com/Silicon-Ally/etap2sf/secrets"
 )
 
+// NewSandboxClient logs into the active profile's org, which (despite the name)
+// isn't necessarily a sandbox.
 func NewSandboxClient() (*client.Client, error) {
-	connConfig, err := secrets.GetSalesforceConnectionConfig()
+	p, err := profile.Active()
 	if err != nil {
-		return nil, fmt.Errorf("getting salesforce sandbox credentials: %w", err)
+		return nil, err
 	}
+	connConfig, err := secrets.GetSalesforceLogin()
+	if err != nil {
+		return nil, fmt.Errorf("getting salesforce credentials for profile %q: %w", p.Name, err)
+	}
+	logging.For("salesforce").Debug("creating enterprise client", "profile", p.Name, "production", p.Production)
 	client, err := client.New(&client.Config{
 		APIVersion: "58.0",
 		Debug:      true,
 		ConnConfig: connConfig,
+		Production: p.Production,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("creating client: %w", err)
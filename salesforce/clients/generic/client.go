@@ -17,6 +17,12 @@ type Client struct {
 	MetadataClient     *metaforce.Client
 	MetadataSOAPClient *soapforce.SOAPClient
 	IDMap              map[string]string
+
+	// These come from logging in, and are used by clients that talk to the
+	// REST APIs directly, like the bulk client.
+	APIVersion string
+	ServerURL  string
+	SessionID  string
 }
 
 type ConnConfig interface {
@@ -26,6 +32,13 @@ type ConnConfig interface {
 	GetLoginURL() string
 }
 
+// SessionProvider is implemented by connection configs that log in some other
+// way than with a username and password (like OAuth, see the oauth package),
+// and hand over a session for the clients to use.
+type SessionProvider interface {
+	Session() (instanceURL, accessToken string, err error)
+}
+
 type Config struct {
 	ConnConfig ConnConfig
 	APIVersion string
@@ -33,6 +46,9 @@ type Config struct {
 }
 
 func New(c *Config) (*Client, error) {
+	if sp, ok := c.ConnConfig.(SessionProvider); ok {
+		return newFromSession(c, sp)
+	}
 	username := c.ConnConfig.GetUsername()
 	password := c.ConnConfig.GetPassword()
 	securityToken := c.ConnConfig.GetSecurityToken()
@@ -66,7 +82,8 @@ func New(c *Config) (*Client, error) {
 	e.SetApiVersion(c.APIVersion)
 	e.SetDebug(c.Debug)
 	e.SetLoginUrl(loginURL)
-	if _, err := e.Login(username, password+securityToken); err != nil {
+	loginResult, err := e.Login(username, password+securityToken)
+	if err != nil {
 		return nil, fmt.Errorf("failed to login to soapforce client: %w", err)
 	}
 
@@ -76,6 +93,52 @@ func New(c *Config) (*Client, error) {
 		MetadataClient:     m,
 		EnterpriseClient:   e,
 		MetadataSOAPClient: s,
+		APIVersion:         c.APIVersion,
+		ServerURL:          loginResult.ServerUrl,
+		SessionID:          loginResult.SessionId,
+	}, nil
+}
+
+// newFromSession sets up the clients with a session from the connection
+// config, instead of logging them in.
+func newFromSession(c *Config, sp SessionProvider) (*Client, error) {
+	if c.APIVersion == "" {
+		return nil, fmt.Errorf("api version is required")
+	}
+	instanceURL, accessToken, err := sp.Session()
+	if err != nil {
+		return nil, fmt.Errorf("getting session: %w", err)
+	}
+	u, err := url.Parse(instanceURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("parsing instance url %q: %v", instanceURL, err)
+	}
+	serverURL := fmt.Sprintf("%s://%s/services/Soap/u/%s", u.Scheme, u.Host, c.APIVersion)
+
+	m := metaforce.NewClient()
+	m.SetApiVersion(c.APIVersion)
+	m.SetDebug(c.Debug)
+	// metaforce only sets its server URL when it logs in, or from the login
+	// host, which it puts between https:// and /services/Soap/u/<version>. The
+	// fragment swallows the suffix, so requests go to the metadata endpoint.
+	m.SetLoginUrl(fmt.Sprintf("%s/services/Soap/m/%s#", u.Host, c.APIVersion))
+	m.SetAccessToken(accessToken)
+
+	e := soapforce.NewClient()
+	e.SetApiVersion(c.APIVersion)
+	e.SetDebug(c.Debug)
+	e.SetServerUrl(serverURL)
+	e.SetAccessToken(accessToken)
+
+	s := soapforce.NewSOAPClient(fmt.Sprintf("https://login.salesforce.com/services/Soap/u/%s", c.APIVersion), true, nil)
+
+	return &Client{
+		MetadataClient:     m,
+		EnterpriseClient:   e,
+		MetadataSOAPClient: s,
+		APIVersion:         c.APIVersion,
+		ServerURL:          serverURL,
+		SessionID:          accessToken,
 	}, nil
 }
 
@@ -101,7 +164,7 @@ func (c *Client) DownloadMetadataWSDL(toFilePath string) error {
 	req.Header.Set("Accept-Language", `en-US,en;q=0.9`)
 	req.Header.Set("Cache-Control", `max-age=0`)
 	req.Header.Set("Connection", `keep-alive`)
-	req.Header.Set("Cookie", fmt.Sprintf(`cleared-onetrust-cookies=; sid=%s;`, c.MetadataClient.GetSessionID()))
+	req.Header.Set("Cookie", fmt.Sprintf(`cleared-onetrust-cookies=; sid=%s;`, c.SessionID))
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
@@ -153,7 +216,7 @@ func (c *Client) DownloadEnterpriseWSDL(toFilePath string) error {
 	req.Header.Set("Accept-Language", `en-US,en;q=0.9`)
 	req.Header.Set("Cache-Control", `max-age=0`)
 	req.Header.Set("Connection", `keep-alive`)
-	req.Header.Set("Cookie", fmt.Sprintf(`cleared-onetrust-cookies=; sid=%s;`, c.MetadataClient.GetSessionID()))
+	req.Header.Set("Cookie", fmt.Sprintf(`cleared-onetrust-cookies=; sid=%s;`, c.SessionID))
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
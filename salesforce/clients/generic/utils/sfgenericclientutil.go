@@ -3,15 +3,22 @@ package utils
 import (
 	"fmt"
 
+	"github.com/Silicon-Ally/etap2sf/logging"
 	genericclient "github.com/Silicon-Ally/etap2sf/salesforce/clients/generic"
+	"github.com/Silicon-Ally/etap2sf/salesforce/profile"
 	"github.com/Silicon-Ally/etap2sf/secrets"
 )
 
 func NewGenericClient() (*genericclient.Client, error) {
-	connConfig, err := secrets.GetSalesforceConnectionConfig()
+	p, err := profile.Active()
 	if err != nil {
-		return nil, fmt.Errorf("getting salesforce sandbox credentials: %w", err)
+		return nil, err
 	}
+	connConfig, err := secrets.GetSalesforceLogin()
+	if err != nil {
+		return nil, fmt.Errorf("getting salesforce credentials for profile %q: %w", p.Name, err)
+	}
+	logging.For("salesforce").Debug("creating generic client", "profile", p.Name, "production", p.Production)
 	client, err := genericclient.New(&genericclient.Config{
 		APIVersion: "58.0",
 		Debug:      true,
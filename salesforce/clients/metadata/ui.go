@@ -27,7 +27,7 @@ func (c *Client) CreateETapestrySectionsOnFlexiPages() error {
 	}
 
 	for _, sot := range salesforce.ObjectTypes {
-		if sot == salesforce.ObjectType_ContentDocumentLink || sot == salesforce.ObjectType_ContentVersion {
+		if sot == salesforce.ObjectType_ContentDocumentLink || sot == salesforce.ObjectType_ContentVersion || sot == salesforce.ObjectType_ContentNote {
 			// These aren't even VISIBLE.
 			// We store the additional info in the AdditionalContext object.
 			continue
@@ -202,6 +202,9 @@ func createApexPageForSalesforceObjectType(sot salesforce.ObjectType) (string, e
 	if len(errs) > 0 {
 		return "", fmt.Errorf("found %d errors, the first one: %w", len(errs), errs[0])
 	}
+	if err := conversionsettings.Load(); err != nil {
+		return "", err
+	}
 	fieldsGroupedByLabel := map[string][]*sfmetadata.CustomField{}
 	for _, field := range fields {
 		splits := strings.Split(field.Label, ":")
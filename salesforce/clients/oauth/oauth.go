@@ -0,0 +1,206 @@
+// Package oauth logs into Salesforce through a connected app, with the OAuth
+// 2.0 JWT bearer flow or the client credentials flow, rather than with a
+// username, password and security token. Orgs that block SOAP password login
+// still allow these, and they don't break when a password changes.
+//
+// A *Config is a connection config for genericclient.New, which uses the
+// session it gets back instead of logging in itself.
+package oauth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type Flow string
+
+const (
+	Flow_JWTBearer         Flow = "jwt"
+	Flow_ClientCredentials Flow = "client_credentials"
+)
+
+const (
+	defaultAudience = "https://login.salesforce.com"
+	sandboxAudience = "https://test.salesforce.com"
+	// assertionLifetime is how long the JWT is valid for. Salesforce allows
+	// at most three minutes.
+	assertionLifetime = 3 * time.Minute
+)
+
+type Config struct {
+	Flow Flow
+	// ClientID is the consumer key of the connected app.
+	ClientID string
+	// ClientSecret is the consumer secret, for the client credentials flow.
+	ClientSecret string
+	// Username is the user that the JWT bearer flow logs in as. The client
+	// credentials flow logs in as the app's "run as" user.
+	Username string
+	// PrivateKeyPEM is the key whose certificate was uploaded to the
+	// connected app, for the JWT bearer flow.
+	PrivateKeyPEM []byte
+	// LoginURL is the org's login host, like yourorg.my.salesforce.com.
+	LoginURL string
+	// TokenURL is the token endpoint. It defaults to
+	// https://<LoginURL>/services/oauth2/token.
+	TokenURL string
+	// Audience is the aud claim of the JWT. It defaults to
+	// https://test.salesforce.com for sandboxes, and
+	// https://login.salesforce.com otherwise.
+	Audience string
+	// HTTPClient defaults to one with a one minute timeout.
+	HTTPClient *http.Client
+}
+
+// These make the config usable wherever a username and password one is.
+func (c *Config) GetUsername() string      { return c.Username }
+func (c *Config) GetPassword() string      { return "" }
+func (c *Config) GetSecurityToken() string { return "" }
+func (c *Config) GetLoginURL() string      { return c.LoginURL }
+
+func (c *Config) tokenURL() string {
+	if c.TokenURL != "" {
+		return c.TokenURL
+	}
+	return "https://" + strings.TrimSuffix(c.LoginURL, "/") + "/services/oauth2/token"
+}
+
+func (c *Config) audience() string {
+	if c.Audience != "" {
+		return c.Audience
+	}
+	if strings.Contains(c.LoginURL, "test.salesforce.com") || strings.Contains(c.LoginURL, ".sandbox.") {
+		return sandboxAudience
+	}
+	return defaultAudience
+}
+
+func (c *Config) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return &http.Client{Timeout: time.Minute}
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	InstanceURL      string `json:"instance_url"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Session requests an access token, returning it along with the URL of the
+// instance that it's for, like https://yourorg.my.salesforce.com.
+func (c *Config) Session() (instanceURL, accessToken string, err error) {
+	if c.ClientID == "" {
+		return "", "", fmt.Errorf("client id is required")
+	}
+	if c.TokenURL == "" && c.LoginURL == "" {
+		return "", "", fmt.Errorf("login url or token url is required")
+	}
+	form := url.Values{}
+	switch c.Flow {
+	case Flow_JWTBearer:
+		assertion, err := c.assertion(time.Now())
+		if err != nil {
+			return "", "", fmt.Errorf("creating jwt assertion: %w", err)
+		}
+		form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+		form.Set("assertion", assertion)
+	case Flow_ClientCredentials:
+		if c.ClientSecret == "" {
+			return "", "", fmt.Errorf("client secret is required for the %s flow", c.Flow)
+		}
+		form.Set("grant_type", "client_credentials")
+		form.Set("client_id", c.ClientID)
+		form.Set("client_secret", c.ClientSecret)
+	default:
+		return "", "", fmt.Errorf("unknown oauth flow %q, expected %q or %q", c.Flow, Flow_JWTBearer, Flow_ClientCredentials)
+	}
+
+	resp, err := c.httpClient().PostForm(c.tokenURL(), form)
+	if err != nil {
+		return "", "", fmt.Errorf("requesting token from %s: %w", c.tokenURL(), err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", "", fmt.Errorf("reading token response: %w", err)
+	}
+	tr := &tokenResponse{}
+	if err := json.Unmarshal(data, tr); err != nil {
+		return "", "", fmt.Errorf("unmarshalling token response with status %d: %w (body %q)", resp.StatusCode, err, string(data))
+	}
+	if resp.StatusCode != http.StatusOK || tr.Error != "" {
+		return "", "", fmt.Errorf("token request failed with status %d: %s: %s", resp.StatusCode, tr.Error, tr.ErrorDescription)
+	}
+	if tr.AccessToken == "" || tr.InstanceURL == "" {
+		return "", "", fmt.Errorf("token response is missing the access token or instance url")
+	}
+	return strings.TrimSuffix(tr.InstanceURL, "/"), tr.AccessToken, nil
+}
+
+// assertion is the signed JWT that the JWT bearer flow exchanges for a token.
+func (c *Config) assertion(now time.Time) (string, error) {
+	if c.Username == "" {
+		return "", fmt.Errorf("username is required for the %s flow", c.Flow)
+	}
+	key, err := parsePrivateKey(c.PrivateKeyPEM)
+	if err != nil {
+		return "", err
+	}
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", fmt.Errorf("marshalling header: %w", err)
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iss": c.ClientID,
+		"sub": c.Username,
+		"aud": c.audience(),
+		"exp": now.Add(assertionLifetime).Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("marshalling claims: %w", err)
+	}
+	enc := base64.RawURLEncoding
+	signingInput := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("signing: %w", err)
+	}
+	return signingInput + "." + enc.EncodeToString(sig), nil
+}
+
+// parsePrivateKey reads an RSA key in either PKCS #1 ("RSA PRIVATE KEY") or
+// PKCS #8 ("PRIVATE KEY") form, which is what openssl produces.
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("private key isn't PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is a %T, but the jwt bearer flow needs an RSA key", parsed)
+	}
+	return key, nil
+}
@@ -0,0 +1,190 @@
+package oauth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tokenServer is a token endpoint that checks each request with check, and
+// responds with status and body.
+func tokenServer(t *testing.T, check func(form url.Values), status int, body string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parsing form: %v", err)
+		}
+		check(r.PostForm)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func generateKey(t *testing.T) (*rsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshalling key: %v", err)
+	}
+	return key, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+const okResponse = `{"access_token": "token", "instance_url": "https://example.my.salesforce.com/"}`
+
+func TestSession_JWTBearer(t *testing.T) {
+	key, keyPEM := generateKey(t)
+	var assertion string
+	srv := tokenServer(t, func(form url.Values) {
+		if got, want := form.Get("grant_type"), "urn:ietf:params:oauth:grant-type:jwt-bearer"; got != want {
+			t.Errorf("grant_type = %q, want %q", got, want)
+		}
+		assertion = form.Get("assertion")
+	}, http.StatusOK, okResponse)
+
+	c := &Config{
+		Flow:          Flow_JWTBearer,
+		ClientID:      "consumer-key",
+		Username:      "admin@example.org",
+		PrivateKeyPEM: keyPEM,
+		LoginURL:      "example--uat.sandbox.my.salesforce.com",
+		TokenURL:      srv.URL,
+	}
+	before := time.Now()
+	instanceURL, token, err := c.Session()
+	if err != nil {
+		t.Fatalf("Session: %v", err)
+	}
+	if instanceURL != "https://example.my.salesforce.com" || token != "token" {
+		t.Errorf("Session = %q, %q, want the instance url without its trailing slash and the token", instanceURL, token)
+	}
+
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 3 {
+		t.Fatalf("assertion %q doesn't have three parts", assertion)
+	}
+	enc := base64.RawURLEncoding
+	sig, err := enc.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("decoding signature: %v", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+		t.Errorf("verifying signature: %v", err)
+	}
+
+	header := map[string]string{}
+	decodeJSON(t, parts[0], &header)
+	if header["alg"] != "RS256" || header["typ"] != "JWT" {
+		t.Errorf("header = %v, want RS256 JWT", header)
+	}
+	claims := struct {
+		Iss, Sub, Aud string
+		Exp           int64
+	}{}
+	decodeJSON(t, parts[1], &claims)
+	if claims.Iss != "consumer-key" || claims.Sub != "admin@example.org" || claims.Aud != sandboxAudience {
+		t.Errorf("claims = %+v, want the client id, username and sandbox audience", claims)
+	}
+	exp := time.Unix(claims.Exp, 0)
+	if exp.Before(before.Add(assertionLifetime).Truncate(time.Second)) || exp.After(time.Now().Add(assertionLifetime)) {
+		t.Errorf("exp = %v, want %v from now", exp, assertionLifetime)
+	}
+}
+
+func TestSession_ClientCredentials(t *testing.T) {
+	srv := tokenServer(t, func(form url.Values) {
+		want := url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {"consumer-key"},
+			"client_secret": {"consumer-secret"},
+		}
+		if form.Encode() != want.Encode() {
+			t.Errorf("form = %v, want %v", form, want)
+		}
+	}, http.StatusOK, okResponse)
+
+	c := &Config{
+		Flow:         Flow_ClientCredentials,
+		ClientID:     "consumer-key",
+		ClientSecret: "consumer-secret",
+		TokenURL:     srv.URL,
+	}
+	instanceURL, token, err := c.Session()
+	if err != nil {
+		t.Fatalf("Session: %v", err)
+	}
+	if instanceURL != "https://example.my.salesforce.com" || token != "token" {
+		t.Errorf("Session = %q, %q, want the instance url without its trailing slash and the token", instanceURL, token)
+	}
+}
+
+func TestSession_Error(t *testing.T) {
+	srv := tokenServer(t, func(url.Values) {}, http.StatusBadRequest,
+		`{"error": "invalid_grant", "error_description": "user hasn't approved this consumer"}`)
+
+	c := &Config{
+		Flow:         Flow_ClientCredentials,
+		ClientID:     "consumer-key",
+		ClientSecret: "consumer-secret",
+		TokenURL:     srv.URL,
+	}
+	_, _, err := c.Session()
+	if err == nil {
+		t.Fatal("Session succeeded, want an error")
+	}
+	for _, want := range []string{"400", "invalid_grant", "user hasn't approved this consumer"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't contain %q", err, want)
+		}
+	}
+}
+
+func TestAudience(t *testing.T) {
+	tests := []struct {
+		loginURL string
+		want     string
+	}{
+		{"example.my.salesforce.com", defaultAudience},
+		{"login.salesforce.com", defaultAudience},
+		{"test.salesforce.com", sandboxAudience},
+		{"example--uat.sandbox.my.salesforce.com", sandboxAudience},
+	}
+	for _, test := range tests {
+		c := &Config{LoginURL: test.loginURL}
+		if got := c.audience(); got != test.want {
+			t.Errorf("audience for %q = %q, want %q", test.loginURL, got, test.want)
+		}
+	}
+}
+
+func decodeJSON(t *testing.T, part string, v any) {
+	t.Helper()
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		t.Fatalf("decoding %q: %v", part, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("unmarshalling %q: %v", data, err)
+	}
+}
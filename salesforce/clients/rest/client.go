@@ -0,0 +1,129 @@
+// Package restclient uploads records through the Salesforce REST API instead
+// of SOAP. sObject Collections upsert up to 200 records of a type per call, on
+// their external ID, and Composite Graph creates related records (like an
+// opportunity along with its payments and allocations) in a single round
+// trip, pointing the children at their parent without a placeholder pass.
+package restclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Silicon-Ally/etap2sf/salesforce"
+	"github.com/Silicon-Ally/etap2sf/salesforce/apilimits"
+	client "github.com/Silicon-Ally/etap2sf/salesforce/clients/enterprise"
+)
+
+// maxCollectionSize is the most records that sObject Collections takes per call.
+const maxCollectionSize = 200
+
+// Client embeds the enterprise client, which is used for what the REST API
+// doesn't do here - binary content, notes and lookups.
+type Client struct {
+	*client.Client
+
+	httpClient  *http.Client
+	instanceURL string
+	sessionID   string
+	apiVersion  string
+}
+
+func New(ec *client.Client) (*Client, error) {
+	instanceURL, err := ec.InstanceURL()
+	if err != nil {
+		return nil, fmt.Errorf("getting instance url: %w", err)
+	}
+	if ec.SessionID() == "" {
+		return nil, fmt.Errorf("enterprise client has no session id")
+	}
+	return &Client{
+		Client:      ec,
+		httpClient:  &http.Client{Timeout: 5 * time.Minute},
+		instanceURL: instanceURL,
+		sessionID:   ec.SessionID(),
+		apiVersion:  ec.APIVersion(),
+	}, nil
+}
+
+func (c *Client) MaxBatchSize() int {
+	return maxCollectionSize
+}
+
+// apiError is an error in the REST API's format, which is used both for
+// failed calls and for the records that failed within a call.
+type apiError struct {
+	StatusCode string   `json:"statusCode"`
+	ErrorCode  string   `json:"errorCode"`
+	Message    string   `json:"message"`
+	Fields     []string `json:"fields"`
+}
+
+func (e *apiError) code() string {
+	if e.StatusCode != "" {
+		return e.StatusCode
+	}
+	return e.ErrorCode
+}
+
+// toRecordError converts the errors for a record into a RecordError, so that
+// they can be grouped by status code and field.
+func toRecordError(errs []*apiError) *salesforce.RecordError {
+	converted := make([]*salesforce.RecordError, len(errs))
+	for i, e := range errs {
+		converted[i] = &salesforce.RecordError{StatusCode: e.code(), Message: e.Message, Fields: e.Fields}
+	}
+	result := converted[0]
+	result.More = converted[1:]
+	return result
+}
+
+// do issues a request against the REST API, marshalling in as the JSON body
+// if it's non-nil, and unmarshalling the JSON response into out.
+func (c *Client) do(method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("marshalling request to %s %s: %w", method, path, err)
+		}
+		body = bytes.NewReader(data)
+	}
+	url := fmt.Sprintf("%s/services/data/v%s%s", c.instanceURL, c.apiVersion, path)
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.sessionID)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("issuing %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	apilimits.CountCall("rest")
+	apilimits.ObserveHeader(resp.Header)
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response to %s %s: %w", method, path, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErrs := []*apiError{}
+		if err := json.Unmarshal(data, &apiErrs); err == nil && len(apiErrs) > 0 {
+			return fmt.Errorf("%s %s returned %d: %w", method, path, resp.StatusCode, toRecordError(apiErrs))
+		}
+		return fmt.Errorf("%s %s returned %d: %q", method, path, resp.StatusCode, string(data))
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("unmarshalling response to %s %s: %w", method, path, err)
+		}
+	}
+	return nil
+}
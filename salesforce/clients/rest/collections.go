@@ -0,0 +1,225 @@
+package restclient
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Silicon-Ally/etap2sf/salesforce"
+	client "github.com/Silicon-Ally/etap2sf/salesforce/clients/enterprise"
+	"github.com/Silicon-Ally/etap2sf/salesforce/generated/sfenterprise"
+)
+
+type collectionRequest struct {
+	AllOrNone bool             `json:"allOrNone"`
+	Records   []map[string]any `json:"records"`
+}
+
+// saveResult is the result for one record of a collection, in the same order
+// as the records in the request.
+type saveResult struct {
+	ID      string      `json:"id"`
+	Success bool        `json:"success"`
+	Errors  []*apiError `json:"errors"`
+}
+
+// UpsertBatch upserts the given records (all of the given type) on their
+// external ID, in a single sObject Collections call. Content document links
+// have no external ID, so they're created instead. The returned IDs and errors
+// line up with the records, and the overall error is only non-nil if the call
+// itself failed. As with upsertOne, existing records that can't be updated
+// because of the fields that are only set on creation are sent again, in a
+// second collection, without them. The context is only checked before the
+// first call is made.
+func (c *Client) UpsertBatch(ctx context.Context, sot salesforce.ObjectType, records []any) ([]string, []error, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, context.Cause(ctx)
+	}
+	ids, errs, err := c.upsertCollection(sot, records, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	retries := []any{}
+	// retried are the indexes of the records being sent again.
+	retried := []int{}
+	for i, err := range errs {
+		if re, ok := salesforce.AsRecordError(err); ok && re.StatusCode == "INVALID_FIELD_FOR_INSERT_UPDATE" {
+			retries = append(retries, records[i])
+			retried = append(retried, i)
+		}
+	}
+	if len(retries) == 0 {
+		return ids, errs, nil
+	}
+	retryIDs, retryErrs, err := c.upsertCollection(sot, retries, true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("retrying %d %s without their create-only fields: %w", len(retries), sot, err)
+	}
+	for j, i := range retried {
+		ids[i], errs[i] = retryIDs[j], retryErrs[j]
+	}
+	return ids, errs, nil
+}
+
+func (c *Client) upsertCollection(sot salesforce.ObjectType, records []any, withoutCreateOnlyFields bool) ([]string, []error, error) {
+	switch sot {
+	case salesforce.ObjectType_ContentVersion, salesforce.ObjectType_ContentNote:
+		return nil, nil, fmt.Errorf("%s can't be uploaded through sObject Collections - use the soap backend for them", sot)
+	}
+	if len(records) > maxCollectionSize {
+		return nil, nil, fmt.Errorf("can't upsert %d records in one collection, the most is %d", len(records), maxCollectionSize)
+	}
+	ots, err := sot.SalesforceName()
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting salesforce name: %w", err)
+	}
+	fieldKey := ""
+	if sot != salesforce.ObjectType_ContentDocumentLink {
+		if fieldKey, err = sot.SalesforceObjectExternalFieldKey(); err != nil {
+			return nil, nil, fmt.Errorf("getting salesforce object external field key: %w", err)
+		}
+	}
+
+	ids := make([]string, len(records))
+	errs := make([]error, len(records))
+	req := &collectionRequest{}
+	// sent are the indexes of the records in the request.
+	sent := []int{}
+	for i, record := range records {
+		fields, err := toFields(ots, fieldKey, record, withoutCreateOnlyFields)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		fields["attributes"] = map[string]string{"type": ots}
+		req.Records = append(req.Records, fields)
+		sent = append(sent, i)
+	}
+	if len(sent) == 0 {
+		return ids, errs, nil
+	}
+
+	method, path := "PATCH", fmt.Sprintf("/composite/sobjects/%s/%s", ots, fieldKey)
+	if fieldKey == "" {
+		method, path = "POST", "/composite/sobjects"
+	}
+	results := []*saveResult{}
+	if err := c.do(method, path, req, &results); err != nil {
+		return nil, nil, fmt.Errorf("upserting collection of %d %s: %w", len(sent), sot, err)
+	}
+	if len(results) != len(sent) {
+		return nil, nil, fmt.Errorf("expected %d results for collection of %s, got %d", len(sent), sot, len(results))
+	}
+	for j, i := range sent {
+		r := results[j]
+		switch {
+		case len(r.Errors) > 0:
+			errs[i] = toRecordError(r.Errors)
+		case !r.Success:
+			errs[i] = fmt.Errorf("failed to upsert %s, with no errors in the response", sot)
+		case r.ID == "":
+			errs[i] = fmt.Errorf("upserted %s but the response has no id", sot)
+		default:
+			ids[i] = r.ID
+		}
+	}
+	return ids, errs, nil
+}
+
+// toFields converts a record to the fields that are sent for it, checking that
+// it has an external key to be upserted on (if fieldKey is set).
+func toFields(ots, fieldKey string, record any, withoutCreateOnlyFields bool) (map[string]any, error) {
+	fields, err := client.StructToFieldsMap(record)
+	if err != nil {
+		return nil, fmt.Errorf("converting struct to map: %w", err)
+	}
+	if withoutCreateOnlyFields {
+		client.DeleteCreateOnlyFields(fields)
+	}
+	if fieldKey != "" {
+		key, ok := fields[fieldKey].(string)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("field key %s of %s is empty", fieldKey, ots)
+		}
+	}
+	return fields, nil
+}
+
+// upsertOne upserts a single record as a collection of one. As with the SOAP
+// client, an existing record that can't be updated because of the fields that
+// are only set on creation is retried without them.
+func (c *Client) upsertOne(sot salesforce.ObjectType, record any) (string, error) {
+	ids, errs, err := c.upsertCollection(sot, []any{record}, false)
+	if err != nil {
+		return "", err
+	}
+	if re, ok := salesforce.AsRecordError(errs[0]); ok && re.StatusCode == "INVALID_FIELD_FOR_INSERT_UPDATE" {
+		if ids, errs, err = c.upsertCollection(sot, []any{record}, true); err != nil {
+			return "", err
+		}
+	}
+	return ids[0], errs[0]
+}
+
+func (c *Client) UpsertCampaign(v *sfenterprise.Campaign) (string, error) {
+	return c.upsertOne(salesforce.ObjectType_Campaign, v)
+}
+
+func (c *Client) UpsertRelationship(v *sfenterprise.Npe4__Relationship__c) (string, error) {
+	return c.upsertOne(salesforce.ObjectType_Relationship, v)
+}
+
+func (c *Client) UpsertRecurringDonation(v *sfenterprise.Npe03__Recurring_Donation__c) (string, error) {
+	return c.upsertOne(salesforce.ObjectType_RecurringDonation, v)
+}
+
+func (c *Client) UpsertAffiliation(v *sfenterprise.Npe5__Affiliation__c) (string, error) {
+	return c.upsertOne(salesforce.ObjectType_Affiliation, v)
+}
+
+func (c *Client) UpsertPayment(v *sfenterprise.Npe01__OppPayment__c) (string, error) {
+	return c.upsertOne(salesforce.ObjectType_Payment, v)
+}
+
+func (c *Client) UpsertContact(v *sfenterprise.Contact) (string, error) {
+	return c.upsertOne(salesforce.ObjectType_Contact, v)
+}
+
+func (c *Client) UpsertAccount(v *sfenterprise.Account) (string, error) {
+	return c.upsertOne(salesforce.ObjectType_Account, v)
+}
+
+func (c *Client) UpsertGeneralAccountingUnit(v *sfenterprise.Npsp__General_Accounting_Unit__c) (string, error) {
+	return c.upsertOne(salesforce.ObjectType_GeneralAccountingUnit, v)
+}
+
+func (c *Client) UpsertGAUAllocation(v *sfenterprise.Npsp__Allocation__c) (string, error) {
+	return c.upsertOne(salesforce.ObjectType_GAUAllocation, v)
+}
+
+func (c *Client) UpsertTask(v *sfenterprise.Task) (string, error) {
+	return c.upsertOne(salesforce.ObjectType_Task, v)
+}
+
+func (c *Client) UpsertPartialSoftCredit(v *sfenterprise.Npsp__Partial_Soft_Credit__c) (string, error) {
+	return c.upsertOne(salesforce.ObjectType_PartialSoftCredit, v)
+}
+
+func (c *Client) UpsertAccountSoftCredit(v *sfenterprise.Npsp__Account_Soft_Credit__c) (string, error) {
+	return c.upsertOne(salesforce.ObjectType_AccountSoftCredit, v)
+}
+
+func (c *Client) UpsertOpportunity(v *sfenterprise.Opportunity) (string, error) {
+	return c.upsertOne(salesforce.ObjectType_Opportunity, v)
+}
+
+func (c *Client) UpsertAdditionalContext(v *sfenterprise.Etap_AdditionalContext__c) (string, error) {
+	return c.upsertOne(salesforce.ObjectType_AdditionalContext, v)
+}
+
+func (c *Client) UpsertContentDocumentLink(v *sfenterprise.ContentDocumentLink) (string, error) {
+	return c.upsertOne(salesforce.ObjectType_ContentDocumentLink, v)
+}
+
+func (c *Client) UpsertHousehold(v *sfenterprise.Npo02__Household__c) (string, error) {
+	return c.upsertOne(salesforce.ObjectType_Household, v)
+}
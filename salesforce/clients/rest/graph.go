@@ -0,0 +1,226 @@
+package restclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Silicon-Ally/etap2sf/salesforce"
+)
+
+// PlaceholderPrefix marks a lookup that holds the eTapestry ref of the record
+// that it refers to rather than its ID, as conv/conversion writes them.
+const PlaceholderPrefix = "NeedIdHaveRef:"
+
+const (
+	// The most graphs, and nodes across all of them, in one Composite Graph call.
+	maxGraphsPerRequest = 75
+	maxNodesPerRequest  = 500
+)
+
+// Node is a record in a graph.
+type Node struct {
+	ObjectType salesforce.ObjectType
+	Record     any
+}
+
+// Graph is a set of related records, like an opportunity with its payments and
+// allocations, that are saved together - if any of them fails, none of them
+// are saved. A lookup that holds a placeholder for the external key of an
+// earlier node in the same graph is pointed at that node, so children don't
+// need their parent's ID before they're sent.
+type Graph struct {
+	Nodes []*Node
+}
+
+// GraphResult is the result of a graph. The IDs and errors line up with its nodes.
+type GraphResult struct {
+	IDs    []string
+	Errors []error
+}
+
+type graphRequest struct {
+	Graphs []*graphRequestGraph `json:"graphs"`
+}
+
+type graphRequestGraph struct {
+	GraphID          string        `json:"graphId"`
+	CompositeRequest []*subrequest `json:"compositeRequest"`
+}
+
+type subrequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	ReferenceID string         `json:"referenceId"`
+	Body        map[string]any `json:"body"`
+}
+
+type graphResponse struct {
+	Graphs []struct {
+		GraphID       string `json:"graphId"`
+		IsSuccessful  bool   `json:"isSuccessful"`
+		GraphResponse struct {
+			CompositeResponse []struct {
+				Body           json.RawMessage `json:"body"`
+				HTTPStatusCode int             `json:"httpStatusCode"`
+				ReferenceID    string          `json:"referenceId"`
+			} `json:"compositeResponse"`
+		} `json:"graphResponse"`
+	} `json:"graphs"`
+}
+
+// MaxGraphsPerCall is the most graphs that are sent in one Composite Graph call.
+func (c *Client) MaxGraphsPerCall() int {
+	return maxGraphsPerRequest
+}
+
+// UpsertGraphs saves each graph in as few Composite Graph calls as the limits
+// allow. Records are upserted on their external ID, except for content
+// document links, which are created. The overall error is only non-nil if a
+// call itself failed. The context is checked before each call is made.
+func (c *Client) UpsertGraphs(ctx context.Context, graphs []*Graph) ([]*GraphResult, error) {
+	results := make([]*GraphResult, len(graphs))
+	for i, g := range graphs {
+		results[i] = &GraphResult{IDs: make([]string, len(g.Nodes)), Errors: make([]error, len(g.Nodes))}
+	}
+	req := &graphRequest{}
+	indexByID := map[string]int{}
+	nodes := 0
+	flush := func() error {
+		if len(req.Graphs) == 0 {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return context.Cause(ctx)
+		}
+		if err := c.sendGraphs(req, indexByID, results); err != nil {
+			return err
+		}
+		req, indexByID, nodes = &graphRequest{}, map[string]int{}, 0
+		return nil
+	}
+	for i, g := range graphs {
+		if len(g.Nodes) > maxNodesPerRequest {
+			failGraph(results[i], fmt.Errorf("graph has %d records, but the most is %d", len(g.Nodes), maxNodesPerRequest))
+			continue
+		}
+		rg, err := c.toGraphRequest(fmt.Sprintf("g%d", i), g)
+		if err != nil {
+			failGraph(results[i], err)
+			continue
+		}
+		if len(req.Graphs) == maxGraphsPerRequest || nodes+len(g.Nodes) > maxNodesPerRequest {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+		req.Graphs = append(req.Graphs, rg)
+		indexByID[rg.GraphID] = i
+		nodes += len(g.Nodes)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func failGraph(r *GraphResult, err error) {
+	for i := range r.Errors {
+		r.Errors[i] = err
+	}
+}
+
+// toGraphRequest converts the nodes of a graph into subrequests, pointing
+// placeholders for earlier nodes at their references.
+func (c *Client) toGraphRequest(graphID string, g *Graph) (*graphRequestGraph, error) {
+	rg := &graphRequestGraph{GraphID: graphID}
+	refByKey := map[string]string{}
+	for j, n := range g.Nodes {
+		ots, err := n.ObjectType.SalesforceName()
+		if err != nil {
+			return nil, fmt.Errorf("getting salesforce name: %w", err)
+		}
+		fieldKey := ""
+		if n.ObjectType != salesforce.ObjectType_ContentDocumentLink {
+			if fieldKey, err = n.ObjectType.SalesforceObjectExternalFieldKey(); err != nil {
+				return nil, fmt.Errorf("getting salesforce object external field key: %w", err)
+			}
+		}
+		fields, err := toFields(ots, fieldKey, n.Record, false)
+		if err != nil {
+			return nil, fmt.Errorf("record %d (%s): %w", j, n.ObjectType, err)
+		}
+		for k, v := range fields {
+			s, ok := v.(string)
+			if !ok || !strings.HasPrefix(s, PlaceholderPrefix) {
+				continue
+			}
+			if ref, ok := refByKey[strings.TrimPrefix(s, PlaceholderPrefix)]; ok {
+				fields[k] = "@{" + ref + ".id}"
+			}
+		}
+		sr := &subrequest{
+			Method:      "POST",
+			URL:         fmt.Sprintf("/services/data/v%s/sobjects/%s", c.apiVersion, ots),
+			ReferenceID: fmt.Sprintf("n%d", j),
+			Body:        fields,
+		}
+		if fieldKey != "" {
+			key := fields[fieldKey].(string)
+			refByKey[key] = sr.ReferenceID
+			// The external ID is in the URL of an upsert, and can't also be in its body.
+			delete(fields, fieldKey)
+			sr.Method = "PATCH"
+			sr.URL += "/" + fieldKey + "/" + url.PathEscape(key)
+		}
+		rg.CompositeRequest = append(rg.CompositeRequest, sr)
+	}
+	return rg, nil
+}
+
+func (c *Client) sendGraphs(req *graphRequest, indexByID map[string]int, results []*GraphResult) error {
+	resp := &graphResponse{}
+	if err := c.do("POST", "/composite/graph", req, resp); err != nil {
+		return fmt.Errorf("saving %d graphs: %w", len(req.Graphs), err)
+	}
+	for _, g := range resp.Graphs {
+		i, ok := indexByID[g.GraphID]
+		if !ok {
+			return fmt.Errorf("response has unknown graph %q", g.GraphID)
+		}
+		delete(indexByID, g.GraphID)
+		r := results[i]
+		for _, sr := range g.GraphResponse.CompositeResponse {
+			var j int
+			if _, err := fmt.Sscanf(sr.ReferenceID, "n%d", &j); err != nil || j < 0 || j >= len(r.IDs) {
+				return fmt.Errorf("response to graph %q has unknown reference %q", g.GraphID, sr.ReferenceID)
+			}
+			if sr.HTTPStatusCode >= 200 && sr.HTTPStatusCode < 300 {
+				body := &saveResult{}
+				if err := json.Unmarshal(sr.Body, body); err != nil || body.ID == "" {
+					r.Errors[j] = fmt.Errorf("saved %s but couldn't read its id from %q", sr.ReferenceID, string(sr.Body))
+					continue
+				}
+				r.IDs[j] = body.ID
+				continue
+			}
+			apiErrs := []*apiError{}
+			if err := json.Unmarshal(sr.Body, &apiErrs); err != nil || len(apiErrs) == 0 {
+				r.Errors[j] = fmt.Errorf("saving %s failed with status %d: %q", sr.ReferenceID, sr.HTTPStatusCode, string(sr.Body))
+				continue
+			}
+			r.Errors[j] = toRecordError(apiErrs)
+		}
+		for j := range r.IDs {
+			if r.IDs[j] == "" && r.Errors[j] == nil {
+				r.Errors[j] = fmt.Errorf("graph %q has no result for record %d", g.GraphID, j)
+			}
+		}
+	}
+	for id, i := range indexByID {
+		failGraph(results[i], fmt.Errorf("response has no result for graph %q", id))
+	}
+	return nil
+}
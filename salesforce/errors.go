@@ -0,0 +1,65 @@
+package salesforce
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// RecordError is an error that Salesforce returned for a single record, like
+// REQUIRED_FIELD_MISSING or INVALID_CROSS_REFERENCE_KEY.
+type RecordError struct {
+	StatusCode string
+	Message    string
+	// Fields are the fields that the error is about, if Salesforce said.
+	Fields []string
+	// More are any other errors that Salesforce returned for the same record.
+	More []*RecordError
+}
+
+func (e *RecordError) Error() string {
+	sb := strings.Builder{}
+	e.write(&sb)
+	for _, m := range e.More {
+		sb.WriteString("; ")
+		m.write(&sb)
+	}
+	return sb.String()
+}
+
+func (e *RecordError) write(sb *strings.Builder) {
+	fmt.Fprintf(sb, "%s: %s", e.StatusCode, e.Message)
+	if len(e.Fields) > 0 {
+		fmt.Fprintf(sb, " (fields: %s)", strings.Join(e.Fields, ", "))
+	}
+}
+
+// AsRecordError returns the RecordError that err wraps, if there is one.
+func AsRecordError(err error) (*RecordError, bool) {
+	var re *RecordError
+	if errors.As(err, &re) {
+		return re, true
+	}
+	return nil, false
+}
+
+// ParseBulkError parses the sf__Error column of a failed bulk API result, which
+// looks like "REQUIRED_FIELD_MISSING:Required fields are missing: [Name]:Name --".
+func ParseBulkError(s string) *RecordError {
+	code, rest, ok := strings.Cut(s, ":")
+	if !ok || code == "" || strings.ToUpper(code) != code || strings.Contains(code, " ") {
+		return &RecordError{StatusCode: "UNKNOWN", Message: s}
+	}
+	re := &RecordError{StatusCode: code, Message: strings.TrimSpace(rest)}
+	// The fields are after the last colon, terminated by "--".
+	if i := strings.LastIndex(rest, ":"); i >= 0 && strings.HasSuffix(strings.TrimSpace(rest), "--") {
+		fields := strings.TrimSuffix(strings.TrimSpace(rest[i+1:]), "--")
+		re.Message = strings.TrimSpace(rest[:i])
+		for _, f := range strings.Split(fields, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				re.Fields = append(re.Fields, f)
+			}
+		}
+	}
+	return re
+}
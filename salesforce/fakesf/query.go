@@ -0,0 +1,376 @@
+package fakesf
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// soqlQuery is the subset of SOQL that the fake understands:
+//
+//	SELECT a, b FROM Object [WHERE cond [AND cond]...] [LIMIT n]
+//
+// where a condition is `field = value`, `field != value` or `field IN
+// (value, ...)`, and values are quoted strings, NULL or bare literals.
+type soqlQuery struct {
+	fields []string
+	object string
+	conds  []*condition
+	limit  int
+}
+
+type condition struct {
+	field  string
+	op     string
+	values []*literal
+}
+
+type literal struct {
+	value string
+	null  bool
+}
+
+func (l *literal) matches(v string) bool {
+	if l.null {
+		return v == ""
+	}
+	return strings.EqualFold(l.value, v)
+}
+
+// tokenize splits a query into identifiers, punctuation and strings, which
+// start with a quote and are unescaped.
+func tokenize(q string) ([]string, error) {
+	tokens := []string{}
+	for i := 0; i < len(q); {
+		c := q[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'':
+			var sb strings.Builder
+			sb.WriteByte('\'')
+			i++
+			for ; i < len(q) && q[i] != '\''; i++ {
+				if q[i] == '\\' && i+1 < len(q) {
+					i++
+				}
+				sb.WriteByte(q[i])
+			}
+			if i == len(q) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			i++
+			tokens = append(tokens, sb.String())
+		case c == '(' || c == ')' || c == ',' || c == '=':
+			tokens = append(tokens, string(c))
+			i++
+		case strings.HasPrefix(q[i:], "!=") || strings.HasPrefix(q[i:], "<>"):
+			tokens = append(tokens, "!=")
+			i += 2
+		default:
+			j := i
+			for j < len(q) && strings.IndexByte(" \t\n\r'(),=!<>", q[j]) < 0 {
+				j++
+			}
+			if j == i {
+				return nil, fmt.Errorf("unexpected %q", string(c))
+			}
+			tokens = append(tokens, q[i:j])
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+func parseQuery(q string) (*soqlQuery, error) {
+	tokens, err := tokenize(q)
+	if err != nil {
+		return nil, err
+	}
+	pos := 0
+	next := func() string {
+		if pos >= len(tokens) {
+			return ""
+		}
+		pos++
+		return tokens[pos-1]
+	}
+	is := func(keyword string) bool {
+		return pos < len(tokens) && strings.EqualFold(tokens[pos], keyword)
+	}
+	expect := func(want string) error {
+		if got := next(); !strings.EqualFold(got, want) {
+			return fmt.Errorf("expected %s, got %q", want, got)
+		}
+		return nil
+	}
+
+	result := &soqlQuery{limit: -1}
+	if err := expect("SELECT"); err != nil {
+		return nil, err
+	}
+	for {
+		f := next()
+		if f == "" || f == "," || strings.HasPrefix(f, "'") {
+			return nil, fmt.Errorf("expected a field, got %q", f)
+		}
+		result.fields = append(result.fields, f)
+		if !is(",") {
+			break
+		}
+		next()
+	}
+	if err := expect("FROM"); err != nil {
+		return nil, err
+	}
+	if result.object = next(); result.object == "" {
+		return nil, fmt.Errorf("expected an object type")
+	}
+	if is("WHERE") {
+		next()
+		for {
+			c, err := parseCondition(next)
+			if err != nil {
+				return nil, err
+			}
+			result.conds = append(result.conds, c)
+			if !is("AND") {
+				break
+			}
+			next()
+		}
+	}
+	if is("LIMIT") {
+		next()
+		n, err := strconv.Atoi(next())
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid limit")
+		}
+		result.limit = n
+	}
+	if pos < len(tokens) {
+		return nil, fmt.Errorf("fakesf doesn't understand %q", strings.Join(tokens[pos:], " "))
+	}
+	return result, nil
+}
+
+func parseCondition(next func() string) (*condition, error) {
+	c := &condition{field: next()}
+	if c.field == "" {
+		return nil, fmt.Errorf("expected a condition")
+	}
+	switch op := next(); strings.ToUpper(op) {
+	case "=", "!=":
+		c.op = op
+		v, err := parseLiteral(next())
+		if err != nil {
+			return nil, err
+		}
+		c.values = []*literal{v}
+	case "IN":
+		c.op = "IN"
+		if next() != "(" {
+			return nil, fmt.Errorf("expected ( after IN")
+		}
+		for {
+			v, err := parseLiteral(next())
+			if err != nil {
+				return nil, err
+			}
+			c.values = append(c.values, v)
+			t := next()
+			if t == ")" {
+				break
+			}
+			if t != "," {
+				return nil, fmt.Errorf("expected , or ) in IN list, got %q", t)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("fakesf doesn't understand the operator %q", op)
+	}
+	return c, nil
+}
+
+func parseLiteral(t string) (*literal, error) {
+	switch {
+	case t == "" || t == "(" || t == ")" || t == ",":
+		return nil, fmt.Errorf("expected a value, got %q", t)
+	case strings.HasPrefix(t, "'"):
+		return &literal{value: t[1:]}, nil
+	case strings.EqualFold(t, "NULL"):
+		return &literal{null: true}, nil
+	default:
+		return &literal{value: t}, nil
+	}
+}
+
+func (r *record) value(field string) string {
+	if strings.EqualFold(field, "Id") {
+		return r.id
+	}
+	return r.values[strings.ToLower(field)]
+}
+
+func (c *condition) matches(r *record) bool {
+	v := c.value(r)
+	for _, l := range c.values {
+		if l.matches(v) {
+			return c.op != "!="
+		}
+	}
+	return c.op == "!="
+}
+
+// value is the record's value for the field, as compared. IDs can be given
+// in their 15 character form.
+func (c *condition) value(r *record) string {
+	v := r.value(c.field)
+	if strings.EqualFold(c.field, "Id") && len(c.values) > 0 && len(c.values[0].value) == 15 {
+		return v[:15]
+	}
+	return v
+}
+
+// queryRecord is a record in a query result, which is marshalled the way that
+// Salesforce sends them, with its fields in the order they were selected.
+type queryRecord struct {
+	typ    string
+	names  []string
+	values []*string
+}
+
+func (q *queryRecord) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xsi:type"}, Value: "sf:sObject"})
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := e.EncodeElement(q.typ, xml.StartElement{Name: xml.Name{Local: "type"}}); err != nil {
+		return err
+	}
+	for i, name := range q.names {
+		el := xml.StartElement{Name: xml.Name{Local: name}}
+		if q.values[i] == nil {
+			el.Attr = []xml.Attr{{Name: xml.Name{Local: "xsi:nil"}, Value: "true"}}
+			if err := e.EncodeElement("", el); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := e.EncodeElement(*q.values[i], el); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+type queryResult struct {
+	Done         bool           `xml:"done"`
+	QueryLocator string         `xml:"queryLocator,omitempty"`
+	Records      []*queryRecord `xml:"records"`
+	Size         int            `xml:"size"`
+}
+
+type queryResponse struct {
+	XMLName xml.Name
+	Result  *queryResult `xml:"result"`
+}
+
+// cursor is the rest of a query's records, for queryMore.
+type cursor struct {
+	objectType string
+	records    []*queryRecord
+	size       int
+}
+
+func (s *Server) query(op, q string) (any, error) {
+	parsed, err := parseQuery(q)
+	if err != nil {
+		return nil, faultf("MALFORMED_QUERY", "%v", err)
+	}
+	if err := s.callFault(op, []string{parsed.object}); err != nil {
+		return nil, err
+	}
+	o, ok := s.objects[strings.ToLower(parsed.object)]
+	if !ok {
+		// Object types that nothing has been sent for just have no records.
+		o = &object{name: parsed.object}
+	}
+	if o.declared() {
+		fields := append([]string{}, parsed.fields...)
+		for _, c := range parsed.conds {
+			fields = append(fields, c.field)
+		}
+		for _, f := range fields {
+			if _, ok := o.fields[strings.ToLower(f)]; !ok && !strings.EqualFold(f, "Id") {
+				return nil, faultf("INVALID_FIELD", "No such column '%s' on entity '%s'", f, o.name)
+			}
+		}
+	}
+
+	records := []*queryRecord{}
+	for _, r := range o.records {
+		if parsed.limit >= 0 && len(records) == parsed.limit {
+			break
+		}
+		matches := true
+		for _, c := range parsed.conds {
+			matches = matches && c.matches(r)
+		}
+		if !matches {
+			continue
+		}
+		qr := &queryRecord{typ: o.name}
+		for _, f := range parsed.fields {
+			k := strings.ToLower(f)
+			name := f
+			if n, ok := r.names[k]; ok {
+				name = n
+			} else if df, ok := o.fields[k]; ok {
+				name = df.Name
+			}
+			var v *string
+			if k == "id" {
+				name, v = "Id", &r.id
+			} else if value, ok := r.values[k]; ok {
+				v = &value
+			}
+			qr.names = append(qr.names, name)
+			qr.values = append(qr.values, v)
+		}
+		records = append(records, qr)
+	}
+	return s.page(op, &cursor{objectType: o.name, records: records, size: len(records)}), nil
+}
+
+func (s *Server) queryMore(locator string) (any, error) {
+	c, ok := s.cursors[locator]
+	if !ok {
+		return nil, faultf("INVALID_QUERY_LOCATOR", "invalid query locator")
+	}
+	delete(s.cursors, locator)
+	if err := s.callFault("queryMore", []string{c.objectType}); err != nil {
+		return nil, err
+	}
+	return s.page("queryMore", c), nil
+}
+
+// page returns the next batch of a query's records, keeping the rest for queryMore.
+func (s *Server) page(op string, c *cursor) *queryResponse {
+	batchSize := s.QueryBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultQueryBatchSize
+	}
+	result := &queryResult{Done: true, Records: c.records, Size: c.size}
+	if len(c.records) > batchSize {
+		s.lastID++
+		locator := fmt.Sprintf("01g%012dAAA-%d", s.lastID, batchSize)
+		s.cursors[locator] = &cursor{objectType: c.objectType, records: c.records[batchSize:], size: c.size}
+		result.Done, result.QueryLocator, result.Records = false, locator, c.records[:batchSize]
+	}
+	return &queryResponse{
+		XMLName: xml.Name{Space: "urn:partner.soap.sforce.com", Local: op + "Response"},
+		Result:  result,
+	}
+}
@@ -0,0 +1,432 @@
+package fakesf
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/tzmfreedom/go-soapforce"
+)
+
+type record struct {
+	id     string
+	object *object
+	// values are keyed by lowercased field name, and names has the names
+	// that the fields were first sent with.
+	values map[string]string
+	names  map[string]string
+}
+
+func (r *record) fields() map[string]string {
+	result := map[string]string{"Id": r.id}
+	for k, v := range r.values {
+		result[r.names[k]] = v
+	}
+	return result
+}
+
+// Insert adds a record without any of the checks, for seeding the server with
+// records that the migration expects to find (like record types and users),
+// or that it couldn't create itself (like duplicates). It returns the ID.
+func (s *Server) Insert(objectType string, fields map[string]string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.applyLocked(s.objectLocked(objectType), nil, fields, nil).id
+}
+
+// Records returns the fields of every record of the given type, including
+// their Id, in the order that they were created.
+func (s *Server) Records(objectType string) []map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.objects[strings.ToLower(objectType)]
+	if !ok {
+		return nil
+	}
+	result := []map[string]string{}
+	for _, r := range o.records {
+		result = append(result, r.fields())
+	}
+	return result
+}
+
+// Record returns the fields of the record with the given ID, if it exists.
+func (s *Server) Record(id string) (map[string]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.byID[id]
+	if !ok {
+		return nil, false
+	}
+	return r.fields(), true
+}
+
+func (s *Server) newIDLocked(o *object) string {
+	s.lastID++
+	return fmt.Sprintf("%s%012dAAA", o.prefix, s.lastID)
+}
+
+// applyLocked creates a record, or updates an existing one, without checking it.
+func (s *Server) applyLocked(o *object, existing *record, fields map[string]string, toNull []string) *record {
+	r := existing
+	if r == nil {
+		r = &record{id: s.newIDLocked(o), object: o, values: map[string]string{}, names: map[string]string{}}
+		o.records = append(o.records, r)
+		s.byID[r.id] = r
+	}
+	for name, v := range fields {
+		if strings.EqualFold(name, "Id") {
+			continue
+		}
+		k := strings.ToLower(name)
+		if f, ok := o.fields[k]; ok {
+			name = f.Name
+		}
+		if _, ok := r.names[k]; !ok {
+			r.names[k] = name
+		}
+		r.values[k] = v
+	}
+	for _, name := range toNull {
+		delete(r.values, strings.ToLower(name))
+	}
+	// Creating a content version creates the document that it's a version
+	// of, which is what content document links point at.
+	if existing == nil && strings.EqualFold(o.name, "ContentVersion") && r.values["contentdocumentid"] == "" {
+		doc := s.applyLocked(s.objectLocked("ContentDocument"), nil, map[string]string{"Title": r.values["title"]}, nil)
+		r.names["contentdocumentid"] = "ContentDocumentId"
+		r.values["contentdocumentid"] = doc.id
+	}
+	return r
+}
+
+func recordError(code, message string, fields ...string) *soapforce.Error {
+	sc := soapforce.StatusCode(code)
+	return &soapforce.Error{StatusCode: &sc, Message: message, Fields: fields}
+}
+
+func looksLikeID(v string) bool {
+	if len(v) != 15 && len(v) != 18 {
+		return false
+	}
+	for _, c := range v {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) lookupLocked(id string) (*record, bool) {
+	if len(id) == 15 {
+		id += "AAA"
+	}
+	r, ok := s.byID[id]
+	return r, ok
+}
+
+// checkLocked checks a record that's about to be created (if existing is nil)
+// or updated, returning the error that Salesforce would fail it with.
+func (s *Server) checkLocked(o *object, existing *record, fields map[string]string, toNull []string) *soapforce.Error {
+	lower := map[string]string{}
+	for name, v := range fields {
+		lower[strings.ToLower(name)] = v
+	}
+	if existing != nil {
+		for k := range lower {
+			if f, ok := o.fields[k]; ok && f.CreateOnly {
+				return recordError("INVALID_FIELD_FOR_INSERT_UPDATE", fmt.Sprintf("Unable to create/update fields: %s. Please check the security settings of this field and verify that it is read/write for your profile or permission set.", f.Name), f.Name)
+			}
+		}
+	}
+	nulled := map[string]bool{}
+	for _, name := range toNull {
+		nulled[strings.ToLower(name)] = true
+	}
+	missing := []string{}
+	for k, f := range o.fields {
+		if !f.Required {
+			continue
+		}
+		if nulled[k] || (existing == nil && lower[k] == "") {
+			missing = append(missing, f.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return recordError("REQUIRED_FIELD_MISSING", fmt.Sprintf("Required fields are missing: [%s]", strings.Join(missing, ", ")), missing...)
+	}
+	for k, v := range lower {
+		f, ok := o.fields[k]
+		if !ok || f.typ() != "reference" || v == "" {
+			continue
+		}
+		if !looksLikeID(v) {
+			return recordError("MALFORMED_ID", fmt.Sprintf("%s: id value of incorrect type: %s", f.Name, v), f.Name)
+		}
+		target, ok := s.lookupLocked(v)
+		if !ok {
+			return recordError("INVALID_CROSS_REFERENCE_KEY", fmt.Sprintf("invalid cross reference id: %s", v), f.Name)
+		}
+		if f.ReferenceTo != "" && !strings.EqualFold(target.object.name, f.ReferenceTo) {
+			return recordError("FIELD_INTEGRITY_EXCEPTION", fmt.Sprintf("%s: id value of incorrect type: %s", f.Name, v), f.Name)
+		}
+	}
+	for k := range o.unique {
+		v := lower[k]
+		if v == "" {
+			continue
+		}
+		for _, other := range o.findLocked(k, v) {
+			if other != existing {
+				name := k
+				if n, ok := other.names[k]; ok {
+					name = n
+				}
+				return recordError("DUPLICATE_VALUE", fmt.Sprintf("duplicate value found: %s duplicates value on record with id: %s", name, other.id), name)
+			}
+		}
+	}
+	return nil
+}
+
+// findLocked returns the records whose value for the given (lowercased)
+// field matches, ignoring case, as external IDs do.
+func (o *object) findLocked(k, v string) []*record {
+	result := []*record{}
+	for _, r := range o.records {
+		if strings.EqualFold(r.values[k], v) {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// toFields converts the fields of a record in a request, which are all
+// strings unless they're nested records.
+func toFields(sobj *soapforce.SObject) (map[string]string, error) {
+	result := map[string]string{}
+	for k, v := range sobj.Fields {
+		s, ok := v.(string)
+		if !ok {
+			return nil, faultf("INVALID_FIELD", "fakesf doesn't support the %T in field %s of %s", v, k, sobj.Type)
+		}
+		result[k] = s
+	}
+	return result, nil
+}
+
+// callFault checks the injections that fail a whole call, for each of the
+// object types in it.
+func (s *Server) callFault(op string, types []string) error {
+	if len(types) == 0 {
+		types = []string{""}
+	}
+	for _, t := range types {
+		if in := s.injected(op, t, nil, true); in != nil {
+			return &fault{code: in.StatusCode, message: in.Message}
+		}
+	}
+	return nil
+}
+
+func (s *Server) recordFault(op, objectType string, fields map[string]string) *soapforce.Error {
+	if in := s.injected(op, objectType, fields, false); in != nil {
+		return recordError(in.StatusCode, in.Message, in.Fields...)
+	}
+	return nil
+}
+
+// prepare converts the records of a create or upsert call, checking that
+// their fields exist.
+func (s *Server) prepare(op string, sobjs []*soapforce.SObject) ([]*object, []map[string]string, error) {
+	types := []string{}
+	objects := make([]*object, len(sobjs))
+	fields := make([]map[string]string, len(sobjs))
+	for i, sobj := range sobjs {
+		if sobj.Type == "" {
+			return nil, nil, faultf("INVALID_TYPE", "Must send a concrete entity type.")
+		}
+		types = append(types, sobj.Type)
+		f, err := toFields(sobj)
+		if err != nil {
+			return nil, nil, err
+		}
+		objects[i], fields[i] = s.objectLocked(sobj.Type), f
+		if err := checkFields(objects[i], f, sobj.FieldsToNull); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := s.callFault(op, types); err != nil {
+		return nil, nil, err
+	}
+	return objects, fields, nil
+}
+
+type upsertResponse struct {
+	XMLName xml.Name                  `xml:"urn:partner.soap.sforce.com upsertResponse"`
+	Result  []*soapforce.UpsertResult `xml:"result"`
+}
+
+func (s *Server) upsert(req *soapforce.Upsert) (any, error) {
+	objects, fields, err := s.prepare("upsert", req.SObjects)
+	if err != nil {
+		return nil, err
+	}
+	key := strings.ToLower(req.ExternalIDFieldName)
+	if key == "" {
+		return nil, faultf("MISSING_ARGUMENT", "externalIDFieldName not specified")
+	}
+	byID := key == "id"
+	for _, o := range objects {
+		if byID {
+			continue
+		}
+		if o.declared() {
+			if f, ok := o.fields[key]; !ok || !f.ExternalID {
+				return nil, faultf("INVALID_FIELD", "Field name provided, %s is not an External ID or indexed field for %s", req.ExternalIDFieldName, o.name)
+			}
+		}
+		o.unique[key] = true
+	}
+	// Records with the same key in the same call all fail.
+	counts := map[string]int{}
+	for i, f := range fields {
+		counts[objects[i].name+"\x00"+strings.ToLower(lowered(f)[key])]++
+	}
+
+	resp := &upsertResponse{}
+	for i, sobj := range req.SObjects {
+		o, f := objects[i], fields[i]
+		result := &soapforce.UpsertResult{}
+		resp.Result = append(resp.Result, result)
+		if e := s.recordFault("upsert", o.name, f); e != nil {
+			result.Errors = []*soapforce.Error{e}
+			continue
+		}
+		v := lowered(f)[key]
+		if v == "" {
+			result.Errors = []*soapforce.Error{recordError("MISSING_ARGUMENT", req.ExternalIDFieldName+" not specified", req.ExternalIDFieldName)}
+			continue
+		}
+		if counts[o.name+"\x00"+strings.ToLower(v)] > 1 {
+			result.Errors = []*soapforce.Error{recordError("DUPLICATE_EXTERNAL_ID", "Duplicate external id specified: "+v, req.ExternalIDFieldName)}
+			continue
+		}
+		var matches []*record
+		if byID {
+			if r, ok := s.lookupLocked(v); ok && r.object == o {
+				matches = []*record{r}
+			} else {
+				result.Errors = []*soapforce.Error{recordError("INVALID_CROSS_REFERENCE_KEY", "invalid cross reference id: "+v, "Id")}
+				continue
+			}
+		} else {
+			matches = o.findLocked(key, v)
+		}
+		if len(matches) > 1 {
+			ids := []string{}
+			for _, m := range matches {
+				ids = append(ids, m.id)
+			}
+			result.Errors = []*soapforce.Error{recordError("DUPLICATE_EXTERNAL_ID", fmt.Sprintf("%s: more than one record found for external id field: [%s]", req.ExternalIDFieldName, strings.Join(ids, ", ")), req.ExternalIDFieldName)}
+			continue
+		}
+		var existing *record
+		if len(matches) == 1 {
+			existing = matches[0]
+		}
+		if e := s.checkLocked(o, existing, f, sobj.FieldsToNull); e != nil {
+			result.Errors = []*soapforce.Error{e}
+			continue
+		}
+		r := s.applyLocked(o, existing, f, sobj.FieldsToNull)
+		result.Id, result.Success, result.Created = r.id, true, existing == nil
+	}
+	return resp, nil
+}
+
+func lowered(fields map[string]string) map[string]string {
+	result := map[string]string{}
+	for k, v := range fields {
+		result[strings.ToLower(k)] = v
+	}
+	return result
+}
+
+type createResponse struct {
+	XMLName xml.Name                `xml:"urn:partner.soap.sforce.com createResponse"`
+	Result  []*soapforce.SaveResult `xml:"result"`
+}
+
+func (s *Server) create(req *soapforce.Create) (any, error) {
+	objects, fields, err := s.prepare("create", req.SObjects)
+	if err != nil {
+		return nil, err
+	}
+	resp := &createResponse{}
+	for i, sobj := range req.SObjects {
+		o, f := objects[i], fields[i]
+		result := &soapforce.SaveResult{}
+		resp.Result = append(resp.Result, result)
+		if e := s.recordFault("create", o.name, f); e != nil {
+			result.Errors = []*soapforce.Error{e}
+			continue
+		}
+		if e := s.checkLocked(o, nil, f, sobj.FieldsToNull); e != nil {
+			result.Errors = []*soapforce.Error{e}
+			continue
+		}
+		result.Id, result.Success = s.applyLocked(o, nil, f, nil).id, true
+	}
+	return resp, nil
+}
+
+type deleteResponse struct {
+	XMLName xml.Name                  `xml:"urn:partner.soap.sforce.com deleteResponse"`
+	Result  []*soapforce.DeleteResult `xml:"result"`
+}
+
+func (s *Server) delete(req *soapforce.Delete) (any, error) {
+	types := []string{}
+	for _, id := range req.Ids {
+		if r, ok := s.lookupLocked(id); ok {
+			types = append(types, r.object.name)
+		}
+	}
+	if err := s.callFault("delete", types); err != nil {
+		return nil, err
+	}
+	resp := &deleteResponse{}
+	for _, id := range req.Ids {
+		result := &soapforce.DeleteResult{Id: id}
+		resp.Result = append(resp.Result, result)
+		r, ok := s.lookupLocked(id)
+		switch {
+		case ok:
+		case s.deleted[id]:
+			result.Errors = []*soapforce.Error{recordError("ENTITY_IS_DELETED", "entity is deleted")}
+			continue
+		case !looksLikeID(id):
+			result.Errors = []*soapforce.Error{recordError("MALFORMED_ID", "malformed id "+id)}
+			continue
+		default:
+			result.Errors = []*soapforce.Error{recordError("INVALID_CROSS_REFERENCE_KEY", "invalid cross reference id")}
+			continue
+		}
+		if e := s.recordFault("delete", r.object.name, r.fields()); e != nil {
+			result.Errors = []*soapforce.Error{e}
+			continue
+		}
+		o := r.object
+		for i, other := range o.records {
+			if other == r {
+				o.records = append(o.records[:i], o.records[i+1:]...)
+				break
+			}
+		}
+		delete(s.byID, r.id)
+		s.deleted[r.id] = true
+		result.Id, result.Success = r.id, true
+	}
+	return resp, nil
+}
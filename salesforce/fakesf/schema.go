@@ -0,0 +1,204 @@
+package fakesf
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tzmfreedom/go-soapforce"
+)
+
+// Object declares an object type, so that the records sent for it are checked
+// the way that Salesforce would check them. Records of object types that
+// aren't declared are taken as they come, except that the fields they're
+// upserted on must be unique.
+type Object struct {
+	Name   string
+	Fields []*Field
+}
+
+type Field struct {
+	Name string
+	// Type is the describe type, like "string", "date" or "reference". It
+	// defaults to "reference" if ReferenceTo is set, and "string" otherwise.
+	Type string
+	// ExternalID fields can be upserted on, and must be unique.
+	ExternalID bool
+	Unique     bool
+	Required   bool
+	// CreateOnly fields can be set when a record is created, but not when
+	// it's updated, like the lookups of a relationship.
+	CreateOnly bool
+	// ReferenceTo is the object type that a lookup points at.
+	ReferenceTo string
+}
+
+func (f *Field) typ() string {
+	switch {
+	case f.Type != "":
+		return f.Type
+	case f.ReferenceTo != "":
+		return "reference"
+	default:
+		return "string"
+	}
+}
+
+// keyPrefixes are the first three characters of the IDs of standard objects.
+// Other objects get prefixes like those of custom objects.
+var keyPrefixes = map[string]string{
+	"account":             "001",
+	"contact":             "003",
+	"opportunity":         "006",
+	"campaign":            "701",
+	"task":                "00T",
+	"user":                "005",
+	"recordtype":          "012",
+	"contentversion":      "068",
+	"contentdocument":     "069",
+	"contentnote":         "069",
+	"contentdocumentlink": "06A",
+}
+
+type object struct {
+	name   string
+	prefix string
+	// fields are the declared fields, keyed by lowercased name. It's nil if
+	// the object wasn't declared.
+	fields map[string]*Field
+	// unique are the lowercased names of the fields whose values must be unique.
+	unique  map[string]bool
+	records []*record
+}
+
+func (o *object) declared() bool {
+	return o.fields != nil
+}
+
+// Define declares an object type. Records that already exist aren't checked.
+func (s *Server) Define(def *Object) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.objectLocked(def.Name)
+	o.name = def.Name
+	o.fields = map[string]*Field{}
+	for _, f := range def.Fields {
+		o.fields[strings.ToLower(f.Name)] = f
+		if f.ExternalID || f.Unique {
+			o.unique[strings.ToLower(f.Name)] = true
+		}
+	}
+}
+
+// objectLocked returns the object type with the given name, which is case
+// insensitive, creating it if it doesn't exist yet.
+func (s *Server) objectLocked(name string) *object {
+	key := strings.ToLower(name)
+	if o, ok := s.objects[key]; ok {
+		return o
+	}
+	prefix, ok := keyPrefixes[key]
+	if !ok {
+		custom := 0
+		for k := range s.objects {
+			if _, ok := keyPrefixes[k]; !ok {
+				custom++
+			}
+		}
+		prefix = fmt.Sprintf("a%02d", custom)
+	}
+	o := &object{name: name, prefix: prefix, unique: map[string]bool{}}
+	s.objects[key] = o
+	return o
+}
+
+// checkFields returns a fault if a record of a declared object type has
+// fields that the type doesn't, as Salesforce does for the whole call.
+func checkFields(o *object, fields map[string]string, toNull []string) error {
+	if !o.declared() {
+		return nil
+	}
+	names := append([]string{}, toNull...)
+	for name := range fields {
+		names = append(names, name)
+	}
+	for _, name := range names {
+		if _, ok := o.fields[strings.ToLower(name)]; !ok && !strings.EqualFold(name, "Id") {
+			return faultf("INVALID_FIELD", "No such column '%s' on entity '%s'", name, o.name)
+		}
+	}
+	return nil
+}
+
+type describeResponse struct {
+	XMLName xml.Name                         `xml:"urn:partner.soap.sforce.com describeSObjectResponse"`
+	Result  *soapforce.DescribeSObjectResult `xml:"result"`
+}
+
+// describe describes the declared fields of an object type, or the fields
+// that its records have been sent with if it wasn't declared.
+func (s *Server) describe(name string) (any, error) {
+	if in := s.injected("describeSObject", name, nil, true); in != nil {
+		return nil, &fault{code: in.StatusCode, message: in.Message}
+	}
+	o, ok := s.objects[strings.ToLower(name)]
+	if !ok {
+		return nil, faultf("INVALID_TYPE", "sObject type '%s' is not supported.", name)
+	}
+	fields := []*Field{}
+	if o.declared() {
+		for _, f := range o.fields {
+			fields = append(fields, f)
+		}
+	} else {
+		seen := map[string]bool{}
+		for _, r := range o.records {
+			for k, name := range r.names {
+				if !seen[k] {
+					seen[k] = true
+					fields = append(fields, &Field{Name: name, ExternalID: o.unique[k]})
+				}
+			}
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+
+	idType, idSoapType := soapforce.FieldType("id"), soapforce.SoapType("tns:ID")
+	result := &soapforce.DescribeSObjectResult{
+		Name:       o.name,
+		Label:      o.name,
+		KeyPrefix:  o.prefix,
+		Custom:     strings.HasSuffix(o.name, "__c"),
+		Createable: true,
+		Updateable: true,
+		Deletable:  true,
+		Queryable:  true,
+		Fields:     []*soapforce.Field{{Name: "Id", Label: "Record ID", Type_: &idType, SoapType: &idSoapType, IdLookup: true}},
+	}
+	for _, f := range fields {
+		typ, soapType := soapforce.FieldType(f.typ()), soapforce.SoapType("xsd:string")
+		if f.typ() == "reference" {
+			soapType = "tns:ID"
+		}
+		df := &soapforce.Field{
+			Name:       f.Name,
+			Label:      f.Name,
+			Type_:      &typ,
+			SoapType:   &soapType,
+			Custom:     strings.HasSuffix(f.Name, "__c"),
+			ExternalId: f.ExternalID,
+			Unique:     f.Unique,
+			IdLookup:   f.ExternalID,
+			Nillable:   !f.Required,
+			Createable: true,
+			Updateable: !f.CreateOnly,
+			Filterable: true,
+		}
+		if f.ReferenceTo != "" {
+			df.ReferenceTo = []string{f.ReferenceTo}
+		}
+		result.Fields = append(result.Fields, df)
+	}
+	return &describeResponse{Result: result}, nil
+}
@@ -0,0 +1,408 @@
+// Package fakesf is an in-memory stand-in for Salesforce, for running the real
+// clients end to end without an org. It speaks enough of the SOAP partner API
+// for genericclient.New and the enterprise client: login, upsert, create,
+// delete, query, queryAll, queryMore and describeSObject. Each call counts
+// against the org's daily API allowance, which is reported on the responses.
+//
+// Records are kept in memory. Fields that are upserted on, or are declared as
+// external IDs, must be unique, and objects that are declared with Define
+// also check for unknown fields, required fields, lookups to records that
+// don't exist and fields that can only be set on creation, with the same
+// status codes as Salesforce. Inject makes calls or records fail on purpose.
+//
+// The metadata API, and the REST APIs that the bulk and rest clients use,
+// aren't implemented.
+package fakesf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/tzmfreedom/go-soapforce"
+)
+
+const (
+	defaultQueryBatchSize = 2000
+	defaultAPILimit       = 15000
+	orgID                 = "00D000000000001AAA"
+	userID                = "005000000000001AAA"
+)
+
+type Server struct {
+	// QueryBatchSize is how many records a query returns at once, before the
+	// rest need queryMore. Lower it to test paging.
+	QueryBatchSize int
+	// APILimit is the org's daily allowance of API requests. Every call but
+	// login counts against it, and the usage is reported in the
+	// LimitInfoHeader of each response, as Salesforce does.
+	APILimit int
+
+	srv       *httptest.Server
+	creds     *ConnConfig
+	sessionID string
+
+	mu         sync.Mutex
+	objects    map[string]*object
+	byID       map[string]*record
+	deleted    map[string]bool
+	lastID     int
+	cursors    map[string]*cursor
+	injections []*Injection
+	calls      map[string]int
+	apiUsed    int
+}
+
+// ConnConfig is a connection config for the clients, with the credentials
+// that the server accepts.
+type ConnConfig struct {
+	Username      string
+	Password      string
+	SecurityToken string
+	LoginURL      string
+}
+
+func (c *ConnConfig) GetUsername() string      { return c.Username }
+func (c *ConnConfig) GetPassword() string      { return c.Password }
+func (c *ConnConfig) GetSecurityToken() string { return c.SecurityToken }
+func (c *ConnConfig) GetLoginURL() string      { return c.LoginURL }
+
+// New starts a server, which should be closed when it's no longer needed. The
+// clients skip TLS verification, so its self-signed certificate is fine.
+func New() *Server {
+	s := &Server{
+		QueryBatchSize: defaultQueryBatchSize,
+		APILimit:       defaultAPILimit,
+		sessionID:      "00D000000000001!fakesession",
+		objects:        map[string]*object{},
+		byID:           map[string]*record{},
+		deleted:        map[string]bool{},
+		cursors:        map[string]*cursor{},
+		calls:          map[string]int{},
+	}
+	s.srv = httptest.NewTLSServer(http.HandlerFunc(s.serveHTTP))
+	s.creds = &ConnConfig{
+		Username:      "migration@example.org",
+		Password:      "password",
+		SecurityToken: "token",
+		LoginURL:      strings.TrimPrefix(s.srv.URL, "https://"),
+	}
+	return s
+}
+
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// URL is the base URL of the server, like https://127.0.0.1:1234.
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// ConnConfig returns credentials that log into the server.
+func (s *Server) ConnConfig() *ConnConfig {
+	c := *s.creds
+	return &c
+}
+
+// Calls is how many times the given operation (like "upsert") was called,
+// whether or not it succeeded.
+func (s *Server) Calls(operation string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[operation]
+}
+
+// SetAPIUsage sets how many API requests the org has made today, i.e. to test
+// what happens near the limit.
+func (s *Server) SetAPIUsage(used int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apiUsed = used
+}
+
+// Injection makes the server fail calls, or records within them, on purpose.
+type Injection struct {
+	// Operation limits it to one operation, like "upsert", "create" or "login".
+	Operation string
+	// ObjectType limits it to calls with, or records of, one object type.
+	ObjectType string
+	// Match limits it to the records for which it returns true. The fields
+	// are the ones sent for the record. It's ignored for faults.
+	Match func(fields map[string]string) bool
+	// Fault fails the whole call with a SOAP fault, rather than the records.
+	Fault bool
+	// StatusCode is what the call or records fail with, like UNABLE_TO_LOCK_ROW.
+	StatusCode string
+	Message    string
+	Fields     []string
+	// Times is how many calls or records it fails before it stops. Zero
+	// means that it never stops.
+	Times int
+
+	used int
+}
+
+// Inject adds an injection. They're checked in the order that they were added.
+func (s *Server) Inject(in *Injection) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.injections = append(s.injections, in)
+}
+
+func (s *Server) ClearInjections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.injections = nil
+}
+
+// injected finds the injection, if any, for a call or a record within it.
+func (s *Server) injected(op, objectType string, fields map[string]string, fault bool) *Injection {
+	for _, in := range s.injections {
+		if in.Fault != fault || (in.Times > 0 && in.used >= in.Times) {
+			continue
+		}
+		if in.Operation != "" && in.Operation != op {
+			continue
+		}
+		if in.ObjectType != "" && !strings.EqualFold(in.ObjectType, objectType) {
+			continue
+		}
+		if !fault && in.Match != nil && !in.Match(fields) {
+			continue
+		}
+		in.used++
+		return in
+	}
+	return nil
+}
+
+// fault is returned for a call that fails as a whole.
+type fault struct {
+	code    string
+	message string
+}
+
+func (f *fault) Error() string {
+	return f.code + ": " + f.message
+}
+
+func faultf(code, format string, args ...any) *fault {
+	return &fault{code: code, message: fmt.Sprintf(format, args...)}
+}
+
+type requestEnvelope struct {
+	Header struct {
+		SessionHeader struct {
+			SessionID string `xml:"sessionId"`
+		} `xml:"SessionHeader"`
+	} `xml:"Header"`
+	Body struct {
+		Inner []byte `xml:",innerxml"`
+	} `xml:"Body"`
+}
+
+type responseEnvelope struct {
+	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
+	XSI     string   `xml:"xmlns:xsi,attr"`
+	Header  *struct {
+		LimitInfoHeader *limitInfoHeader
+	} `xml:"http://schemas.xmlsoap.org/soap/envelope/ Header,omitempty"`
+	Body struct {
+		Content any
+	} `xml:"http://schemas.xmlsoap.org/soap/envelope/ Body"`
+}
+
+type limitInfoHeader struct {
+	XMLName   xml.Name `xml:"urn:partner.soap.sforce.com LimitInfoHeader"`
+	LimitInfo struct {
+		Current int    `xml:"current"`
+		Limit   int    `xml:"limit"`
+		Type    string `xml:"type"`
+	} `xml:"limitInfo"`
+}
+
+type soapFault struct {
+	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Fault"`
+	Code    string   `xml:"faultcode"`
+	String  string   `xml:"faultstring"`
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "fakesf only takes POSTs", http.StatusMethodNotAllowed)
+		return
+	}
+	var version string
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/services/Soap/u/"):
+		version = strings.Split(strings.TrimPrefix(r.URL.Path, "/services/Soap/u/"), "/")[0]
+	case strings.HasPrefix(r.URL.Path, "/services/Soap/m/"):
+		writeResponse(w, nil, nil, faultf("UNSUPPORTED_API", "fakesf doesn't implement the metadata api"))
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, "reading gzipped request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gr.Close()
+		body = gr
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, "reading request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	env := &requestEnvelope{}
+	if err := xml.Unmarshal(data, env); err != nil {
+		writeResponse(w, nil, nil, faultf("INVALID_XML", "unmarshalling envelope: %v", err))
+		return
+	}
+	op, err := operation(env.Body.Inner)
+	if err != nil {
+		writeResponse(w, nil, nil, faultf("INVALID_XML", "%v", err))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[op]++
+	if op != "login" && env.Header.SessionHeader.SessionID != s.sessionID {
+		writeResponse(w, nil, nil, faultf("INVALID_SESSION_ID", "Invalid Session ID found in SessionHeader: Illegal Session"))
+		return
+	}
+	var limits *limitInfoHeader
+	if op != "login" {
+		s.apiUsed++
+		limits = &limitInfoHeader{}
+		limits.LimitInfo.Current, limits.LimitInfo.Limit, limits.LimitInfo.Type = s.apiUsed, s.APILimit, "API REQUESTS"
+	}
+	resp, err := s.handle(op, version, env.Body.Inner)
+	writeResponse(w, limits, resp, err)
+}
+
+// operation is the name of the element in the body of a request.
+func operation(inner []byte) (string, error) {
+	d := xml.NewDecoder(bytes.NewReader(inner))
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return "", fmt.Errorf("no operation in request body: %w", err)
+		}
+		if se, ok := token.(xml.StartElement); ok {
+			return se.Name.Local, nil
+		}
+	}
+}
+
+func (s *Server) handle(op, version string, inner []byte) (any, error) {
+	switch op {
+	case "login":
+		req := &soapforce.Login{}
+		if err := xml.Unmarshal(inner, req); err != nil {
+			return nil, faultf("INVALID_XML", "unmarshalling login: %v", err)
+		}
+		return s.login(req, version)
+	case "upsert":
+		req := &soapforce.Upsert{}
+		if err := xml.Unmarshal(inner, req); err != nil {
+			return nil, faultf("INVALID_XML", "unmarshalling upsert: %v", err)
+		}
+		return s.upsert(req)
+	case "create":
+		req := &soapforce.Create{}
+		if err := xml.Unmarshal(inner, req); err != nil {
+			return nil, faultf("INVALID_XML", "unmarshalling create: %v", err)
+		}
+		return s.create(req)
+	case "delete":
+		req := &soapforce.Delete{}
+		if err := xml.Unmarshal(inner, req); err != nil {
+			return nil, faultf("INVALID_XML", "unmarshalling delete: %v", err)
+		}
+		return s.delete(req)
+	case "query", "queryAll":
+		// query and queryAll only differ in their element names.
+		req := &struct {
+			QueryString string `xml:"queryString"`
+		}{}
+		if err := xml.Unmarshal(inner, req); err != nil {
+			return nil, faultf("INVALID_XML", "unmarshalling %s: %v", op, err)
+		}
+		return s.query(op, req.QueryString)
+	case "queryMore":
+		req := &soapforce.QueryMore{}
+		if err := xml.Unmarshal(inner, req); err != nil {
+			return nil, faultf("INVALID_XML", "unmarshalling queryMore: %v", err)
+		}
+		return s.queryMore(req.QueryLocator)
+	case "describeSObject":
+		req := &soapforce.DescribeSObject{}
+		if err := xml.Unmarshal(inner, req); err != nil {
+			return nil, faultf("INVALID_XML", "unmarshalling describeSObject: %v", err)
+		}
+		return s.describe(req.SObjectType)
+	default:
+		return nil, faultf("INVALID_OPERATION", "fakesf doesn't implement %q", op)
+	}
+}
+
+func writeResponse(w http.ResponseWriter, limits *limitInfoHeader, resp any, err error) {
+	env := &responseEnvelope{XSI: "http://www.w3.org/2001/XMLSchema-instance"}
+	if limits != nil {
+		env.Header = &struct{ LimitInfoHeader *limitInfoHeader }{limits}
+	}
+	status := http.StatusOK
+	if err != nil {
+		f, ok := err.(*fault)
+		if !ok {
+			f = faultf("UNKNOWN_EXCEPTION", "%v", err)
+		}
+		env.Body.Content = &soapFault{Code: "sf:" + f.code, String: f.Error()}
+		status = http.StatusInternalServerError
+	} else {
+		env.Body.Content = resp
+	}
+	data, err := xml.Marshal(env)
+	if err != nil {
+		http.Error(w, "marshalling response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+}
+
+type loginResponse struct {
+	XMLName xml.Name               `xml:"urn:partner.soap.sforce.com loginResponse"`
+	Result  *soapforce.LoginResult `xml:"result"`
+}
+
+func (s *Server) login(req *soapforce.Login, version string) (any, error) {
+	if in := s.injected("login", "", nil, true); in != nil {
+		return nil, &fault{code: in.StatusCode, message: in.Message}
+	}
+	if req.Username != s.creds.Username || req.Password != s.creds.Password+s.creds.SecurityToken {
+		return nil, faultf("INVALID_LOGIN", "Invalid username, password, security token; or user locked out.")
+	}
+	return &loginResponse{Result: &soapforce.LoginResult{
+		MetadataServerUrl: fmt.Sprintf("%s/services/Soap/m/%s/%s", s.srv.URL, version, orgID),
+		ServerUrl:         fmt.Sprintf("%s/services/Soap/u/%s/%s", s.srv.URL, version, orgID),
+		SessionId:         s.sessionID,
+		UserId:            userID,
+		Sandbox:           true,
+	}}, nil
+}
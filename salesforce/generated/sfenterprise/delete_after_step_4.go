@@ -1,83 +1,92 @@
 package sfenterprise
 
 // This file is a placeholder to allow for the packages to build.
-// It should be deleted as soon as step_04 is completed.
+// It should be deleted as soon as step_04 is completed. The fields have the
+// tags that the generated ones do, so the clients send the same field names.
 
 type ID string
 
 type Account struct {
-	Etap_MultiObject_EtapRef__c *string
+	Etap_MultiObject_EtapRef__c *string `xml:"etap_MultiObject_EtapRef__c,omitempty" json:"etap_MultiObject_EtapRef__c,omitempty"`
 }
 
 type Npsp__Account_Soft_Credit__c struct {
-	Etap_SoftCredit_Ref__c *string
+	Etap_SoftCredit_Ref__c *string `xml:"etap_SoftCredit_Ref__c,omitempty" json:"etap_SoftCredit_Ref__c,omitempty"`
 }
 
 type Etap_AdditionalContext__c struct {
-	Name *string
+	Name *string `xml:"Name,omitempty" json:"Name,omitempty"`
 }
 
 type Npe5__Affiliation__c struct {
-	Etap_Relationship_Ref__c *string
+	Etap_Relationship_Ref__c *string `xml:"etap_Relationship_Ref__c,omitempty" json:"etap_Relationship_Ref__c,omitempty"`
 }
 
 type Campaign struct {
-	Etap_MultiObject_EtapRef__c *string
+	Etap_MultiObject_EtapRef__c *string `xml:"etap_MultiObject_EtapRef__c,omitempty" json:"etap_MultiObject_EtapRef__c,omitempty"`
 }
 
 type Contact struct {
-	Etap_Account_Ref__c *string
+	Etap_Account_Ref__c *string `xml:"etap_Account_Ref__c,omitempty" json:"etap_Account_Ref__c,omitempty"`
 }
 
 type ContentDocumentLink struct {
-	ContentDocumentId *ID
-	LinkedEntityId    *ID
+	ContentDocumentId *ID `xml:"ContentDocumentId,omitempty" json:"ContentDocumentId,omitempty"`
+	LinkedEntityId    *ID `xml:"LinkedEntityId,omitempty" json:"LinkedEntityId,omitempty"`
 }
 
 type ContentVersion struct {
-	Etap_MultiObject_EtapRef__c *string
+	Etap_MultiObject_EtapRef__c *string `xml:"etap_MultiObject_EtapRef__c,omitempty" json:"etap_MultiObject_EtapRef__c,omitempty"`
 }
 
 type Npsp__General_Accounting_Unit__c struct {
-	Etap_Fund_Ref__c *string
+	Etap_Fund_Ref__c *string `xml:"etap_Fund_Ref__c,omitempty" json:"etap_Fund_Ref__c,omitempty"`
 }
 
 type Opportunity struct {
-	Etap_MultiObject_EtapRef__c *string
+	Etap_MultiObject_EtapRef__c *string `xml:"etap_MultiObject_EtapRef__c,omitempty" json:"etap_MultiObject_EtapRef__c,omitempty"`
 }
 
 type Npe01__OppPayment__c struct {
-	Etap_Payment_Ref__c *string
+	Etap_Payment_Ref__c   *string `xml:"etap_Payment_Ref__c,omitempty" json:"etap_Payment_Ref__c,omitempty"`
+	Npe01__Opportunity__c *ID     `xml:"npe01__Opportunity__c,omitempty" json:"npe01__Opportunity__c,omitempty"`
 }
 
 type Npe03__Recurring_Donation__c struct {
-	Etap_RecurringGiftSchedule_Ref__c *string
+	Etap_RecurringGiftSchedule_Ref__c *string `xml:"etap_RecurringGiftSchedule_Ref__c,omitempty" json:"etap_RecurringGiftSchedule_Ref__c,omitempty"`
 }
 
 type Npsp__Partial_Soft_Credit__c struct {
-	Etap_SoftCredit_Ref__c *string
+	Etap_SoftCredit_Ref__c *string `xml:"etap_SoftCredit_Ref__c,omitempty" json:"etap_SoftCredit_Ref__c,omitempty"`
 }
 
 type Npe4__Relationship__c struct {
-	Etap_Relationship_Ref__c *string
+	Etap_Relationship_Ref__c *string `xml:"etap_Relationship_Ref__c,omitempty" json:"etap_Relationship_Ref__c,omitempty"`
+	Npe4__Contact__c         *ID     `xml:"npe4__Contact__c,omitempty" json:"npe4__Contact__c,omitempty"`
 }
 
 type Task struct {
-	Etap_MultiObject_EtapRef__c *string
+	Etap_MultiObject_EtapRef__c *string `xml:"etap_MultiObject_EtapRef__c,omitempty" json:"etap_MultiObject_EtapRef__c,omitempty"`
 }
 
 type Npsp__Allocation__c struct {
-	Etap_MultiObject_EtapRef__c *string
+	Etap_MultiObject_EtapRef__c      *string `xml:"etap_MultiObject_EtapRef__c,omitempty" json:"etap_MultiObject_EtapRef__c,omitempty"`
+	Npsp__Campaign__c                *ID     `xml:"npsp__Campaign__c,omitempty" json:"npsp__Campaign__c,omitempty"`
+	Npsp__General_Accounting_Unit__c *ID     `xml:"npsp__General_Accounting_Unit__c,omitempty" json:"npsp__General_Accounting_Unit__c,omitempty"`
+	Npsp__Opportunity__c             *ID     `xml:"npsp__Opportunity__c,omitempty" json:"npsp__Opportunity__c,omitempty"`
+	Npsp__Recurring_Donation__c      *ID     `xml:"npsp__Recurring_Donation__c,omitempty" json:"npsp__Recurring_Donation__c,omitempty"`
 }
 
-type Npo02__Household__c struct{}
+type Npo02__Household__c struct {
+	Etap_MultiObject_EtapRef__c *string `xml:"etap_MultiObject_EtapRef__c,omitempty" json:"etap_MultiObject_EtapRef__c,omitempty"`
+}
 
 type ContentNote struct{}
 
 type Task_Subject_ string
 
 type RecordType struct {
-	Id          *ID
-	Name        *string
-	SobjectType *string
+	Id          *ID     `xml:"Id,omitempty" json:"Id,omitempty"`
+	Name        *string `xml:"Name,omitempty" json:"Name,omitempty"`
+	SobjectType *string `xml:"SobjectType,omitempty" json:"SobjectType,omitempty"`
 }
@@ -0,0 +1,184 @@
+// Package profile picks which Salesforce org the migration talks to. Each
+// named profile (like a dev sandbox, UAT and production) has its own
+// credentials in secrets/ and its own directory for the upload state, so
+// switching orgs doesn't mean overwriting files.
+//
+// The profile is chosen with the --profile flag, the ETAP2SF_PROFILE
+// environment variable, or salesforce.default_profile in the config, in that
+// order. Without any configured profiles, the original single set of
+// credentials and the data/ directory are used, as before profiles existed.
+package profile
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Silicon-Ally/etap2sf/config"
+	"github.com/Silicon-Ally/etap2sf/logging"
+	"github.com/Silicon-Ally/etap2sf/utils"
+)
+
+const EnvVar = "ETAP2SF_PROFILE"
+
+// legacyName is the profile that's used when none are configured.
+const legacyName = "default"
+
+type Profile struct {
+	Name string
+	// ConnectionConfig is the file in secrets/ with the org's credentials.
+	ConnectionConfig string
+	// Production is set for orgs with real data in them. Writes to them need
+	// a typed confirmation, and destructive operations are blocked.
+	Production bool
+	// Auth is how the profile logs in, if it's not with a username and password.
+	Auth config.SalesforceAuth
+	// legacy is set for the profile that's used when none are configured,
+	// which keeps its state directly in data/.
+	legacy bool
+}
+
+var (
+	mu        sync.Mutex
+	active    *Profile
+	confirmed bool
+)
+
+// Select makes the named profile the active one. An empty name picks the
+// profile from the environment or the config.
+func Select(name string) error {
+	p, err := resolve(name)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	active = p
+	confirmed = false
+	return nil
+}
+
+// Active returns the selected profile, picking one from the environment or
+// the config if none has been selected.
+func Active() (*Profile, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if active == nil {
+		p, err := resolve("")
+		if err != nil {
+			return nil, err
+		}
+		active = p
+	}
+	return active, nil
+}
+
+func resolve(name string) (*Profile, error) {
+	if name == "" {
+		name = os.Getenv(EnvVar)
+	}
+	c := config.Get().Salesforce
+	if name == "" {
+		name = c.DefaultProfile
+	}
+	if name == "" {
+		switch len(c.Profiles) {
+		case 0:
+			return &Profile{Name: legacyName, ConnectionConfig: "salesforce-connection-config.txt", legacy: true}, nil
+		case 1:
+			for n := range c.Profiles {
+				name = n
+			}
+		default:
+			return nil, fmt.Errorf("several Salesforce profiles are configured (%s) - pick one with --profile or %s", strings.Join(names(c.Profiles), ", "), EnvVar)
+		}
+	}
+	pc, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown Salesforce profile %q, configured profiles are: %s", name, strings.Join(names(c.Profiles), ", "))
+	}
+	p := &Profile{Name: name, ConnectionConfig: pc.ConnectionConfig, Production: pc.Production, Auth: pc.Auth}
+	if p.ConnectionConfig == "" {
+		p.ConnectionConfig = "salesforce-" + name + "-connection-config.txt"
+	}
+	return p, nil
+}
+
+func names(profiles map[string]config.SalesforceProfile) []string {
+	result := []string{}
+	for n := range profiles {
+		result = append(result, n)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// DataDir is where the state that belongs to the profile's org (like what's
+// been uploaded to it) is kept: data/profiles/<name>, or data/ when no
+// profiles are configured.
+func (p *Profile) DataDir() (string, error) {
+	dir := filepath.Join(utils.ProjectRoot(), "data")
+	if !p.legacy {
+		dir = filepath.Join(dir, "profiles", p.Name)
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return "", fmt.Errorf("creating data directory for profile %q: %w", p.Name, err)
+	}
+	return dir, nil
+}
+
+// DataPath is the path of a file in the active profile's data directory.
+func DataPath(fileName string) (string, error) {
+	p, err := Active()
+	if err != nil {
+		return "", err
+	}
+	dir, err := p.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, fileName), nil
+}
+
+// ConfirmWrite asks the user to type the name of the profile before anything
+// is written to a production org. It's only asked once per run, and does
+// nothing for other orgs.
+func ConfirmWrite(action string) error {
+	p, err := Active()
+	if err != nil {
+		return err
+	}
+	if !p.Production {
+		return nil
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if confirmed {
+		return nil
+	}
+	fmt.Printf("About to %s in PRODUCTION (profile %q).\nType the name of the profile to continue: ", action, p.Name)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if strings.TrimSpace(answer) != p.Name {
+		return fmt.Errorf("not confirmed - nothing was written to the %q org", p.Name)
+	}
+	logging.For("profile").Info("confirmed writes to production", "profile", p.Name, "action", action)
+	confirmed = true
+	return nil
+}
+
+// RefuseOnProduction returns an error if the active profile is production,
+// for operations that are too destructive to ever run there.
+func RefuseOnProduction(action string) error {
+	p, err := Active()
+	if err != nil {
+		return err
+	}
+	if p.Production {
+		return fmt.Errorf("refusing to %s: profile %q is a production org", action, p.Name)
+	}
+	return nil
+}
@@ -14,9 +14,11 @@ const (
 	ObjectType_Campaign              ObjectType = "Campaign"
 	ObjectType_Contact               ObjectType = "Contact"
 	ObjectType_ContentDocumentLink   ObjectType = "ContentDocumentLink"
+	ObjectType_ContentNote           ObjectType = "ContentNote"
 	ObjectType_ContentVersion        ObjectType = "ContentVersion"
 	ObjectType_GeneralAccountingUnit ObjectType = "GeneralAccountingUnit"
 	ObjectType_GAUAllocation         ObjectType = "GAUAllocation"
+	ObjectType_Household             ObjectType = "Household"
 	ObjectType_Opportunity           ObjectType = "Opportunity"
 	ObjectType_Payment               ObjectType = "Payment"
 	ObjectType_PartialSoftCredit     ObjectType = "PartialSoftCredit"
@@ -33,9 +35,11 @@ var ObjectTypes = []ObjectType{
 	ObjectType_Campaign,
 	ObjectType_Contact,
 	ObjectType_ContentDocumentLink,
+	ObjectType_ContentNote,
 	ObjectType_ContentVersion,
 	ObjectType_GeneralAccountingUnit,
 	ObjectType_GAUAllocation,
+	ObjectType_Household,
 	ObjectType_Opportunity,
 	ObjectType_Payment,
 	ObjectType_PartialSoftCredit,
@@ -60,12 +64,16 @@ func (ot ObjectType) SalesforceName() (string, error) {
 		return "Contact", nil
 	case ObjectType_ContentDocumentLink:
 		return "ContentDocumentLink", nil
+	case ObjectType_ContentNote:
+		return "ContentNote", nil
 	case ObjectType_ContentVersion:
 		return "ContentVersion", nil
 	case ObjectType_GeneralAccountingUnit:
 		return "npsp__General_Accounting_Unit__c", nil
 	case ObjectType_GAUAllocation:
 		return "npsp__Allocation__c", nil
+	case ObjectType_Household:
+		return "npo02__Household__c", nil
 	case ObjectType_Opportunity:
 		return "Opportunity", nil
 	case ObjectType_Payment:
@@ -105,6 +113,9 @@ func (ot ObjectType) SalesforceObjectExternalFieldKey() (string, error) {
 		return MultiObjectExternalFieldKey, nil
 	case ObjectType_ContentDocumentLink:
 		return "Id", nil
+	case ObjectType_ContentNote:
+		// Notes can't have custom fields, so they're created rather than upserted.
+		return "Id", nil
 	case ObjectType_ContentVersion:
 		return MultiObjectExternalFieldKey, nil
 	case ObjectType_Contact:
@@ -113,6 +124,8 @@ func (ot ObjectType) SalesforceObjectExternalFieldKey() (string, error) {
 		return "etap_Fund_Ref__c", nil
 	case ObjectType_GAUAllocation:
 		return MultiObjectExternalFieldKey, nil
+	case ObjectType_Household:
+		return MultiObjectExternalFieldKey, nil
 	case ObjectType_Opportunity:
 		return MultiObjectExternalFieldKey, nil
 	case ObjectType_Payment:
@@ -150,7 +163,8 @@ func (ot ObjectType) SalesforceLayoutNeedingFilesButton() ([]string, error) {
 	case ObjectType_Account, ObjectType_Affiliation, ObjectType_Campaign,
 		ObjectType_GeneralAccountingUnit, ObjectType_Contact, ObjectType_GAUAllocation,
 		ObjectType_Opportunity, ObjectType_RecurringDonation, ObjectType_AdditionalContext,
-		ObjectType_Relationship, ObjectType_ContentDocumentLink, ObjectType_ContentVersion:
+		ObjectType_Relationship, ObjectType_ContentDocumentLink, ObjectType_ContentVersion,
+		ObjectType_ContentNote, ObjectType_Household:
 		return []string{}, nil
 	case ObjectType_Payment:
 		return []string{"Payment"}, nil
@@ -170,7 +184,8 @@ func (ot ObjectType) SalesforceLayoutNeedingManualIntervention() ([]string, erro
 	case ObjectType_Account, ObjectType_Affiliation, ObjectType_Campaign,
 		ObjectType_GeneralAccountingUnit, ObjectType_Contact,
 		ObjectType_Opportunity, ObjectType_Payment, ObjectType_RecurringDonation,
-		ObjectType_Relationship, ObjectType_ContentDocumentLink, ObjectType_ContentVersion:
+		ObjectType_Relationship, ObjectType_ContentDocumentLink, ObjectType_ContentVersion,
+		ObjectType_ContentNote, ObjectType_Household:
 		return []string{}, nil
 	case ObjectType_AdditionalContext:
 		return []string{"etap_AdditionalContext__c-AdditionalContext Layout"}, nil
@@ -202,12 +217,17 @@ func (ot ObjectType) SalesforceFlexiPageNames() ([]string, error) {
 		return []string{"NPSP_Contact_Record_Page"}, nil
 	case ObjectType_ContentDocumentLink:
 		return []string{}, nil
+	case ObjectType_ContentNote:
+		return []string{}, nil
 	case ObjectType_ContentVersion:
 		return []string{}, nil
 	case ObjectType_GeneralAccountingUnit:
 		return []string{"NPSP_General_Accounting_Unit"}, nil
 	case ObjectType_GAUAllocation:
 		return []string{"NPSP_GAU_Allocation"}, nil
+	case ObjectType_Household:
+		// Legacy households predate Lightning record pages.
+		return []string{}, nil
 	case ObjectType_Opportunity:
 		return []string{"npsp__NPSP_Opportunity_Record_Page", "NPSP_Opportunity_Record_Page"}, nil
 	case ObjectType_Payment:
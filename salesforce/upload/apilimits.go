@@ -0,0 +1,179 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Silicon-Ally/etap2sf/config"
+	"github.com/Silicon-Ally/etap2sf/logging"
+	"github.com/Silicon-Ally/etap2sf/salesforce/apilimits"
+)
+
+const (
+	defaultSlowDownAt       = 0.8
+	defaultSlowDownInterval = time.Second
+	defaultStopAt           = 0.9
+)
+
+// APILimitError is why an upload stopped early: the org had used the share of
+// its daily API requests that uploads are allowed.
+type APILimitError struct {
+	Usage  apilimits.Usage
+	StopAt float64
+}
+
+func (e *APILimitError) Error() string {
+	return fmt.Sprintf("stopped with %s of the org's daily api requests used, which is past upload.api_limits.stop_at (%.0f%%) - run the upload again once usage has dropped", e.Usage, e.StopAt*100)
+}
+
+// apiGate keeps an upload within its share of the org's daily API requests,
+// going by the usage that Salesforce last reported. It's checked before each
+// call, across all of the phases that are running.
+type apiGate struct {
+	slowDownAt float64
+	stopAt     float64
+	interval   time.Duration
+	cancel     context.CancelCauseFunc
+
+	mu      sync.Mutex
+	next    time.Time
+	slowing bool
+}
+
+func newAPIGate(cancel context.CancelCauseFunc) *apiGate {
+	c := config.Get().Upload.APILimits
+	g := &apiGate{
+		slowDownAt: defaultSlowDownAt,
+		stopAt:     defaultStopAt,
+		interval:   defaultSlowDownInterval,
+		cancel:     cancel,
+	}
+	if c.SlowDownAt > 0 {
+		g.slowDownAt = c.SlowDownAt
+	}
+	if c.StopAt > 0 {
+		g.stopAt = c.StopAt
+	}
+	if c.SlowDownIntervalSeconds > 0 {
+		g.interval = time.Duration(c.SlowDownIntervalSeconds * float64(time.Second))
+	}
+	return g
+}
+
+// wait is called before each call to Salesforce. Past stop_at, it stops the
+// upload and returns false. Past slow_down_at, it waits until the next call is
+// due, returning false if the upload is stopped in the meantime.
+func (g *apiGate) wait(ctx context.Context) bool {
+	if g == nil {
+		return true
+	}
+	usage := apilimits.Current()
+	if !usage.Known() {
+		return true
+	}
+	if usage.Fraction() >= g.stopAt {
+		g.cancel(&APILimitError{Usage: usage, StopAt: g.stopAt})
+		return false
+	}
+	g.mu.Lock()
+	if usage.Fraction() < g.slowDownAt {
+		if g.slowing {
+			g.slowing = false
+			logging.For("upload").Info("api usage is back under upload.api_limits.slow_down_at, no longer slowing down", "usage", usage.String())
+		}
+		g.mu.Unlock()
+		return true
+	}
+	if !g.slowing {
+		g.slowing = true
+		logging.For("upload").Warn("api usage is past upload.api_limits.slow_down_at, slowing down", "usage", usage.String(), "calls_before_stop", g.callsBeforeStop(usage), "interval", g.interval.String())
+	}
+	now := time.Now()
+	if g.next.Before(now) {
+		g.next = now
+	}
+	wait := g.next.Sub(now)
+	g.next = g.next.Add(g.interval)
+	g.mu.Unlock()
+	return sleep(ctx, wait)
+}
+
+// callsBeforeStop is how many more calls can be made before the upload stops.
+func (g *apiGate) callsBeforeStop(usage apilimits.Usage) int {
+	n := int(g.stopAt*float64(usage.Max)) - usage.Used
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// callsPerBatcher is implemented by batch clients that take more than one
+// call per batch, like the bulk client.
+type callsPerBatcher interface {
+	CallsPerBatch() int
+}
+
+// estimateCalls is roughly how many calls uploading n records takes, at the
+// given number of records and calls per batch. Retries aren't counted.
+func estimateCalls(n, perBatch, callsPerBatch int) int {
+	if perBatch < 1 {
+		perBatch = 1
+	}
+	return (n + perBatch - 1) / perBatch * callsPerBatch
+}
+
+// reportEstimate logs how many calls a run is expected to take, and warns if
+// that's more than the org has left before the upload would stop.
+func (u *Uploader) reportEstimate(rs *runState, todo int) {
+	calls := estimateCalls(todo, rs.perBatch, rs.callsPerBatch)
+	apilimits.SetPhaseEstimate(rs.name, calls)
+	logger := logging.For("upload").With("object_type", rs.name, "records", todo, "estimated_calls", calls)
+	usage := apilimits.Current()
+	if !usage.Known() || u.apiGate == nil {
+		logger.Info("estimated api calls for phase")
+		return
+	}
+	left := u.apiGate.callsBeforeStop(usage)
+	logger = logger.With("usage", usage.String(), "calls_before_stop", left)
+	if calls <= left {
+		logger.Info("estimated api calls for phase")
+		return
+	}
+	logger.Warn("phase is likely to stop at the api limit before it's done")
+	fmt.Printf("Uploading %d %s takes about %d API calls, but only %d are left before the upload stops at %.0f%% of the org's daily allowance (%s used).\n", todo, rs.name, calls, left, u.apiGate.stopAt*100, usage)
+}
+
+// recordDone updates the estimated calls left in a run after a record is done.
+func (rs *runState) recordDone() {
+	left := int(rs.remaining.Add(-1))
+	if left < 0 {
+		left = 0
+	}
+	apilimits.SetPhaseEstimate(rs.name, estimateCalls(left, rs.perBatch, rs.callsPerBatch))
+}
+
+type apiUsageFetcher interface {
+	FetchAPIUsage() (apilimits.Usage, error)
+}
+
+// checkAPIUsage reports the org's API usage before the upload starts, and
+// refuses to start if it's already past stop_at. If the usage can't be
+// fetched, the upload goes ahead, and the usage is known after its first call.
+func (u *Uploader) checkAPIUsage() error {
+	f, ok := u.client.(apiUsageFetcher)
+	if !ok || !u.Wetrun {
+		return nil
+	}
+	usage, err := f.FetchAPIUsage()
+	if err != nil {
+		logging.For("upload").Warn("couldn't get the org's api usage, going ahead without it", "error", err)
+		return nil
+	}
+	fmt.Printf("The org has used %s of its daily API requests, and %d calls are left before the upload stops.\n", usage, u.apiGate.callsBeforeStop(usage))
+	if usage.Fraction() >= u.apiGate.stopAt {
+		return &APILimitError{Usage: usage, StopAt: u.apiGate.stopAt}
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Silicon-Ally/etap2sf/config"
+	"github.com/Silicon-Ally/etap2sf/logging"
+	"github.com/Silicon-Ally/etap2sf/salesforce"
+	bulkclient "github.com/Silicon-Ally/etap2sf/salesforce/clients/bulk"
+	enterprise "github.com/Silicon-Ally/etap2sf/salesforce/clients/enterprise"
+	restclient "github.com/Silicon-Ally/etap2sf/salesforce/clients/rest"
+	"github.com/Silicon-Ally/etap2sf/utils"
+)
+
+type Backend string
+
+const (
+	Backend_SOAP      Backend = "soap"
+	Backend_SOAPBatch Backend = "soap-batch"
+	Backend_Bulk      Backend = "bulk"
+	Backend_REST      Backend = "rest"
+	// Backend_RESTGraph is only for opportunities, which are uploaded along
+	// with their payments and allocations through REST Composite Graph.
+	Backend_RESTGraph Backend = "rest-graph"
+)
+
+// batchClient is implemented by clients which can upsert many records of a
+// single type at once. The returned IDs and errors line up with the records,
+// and the call gives up once the context ends, i.e. when the upload is stopped.
+type batchClient interface {
+	MaxBatchSize() int
+	UpsertBatch(ctx context.Context, sot salesforce.ObjectType, records []any) ([]string, []error, error)
+}
+
+var (
+	_ client      = (*bulkclient.Client)(nil)
+	_ batchClient = (*bulkclient.Client)(nil)
+	_ batchClient = (*enterprise.Client)(nil)
+	_ client      = (*restclient.Client)(nil)
+	_ batchClient = (*restclient.Client)(nil)
+	_ graphClient = (*restclient.Client)(nil)
+)
+
+// backendsFromConfig reads which backend each object type should use from the
+// config file. Object types that aren't mentioned use SOAP.
+func backendsFromConfig(c *config.Upload) (map[salesforce.ObjectType]Backend, error) {
+	sots := map[string]salesforce.ObjectType{}
+	for _, sot := range salesforce.ObjectTypes {
+		sots[string(sot)] = sot
+	}
+	result := map[salesforce.ObjectType]Backend{}
+	for k, v := range c.Backends {
+		sot, ok := sots[k]
+		if !ok {
+			return nil, fmt.Errorf("unknown salesforce object type %q", k)
+		}
+		b := Backend(v)
+		if sot == salesforce.ObjectType_ContentNote && b != Backend_SOAP {
+			return nil, fmt.Errorf("%s can only be uploaded with the %s backend", sot, Backend_SOAP)
+		}
+		switch b {
+		case Backend_SOAP:
+		case Backend_SOAPBatch:
+			result[sot] = b
+		case Backend_Bulk, Backend_REST:
+			if sot == salesforce.ObjectType_ContentVersion {
+				return nil, fmt.Errorf("%s can't be uploaded with the %s backend", sot, b)
+			}
+			result[sot] = b
+		case Backend_RESTGraph:
+			if sot != salesforce.ObjectType_Opportunity {
+				return nil, fmt.Errorf("the %s backend is only for %s, not %s", b, salesforce.ObjectType_Opportunity, sot)
+			}
+			result[sot] = b
+		default:
+			return nil, fmt.Errorf("unknown backend %q for %s, expected %q, %q, %q, %q or %q", v, k, Backend_SOAP, Backend_SOAPBatch, Backend_Bulk, Backend_REST, Backend_RESTGraph)
+		}
+	}
+	return result, nil
+}
+
+func (u *Uploader) setUpBackends(ec *enterprise.Client) error {
+	backends, err := backendsFromConfig(&config.Get().Upload)
+	if err != nil {
+		return fmt.Errorf("reading upload backends from %s: %w", config.Path(), err)
+	}
+	u.batchClients = map[salesforce.ObjectType]batchClient{}
+	var bulk *bulkclient.Client
+	var rest *restclient.Client
+	for sot, b := range backends {
+		switch b {
+		case Backend_SOAPBatch:
+			u.batchClients[sot] = ec
+		case Backend_Bulk:
+			if bulk == nil {
+				if bulk, err = bulkclient.New(ec); err != nil {
+					return fmt.Errorf("creating bulk client: %w", err)
+				}
+			}
+			u.batchClients[sot] = bulk
+		case Backend_REST, Backend_RESTGraph:
+			if rest == nil {
+				if rest, err = restclient.New(ec); err != nil {
+					return fmt.Errorf("creating rest client: %w", err)
+				}
+			}
+			if b == Backend_RESTGraph {
+				u.graphClient = rest
+			} else {
+				u.batchClients[sot] = rest
+			}
+		}
+	}
+	return nil
+}
+
+// upsert uploads the given records of the given type, grouping them into
+// batches if a batch backend was configured for the type, and one at a time if not.
+func upsert[T any](ctx context.Context, u *Uploader, sot salesforce.ObjectType, ts []T, idFn func(t T) string, fn func(t T) (string, error)) error {
+	if bc, ok := u.batchClients[sot]; ok {
+		return runBatches(ctx, u, sot, bc, ts, idFn, u.hard())
+	}
+	return run(ctx, u, sot, ts, idFn, fn, u.hard())
+}
+
+func runBatches[T any](ctx context.Context, u *Uploader, sot salesforce.ObjectType, bc batchClient, ts []T, idFn func(t T) string, hard bool) error {
+	defer func() {
+		if err := save(u); err != nil {
+			logging.For("upload").Error("failed to save output", "error", err)
+		}
+	}()
+	if len(ts) == 0 {
+		return nil
+	}
+	if u.DoShuffles {
+		// As in run, shuffling avoids lock contention between batches.
+		utils.Shuffle(ts)
+	}
+	name := fmt.Sprintf("%T", ts[0])
+	errors := []error{}
+	u.stateChangeMutex.Lock()
+	changed, hashes, _, counts := classify(u, ts, idFn, hard)
+	u.stateChangeMutex.Unlock()
+	u.addChangeCounts(sot, name, counts)
+	retained := []T{}
+	seen := map[string]bool{}
+	for _, t := range changed {
+		id := idFn(t)
+		if seen[id] {
+			errors = append(errors, fmt.Errorf("duplicate ref %s in %s", id, name))
+			continue
+		}
+		seen[id] = true
+		retained = append(retained, t)
+	}
+	callsPerBatch := 1
+	if cb, ok := bc.(callsPerBatcher); ok {
+		callsPerBatch = cb.CallsPerBatch()
+	}
+	rs := u.newRunState(name, sot, len(retained), bc.MaxBatchSize(), callsPerBatch)
+	rs.hashes = hashes
+
+	batches := utils.SplitIntoBatches(retained, bc.MaxBatchSize())
+	split := splitByNumThreads(batches, u.NumThreads)
+	errorsChan := make(chan []error)
+	for _, bs := range split {
+		go func(bs [][]T) {
+			errorsChan <- runBatchThread(ctx, u, rs, sot, bc, bs, idFn)
+		}(bs)
+	}
+	for range split {
+		errors = append(errors, <-errorsChan...)
+	}
+	if err := ctx.Err(); err != nil {
+		rs.progress.Done()
+		return fmt.Errorf("interrupted with %d errors: %w", len(errors), context.Cause(ctx))
+	}
+	rs.progress.Done()
+	if err := rs.persistError(); err != nil {
+		return err
+	}
+	logging.For("upload").Info("done with object type", "object_type", rs.name, "retained", len(retained), "batches", len(batches), "errors", len(errors))
+	return handleErrors(errors)
+}
+
+func runBatchThread[T any](ctx context.Context, u *Uploader, rs *runState, sot salesforce.ObjectType, bc batchClient, batches [][]T, idFn func(t T) string) []error {
+	maxErrorsPerThread := u.MaxErrors / u.NumThreads
+	if maxErrorsPerThread < 1 {
+		return []error{fmt.Errorf("max errors per thread must be at least 1")}
+	}
+	errors := []error{}
+	for _, batch := range batches {
+		if len(errors) >= maxErrorsPerThread {
+			rs.anyThreadDead.Store(true)
+			return errors
+		}
+		if rs.anyThreadDead.Load() || ctx.Err() != nil {
+			return errors
+		}
+		records := make([]any, len(batch))
+		for i, t := range batch {
+			records[i] = t
+		}
+		if !u.apiGate.wait(ctx) || !rs.limiter.acquire(ctx) {
+			return errors
+		}
+		ids, errs, err := upsertBatchWithRetries(ctx, rs, sot, bc, records)
+		rs.limiter.release()
+		if err != nil {
+			err = fmt.Errorf("upserting batch of %d %s: %w", len(batch), sot, err)
+			for _, t := range batch {
+				u.failed(rs, idFn(t), err)
+			}
+			errors = append(errors, err)
+			continue
+		}
+		if len(ids) != len(batch) || len(errs) != len(batch) {
+			return append(errors, fmt.Errorf("expected %d results for batch of %s, got %d ids and %d errors", len(batch), sot, len(ids), len(errs)))
+		}
+		for i, t := range batch {
+			if errs[i] != nil {
+				errors = append(errors, fmt.Errorf("%s: %w", idFn(t), errs[i]))
+				u.failed(rs, idFn(t), errs[i])
+			} else {
+				u.succeeded(rs, idFn(t), ids[i])
+			}
+		}
+	}
+	return errors
+}
+
+// upsertBatchWithRetries upserts a batch, retrying the call if it fails in a
+// way that's worth retrying, and then retrying (as a smaller batch) the
+// records that failed with row locks and the like.
+func upsertBatchWithRetries(ctx context.Context, rs *runState, sot salesforce.ObjectType, bc batchClient, records []any) ([]string, []error, error) {
+	type result struct {
+		ids  []string
+		errs []error
+	}
+	ids := make([]string, len(records))
+	errs := make([]error, len(records))
+	pending := make([]int, len(records))
+	for i := range records {
+		pending[i] = i
+	}
+	for attempt := 0; ; attempt++ {
+		batch := make([]any, len(pending))
+		for j, i := range pending {
+			batch[j] = records[i]
+		}
+		r, err := withRetries(ctx, rs, rs.maxRetries, func() (*result, error) {
+			ids, errs, err := bc.UpsertBatch(ctx, sot, batch)
+			return &result{ids: ids, errs: errs}, err
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(r.ids) != len(batch) || len(r.errs) != len(batch) {
+			return nil, nil, fmt.Errorf("expected %d results for batch of %s, got %d ids and %d errors", len(batch), sot, len(r.ids), len(r.errs))
+		}
+		retry := []int{}
+		for j, i := range pending {
+			ids[i], errs[i] = r.ids[j], r.errs[j]
+			retriable, lock := isRetriable(r.errs[j])
+			rs.limiter.observe(lock)
+			if retriable && attempt < rs.maxRetries {
+				retry = append(retry, i)
+			}
+		}
+		if len(retry) == 0 {
+			return ids, errs, nil
+		}
+		wait := backoff(attempt)
+		logging.For("upload").Debug("retrying records in batch after transient errors", "object_type", rs.name, "records", len(retry), "attempt", attempt+1, "wait", wait.Round(time.Millisecond).String())
+		if !sleep(ctx, wait) {
+			return ids, errs, nil
+		}
+		pending = retry
+	}
+}
@@ -0,0 +1,192 @@
+package upload
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Silicon-Ally/etap2sf/config"
+	"github.com/Silicon-Ally/etap2sf/logging"
+	"github.com/Silicon-Ally/etap2sf/salesforce"
+)
+
+const (
+	defaultMaxRetries = 5
+	baseBackoff       = time.Second
+	maxBackoff        = 30 * time.Second
+
+	// The thread count is re-evaluated after this many attempts. If more than
+	// shrinkAbove of them hit a lock, it's halved, and if fewer than growBelow
+	// did, it goes up by one (to at most NumThreads).
+	adjustEvery = 50
+	shrinkAbove = 0.10
+	growBelow   = 0.01
+)
+
+// retriableCodes are the Salesforce errors that are likely to succeed if the
+// record is tried again a little later. They're also matched against errors
+// from calls that failed outright, since the SOAP client only gives us a string.
+var retriableCodes = []string{"UNABLE_TO_LOCK_ROW", "REQUEST_LIMIT_EXCEEDED", "SERVER_UNAVAILABLE"}
+
+// isRetriable reports whether the error is worth retrying, and if so whether
+// it was lock contention, which is what the thread count adapts to.
+func isRetriable(err error) (retriable, lock bool) {
+	if err == nil {
+		return false, false
+	}
+	code := ""
+	if re, ok := salesforce.AsRecordError(err); ok {
+		code = re.StatusCode
+	} else {
+		msg := err.Error()
+		for _, c := range retriableCodes {
+			if strings.Contains(msg, c) {
+				code = c
+				break
+			}
+		}
+	}
+	for _, c := range retriableCodes {
+		if code == c {
+			return true, code == "UNABLE_TO_LOCK_ROW"
+		}
+	}
+	return false, false
+}
+
+func maxRetriesFromConfig() int {
+	if n := config.Get().Upload.MaxRetries; n > 0 {
+		return n
+	}
+	return defaultMaxRetries
+}
+
+// backoff is the (exponential, with full jitter) delay before the given retry.
+func backoff(attempt int) time.Duration {
+	d := baseBackoff << attempt
+	if d <= 0 || d > maxBackoff {
+		d = maxBackoff
+	}
+	return time.Duration(rand.Int63n(int64(d))) + baseBackoff
+}
+
+// sleep waits for d, returning false if the context is cancelled first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// withRetries calls fn until it succeeds, fails in a way that isn't worth
+// retrying, or has been retried maxRetries times.
+func withRetries[T any](ctx context.Context, rs *runState, maxRetries int, fn func() (T, error)) (T, error) {
+	for attempt := 0; ; attempt++ {
+		t, err := fn()
+		retriable, lock := isRetriable(err)
+		rs.limiter.observe(lock)
+		if !retriable || attempt >= maxRetries {
+			return t, err
+		}
+		wait := backoff(attempt)
+		logging.For("upload").Debug("retrying after a transient error", "object_type", rs.name, "attempt", attempt+1, "wait", wait.Round(time.Millisecond).String(), "error", err)
+		if !sleep(ctx, wait) {
+			return t, err
+		}
+	}
+}
+
+// limiter caps how many of a run's threads are uploading at once. The cap
+// starts at the configured number of threads, and moves down when records
+// start hitting row locks, and back up when they stop.
+type limiter struct {
+	name string
+	max  int
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	limit    int
+	inUse    int
+	attempts int
+	locks    int
+}
+
+func newLimiter(name string, max int) *limiter {
+	if max < 1 {
+		max = 1
+	}
+	l := &limiter{name: name, max: max, limit: max}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// acquire waits for a free slot, returning false if the context is cancelled first.
+func (l *limiter) acquire(ctx context.Context) bool {
+	if l == nil {
+		return ctx.Err() == nil
+	}
+	stop := context.AfterFunc(ctx, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.cond.Broadcast()
+	})
+	defer stop()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.inUse >= l.limit {
+		if ctx.Err() != nil {
+			return false
+		}
+		l.cond.Wait()
+	}
+	if ctx.Err() != nil {
+		return false
+	}
+	l.inUse++
+	return true
+}
+
+func (l *limiter) release() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inUse--
+	l.cond.Broadcast()
+}
+
+// observe records the outcome of an attempt, adjusting the limit every adjustEvery attempts.
+func (l *limiter) observe(lock bool) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.attempts++
+	if lock {
+		l.locks++
+	}
+	if l.attempts < adjustEvery {
+		return
+	}
+	rate := float64(l.locks) / float64(l.attempts)
+	old := l.limit
+	switch {
+	case rate > shrinkAbove:
+		l.limit = max(1, l.limit/2)
+	case rate < growBelow:
+		l.limit = min(l.max, l.limit+1)
+	}
+	l.attempts, l.locks = 0, 0
+	if l.limit != old {
+		logging.For("upload").Info("adjusted thread count for lock contention", "object_type", l.name, "lock_rate", rate, "from", old, "to", l.limit)
+		l.cond.Broadcast()
+	}
+}
@@ -0,0 +1,88 @@
+package upload
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Silicon-Ally/etap2sf/salesforce"
+)
+
+func TestIsRetriable(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		wantRetriable bool
+		wantLock      bool
+	}{
+		{"no error", nil, false, false},
+		{"row lock", &salesforce.RecordError{StatusCode: "UNABLE_TO_LOCK_ROW"}, true, true},
+		{"wrapped row lock", fmt.Errorf("1.0.1: %w", &salesforce.RecordError{StatusCode: "UNABLE_TO_LOCK_ROW"}), true, true},
+		{"request limit", &salesforce.RecordError{StatusCode: "REQUEST_LIMIT_EXCEEDED"}, true, false},
+		{"server unavailable", &salesforce.RecordError{StatusCode: "SERVER_UNAVAILABLE"}, true, false},
+		{"bad record", &salesforce.RecordError{StatusCode: "REQUIRED_FIELD_MISSING"}, false, false},
+		// A record's status code is what counts, not what its message says.
+		{"bad record mentioning a lock", &salesforce.RecordError{StatusCode: "FIELD_CUSTOM_VALIDATION_EXCEPTION", Message: "UNABLE_TO_LOCK_ROW"}, false, false},
+		{"failed call with a lock", errors.New("upserting batch: UNABLE_TO_LOCK_ROW: unable to obtain exclusive access to this record"), true, true},
+		{"failed call with a request limit", errors.New("soap fault: REQUEST_LIMIT_EXCEEDED: TotalRequests Limit exceeded"), true, false},
+		{"failed call", errors.New("soap fault: INVALID_SESSION_ID"), false, false},
+	}
+	for _, test := range tests {
+		retriable, lock := isRetriable(test.err)
+		if retriable != test.wantRetriable || lock != test.wantLock {
+			t.Errorf("%s: isRetriable = %t, %t, want %t, %t", test.name, retriable, lock, test.wantRetriable, test.wantLock)
+		}
+	}
+}
+
+// observeRound records a round of adjustEvery attempts, locks of which hit a
+// row lock, and returns the limit after it.
+func observeRound(l *limiter, locks int) int {
+	for i := 0; i < adjustEvery; i++ {
+		l.observe(i < locks)
+	}
+	return l.limit
+}
+
+func TestLimiter(t *testing.T) {
+	// Rounds with just over the given rate of locks: shrinkAbove shrinks the
+	// limit, and growBelow is too many to grow it.
+	justOver := func(rate float64) int { return int(rate*adjustEvery) + 1 }
+	contended, someLocks := justOver(shrinkAbove), justOver(growBelow)
+
+	l := newLimiter("test", 8)
+	if l.limit != 8 {
+		t.Fatalf("limit = %d, want to start at max", l.limit)
+	}
+	for i := 0; i < adjustEvery-1; i++ {
+		l.observe(true)
+	}
+	if l.limit != 8 {
+		t.Errorf("limit = %d after %d attempts, want no change before %d", l.limit, adjustEvery-1, adjustEvery)
+	}
+	l.observe(true)
+	if l.limit != 4 {
+		t.Errorf("limit = %d after a round of locks, want it halved to 4", l.limit)
+	}
+
+	for _, want := range []int{2, 1, 1} {
+		if got := observeRound(l, contended); got != want {
+			t.Errorf("limit = %d after a contended round, want %d", got, want)
+		}
+	}
+	if got := observeRound(l, someLocks); got != 1 {
+		t.Errorf("limit = %d after a round with a few locks, want it unchanged at 1", got)
+	}
+	for want := 2; want <= 8; want++ {
+		if got := observeRound(l, 0); got != want {
+			t.Errorf("limit = %d after a round without locks, want %d", got, want)
+		}
+	}
+	if got := observeRound(l, 0); got != 8 {
+		t.Errorf("limit = %d, want it to stay at max", got)
+	}
+
+	if l := newLimiter("test", 0); l.limit != 1 {
+		t.Errorf("limit = %d with no threads, want 1", l.limit)
+	}
+}
@@ -0,0 +1,324 @@
+// Package diff_against_salesforce is a dry run of the upload: it compares the
+// converted records with what's already in the target org, and reports which
+// records would be inserted, which would be updated, and which fields would
+// change, without writing anything to Salesforce.
+package diff_against_salesforce
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Silicon-Ally/etap2sf/conv/conversion"
+	"github.com/Silicon-Ally/etap2sf/logging"
+	"github.com/Silicon-Ally/etap2sf/salesforce"
+	client "github.com/Silicon-Ally/etap2sf/salesforce/clients/enterprise"
+	esfutils "github.com/Silicon-Ally/etap2sf/salesforce/clients/enterprise/utils"
+	"github.com/Silicon-Ally/etap2sf/salesforce/profile"
+)
+
+type Report struct {
+	GeneratedAt time.Time
+	Partial     bool
+	Types       []*TypeReport
+}
+
+type TypeReport struct {
+	ObjectType salesforce.ObjectType
+	// Inserts are the external keys of records that don't exist in the org yet.
+	Inserts   []string
+	Updates   []*RecordDiff
+	Unchanged int
+	// Errors are records that couldn't be compared, i.e. because they're missing their key.
+	Errors []string
+}
+
+type RecordDiff struct {
+	Key     string
+	ID      string
+	Changes []*FieldChange
+}
+
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// typedRecords are the records of one object type in the output, in the same
+// order that the uploader uploads them.
+type typedRecords struct {
+	sot     salesforce.ObjectType
+	records []any
+}
+
+func toAny[T any](ts []T) []any {
+	result := make([]any, len(ts))
+	for i, t := range ts {
+		result[i] = t
+	}
+	return result
+}
+
+func recordsByType(output *conversion.Output) []*typedRecords {
+	// Content document links and notes have no external key, they're always created.
+	return []*typedRecords{
+		{salesforce.ObjectType_Campaign, toAny(output.Campaigns)},
+		{salesforce.ObjectType_GeneralAccountingUnit, toAny(output.GeneralAccountingUnits)},
+		{salesforce.ObjectType_Account, toAny(output.Accounts)},
+		{salesforce.ObjectType_Household, toAny(output.Households)},
+		{salesforce.ObjectType_Contact, toAny(output.Contacts)},
+		{salesforce.ObjectType_Relationship, toAny(output.Relationships)},
+		{salesforce.ObjectType_Affiliation, toAny(output.Affiliations)},
+		{salesforce.ObjectType_RecurringDonation, toAny(output.RecurringDonations)},
+		{salesforce.ObjectType_Opportunity, toAny(output.Opportunities)},
+		{salesforce.ObjectType_Payment, toAny(output.Payments)},
+		{salesforce.ObjectType_GAUAllocation, toAny(output.GAUAllocations)},
+		{salesforce.ObjectType_PartialSoftCredit, toAny(output.PartialSoftCredits)},
+		{salesforce.ObjectType_AccountSoftCredit, toAny(output.AccountSoftCredits)},
+		{salesforce.ObjectType_AdditionalContext, toAny(output.AdditionalContexts)},
+		{salesforce.ObjectType_Task, toAny(output.Tasks)},
+		{salesforce.ObjectType_ContentVersion, toAny(output.ContentVersions)},
+	}
+}
+
+// ignoredFields can't be meaningfully compared - binary content isn't
+// returned by queries.
+var ignoredFields = map[string]bool{
+	"versiondata": true,
+}
+
+func Run(partial bool) error {
+	input, err := conversion.GetInput()
+	if err != nil {
+		return fmt.Errorf("failed to get input: %v", err)
+	}
+	if partial {
+		input = input.Sample()
+	}
+	output, err := input.Convert()
+	if err != nil {
+		return fmt.Errorf("failed to convert to output: %v", err)
+	}
+	c, err := esfutils.NewSandboxClient()
+	if err != nil {
+		return fmt.Errorf("creating client: %w", err)
+	}
+	report, err := diff(c, output)
+	if err != nil {
+		return err
+	}
+	report.Partial = partial
+
+	jsonPath, err := profile.DataPath("salesforce-diff.json")
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling report: %w", err)
+	}
+	if err := os.WriteFile(jsonPath, data, 0777); err != nil {
+		return fmt.Errorf("writing report to %s: %w", jsonPath, err)
+	}
+	summary := report.Summary()
+	summaryPath, err := profile.DataPath("salesforce-diff.txt")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(summaryPath, []byte(summary), 0777); err != nil {
+		return fmt.Errorf("writing summary to %s: %w", summaryPath, err)
+	}
+	fmt.Print(summary)
+	fmt.Printf("\nNothing was written to Salesforce. The full report is in %s\n", jsonPath)
+	return nil
+}
+
+func diff(c *client.Client, output *conversion.Output) (*Report, error) {
+	report := &Report{GeneratedAt: time.Now()}
+	all := recordsByType(output)
+
+	// First we fetch everything that already exists, so that references to other
+	// records can be resolved to their Salesforce IDs the same way the uploader does.
+	existing := map[salesforce.ObjectType]map[string]map[string]string{}
+	idMap := map[string]string{}
+	for _, tr := range all {
+		if len(tr.records) == 0 {
+			continue
+		}
+		fieldSet := map[string]bool{}
+		for _, r := range tr.records {
+			fields, err := client.StructToFieldsMap(r)
+			if err != nil {
+				continue
+			}
+			for k := range fields {
+				if !ignoredFields[strings.ToLower(k)] {
+					fieldSet[k] = true
+				}
+			}
+		}
+		fields := make([]string, 0, len(fieldSet))
+		for f := range fieldSet {
+			fields = append(fields, f)
+		}
+		sort.Strings(fields)
+		logging.For("diff").Info("fetching existing records", "object_type", string(tr.sot))
+		records, err := c.GetRecordsByExternalKey(tr.sot, fields)
+		if err != nil {
+			return nil, fmt.Errorf("getting existing %s records: %w", tr.sot, err)
+		}
+		existing[tr.sot] = records
+		for key, values := range records {
+			idMap[key] = values["id"]
+		}
+	}
+	// References to records that don't exist yet can't be resolved, and would
+	// only be once their targets are uploaded, so these errors are expected.
+	output.ReplaceAllIDsInContacts(idMap)
+	output.ReplaceAllIDsInRelationships(idMap)
+	output.ReplaceAllIDsInAffiliations(idMap)
+	output.ReplaceAllIDsInRecurringDonations(idMap)
+	output.ReplaceAllIDsInOpportunities(idMap)
+	output.ReplaceAllIDsInPayments(idMap)
+	output.ReplaceAllIDsInGAUAllocations(idMap)
+	output.ReplaceAllIDsInPartialSoftCredits(idMap)
+	output.ReplaceAllIDsInAccountSoftCredits(idMap)
+	output.ReplaceAllIDsInTasks(idMap)
+
+	for _, tr := range all {
+		if len(tr.records) == 0 {
+			continue
+		}
+		tReport, err := diffType(tr, existing[tr.sot])
+		if err != nil {
+			return nil, fmt.Errorf("diffing %s: %w", tr.sot, err)
+		}
+		report.Types = append(report.Types, tReport)
+	}
+	return report, nil
+}
+
+func diffType(tr *typedRecords, existing map[string]map[string]string) (*TypeReport, error) {
+	fieldKey, err := tr.sot.SalesforceObjectExternalFieldKey()
+	if err != nil {
+		return nil, fmt.Errorf("getting salesforce object external field key: %w", err)
+	}
+	result := &TypeReport{ObjectType: tr.sot}
+	for _, r := range tr.records {
+		fields, err := client.StructToFieldsMap(r)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("converting struct to map: %v", err))
+			continue
+		}
+		key, _ := fields[fieldKey].(string)
+		if key == "" {
+			result.Errors = append(result.Errors, fmt.Sprintf("record has no %s", fieldKey))
+			continue
+		}
+		old, ok := existing[key]
+		if !ok {
+			result.Inserts = append(result.Inserts, key)
+			continue
+		}
+		rd := &RecordDiff{Key: key, ID: old["id"]}
+		for k, v := range fields {
+			lk := strings.ToLower(k)
+			if ignoredFields[lk] {
+				continue
+			}
+			newValue, ok := v.(string)
+			if !ok {
+				newValue = fmt.Sprint(v)
+			}
+			if !sameValue(old[lk], newValue) {
+				rd.Changes = append(rd.Changes, &FieldChange{Field: k, Old: old[lk], New: newValue})
+			}
+		}
+		if len(rd.Changes) == 0 {
+			result.Unchanged++
+			continue
+		}
+		sort.Slice(rd.Changes, func(i, j int) bool { return rd.Changes[i].Field < rd.Changes[j].Field })
+		result.Updates = append(result.Updates, rd)
+	}
+	sort.Strings(result.Inserts)
+	sort.Slice(result.Updates, func(i, j int) bool { return result.Updates[i].Key < result.Updates[j].Key })
+	return result, nil
+}
+
+// sameValue compares a value from Salesforce with the one we'd send, allowing
+// for the different ways that numbers and dates are formatted.
+func sameValue(old, new string) bool {
+	if old == new {
+		return true
+	}
+	if of, err := strconv.ParseFloat(old, 64); err == nil {
+		if nf, err := strconv.ParseFloat(new, 64); err == nil {
+			return of == nf
+		}
+	}
+	if ot, ok := parseTime(old); ok {
+		if nt, ok := parseTime(new); ok {
+			// Date fields come back without a time, so only compare the date.
+			if len(old) == len("2006-01-02") || len(new) == len("2006-01-02") {
+				return ot.UTC().Format("2006-01-02") == nt.UTC().Format("2006-01-02")
+			}
+			return ot.Equal(nt)
+		}
+	}
+	return false
+}
+
+func parseTime(s string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.000Z0700", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Summary is a human readable version of the report.
+func (r *Report) Summary() string {
+	sb := strings.Builder{}
+	kind := "full"
+	if r.Partial {
+		kind = "partial"
+	}
+	fmt.Fprintf(&sb, "Dry run of a %s upload, compared against Salesforce at %s\n\n", kind, r.GeneratedAt.Format(time.RFC1123))
+	fmt.Fprintf(&sb, "%-24s %10s %10s %10s %10s\n", "Object Type", "Insert", "Update", "Unchanged", "Errors")
+	for _, t := range r.Types {
+		fmt.Fprintf(&sb, "%-24s %10d %10d %10d %10d\n", t.ObjectType, len(t.Inserts), len(t.Updates), t.Unchanged, len(t.Errors))
+	}
+	for _, t := range r.Types {
+		if len(t.Updates) == 0 {
+			continue
+		}
+		counts := map[string]int{}
+		for _, u := range t.Updates {
+			for _, c := range u.Changes {
+				counts[c.Field]++
+			}
+		}
+		fields := make([]string, 0, len(counts))
+		for f := range counts {
+			fields = append(fields, f)
+		}
+		sort.Slice(fields, func(i, j int) bool {
+			if counts[fields[i]] != counts[fields[j]] {
+				return counts[fields[i]] > counts[fields[j]]
+			}
+			return fields[i] < fields[j]
+		})
+		fmt.Fprintf(&sb, "\n%s - fields that would change:\n", t.ObjectType)
+		for _, f := range fields {
+			fmt.Fprintf(&sb, "  %-50s %d records\n", f, counts[f])
+		}
+	}
+	return sb.String()
+}
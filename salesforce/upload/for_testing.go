@@ -2,6 +2,7 @@ package upload
 
 import (
 	"strconv"
+	"sync/atomic"
 
 	"github.com/Silicon-Ally/etap2sf/salesforce/generated/sfenterprise"
 )
@@ -13,6 +14,7 @@ func GetUploaderForLocalValidation() (*Uploader, error) {
 	u.Failed = map[string]bool{}
 	u.Succeeded = map[string]bool{}
 	u.IDMap = map[string]string{}
+	u.Hashes = map[string]string{}
 	u.Wetrun = false
 	u.DoShuffles = false
 	u.client = &fakeClient{}
@@ -20,12 +22,11 @@ func GetUploaderForLocalValidation() (*Uploader, error) {
 }
 
 type fakeClient struct {
-	id int
+	id atomic.Int64
 }
 
 func (u *fakeClient) nextID(prefix string) (string, error) {
-	u.id++
-	return prefix + "-" + strconv.Itoa(u.id), nil
+	return prefix + "-" + strconv.FormatInt(u.id.Add(1), 10), nil
 }
 func (u *fakeClient) UpsertCampaign(*sfenterprise.Campaign) (string, error) {
 	return u.nextID("campaign")
@@ -75,6 +76,12 @@ func (u *fakeClient) UpsertContentVersion(*sfenterprise.ContentVersion) (string,
 func (u *fakeClient) UpsertContentDocumentLink(*sfenterprise.ContentDocumentLink) (string, error) {
 	return u.nextID("contentdocumentlink")
 }
+func (u *fakeClient) UpsertHousehold(*sfenterprise.Npo02__Household__c) (string, error) {
+	return u.nextID("household")
+}
+func (u *fakeClient) CreateContentNote(*sfenterprise.ContentNote) (string, error) {
+	return u.nextID("contentnote")
+}
 func (u *fakeClient) LookupContentDocumentByVersion(id sfenterprise.ID) (sfenterprise.ID, error) {
 	return id + "-contentdocument", nil
 }
@@ -0,0 +1,298 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Silicon-Ally/etap2sf/conv/conversion"
+	"github.com/Silicon-Ally/etap2sf/logging"
+	"github.com/Silicon-Ally/etap2sf/salesforce"
+	restclient "github.com/Silicon-Ally/etap2sf/salesforce/clients/rest"
+	"github.com/Silicon-Ally/etap2sf/salesforce/generated/sfenterprise"
+	"github.com/Silicon-Ally/etap2sf/utils"
+)
+
+// graphClient is implemented by clients which can save a record along with
+// the records that refer to it in one call, without needing its ID first.
+type graphClient interface {
+	MaxGraphsPerCall() int
+	UpsertGraphs(ctx context.Context, graphs []*restclient.Graph) ([]*restclient.GraphResult, error)
+}
+
+// withGraphs makes the opportunities phase upload each opportunity along with
+// its payments and allocations when the rest-graph backend is configured. The
+// payments and allocations phases then find those records already uploaded
+// with the same fields, and skip them (so they count as unchanged there).
+func withGraphs(p *phase) *phase {
+	upsertAll := p.run
+	p.run = func(ctx context.Context, u *Uploader, o *conversion.Output) error {
+		if u.graphClient == nil {
+			return upsertAll(ctx, u, o)
+		}
+		u.stateChangeMutex.Lock()
+		errs := o.ReplaceAllIDsInOpportunities(u.IDMap)
+		u.stateChangeMutex.Unlock()
+		if err := handleErrors(errs); err != nil {
+			return fmt.Errorf("replacing ids: %w", err)
+		}
+		return uploadOpportunityGraphs(ctx, u, o)
+	}
+	return p
+}
+
+// opportunityGraph is an opportunity with its payments and allocations, and
+// how to record the result of each.
+type opportunityGraph struct {
+	graph *restclient.Graph
+	nodes []*graphNode
+}
+
+type graphNode struct {
+	rs  *runState
+	key string
+	// hash is the hash of the record as its own phase would see it, once the
+	// placeholder for its opportunity has been replaced with the given ID.
+	hash func(oppID string) string
+}
+
+func uploadOpportunityGraphs(ctx context.Context, u *Uploader, o *conversion.Output) error {
+	defer func() {
+		if err := save(u); err != nil {
+			logging.For("upload").Error("failed to save output", "error", err)
+		}
+	}()
+	if len(o.Opportunities) == 0 {
+		return nil
+	}
+	if u.DoShuffles {
+		// As in run, shuffling avoids lock contention between calls.
+		utils.Shuffle(o.Opportunities)
+	}
+	oppKey := func(opp *sfenterprise.Opportunity) string { return *opp.Etap_MultiObject_EtapRef__c }
+	name := fmt.Sprintf("%T", o.Opportunities[0])
+	errors := []error{}
+	u.stateChangeMutex.Lock()
+	changed, hashes, _, counts := classify(u, o.Opportunities, oppKey, u.hard())
+	u.stateChangeMutex.Unlock()
+	u.addChangeCounts(salesforce.ObjectType_Opportunity, name, counts)
+	opps := []*sfenterprise.Opportunity{}
+	seen := map[string]bool{}
+	for _, opp := range changed {
+		key := oppKey(opp)
+		if seen[key] {
+			errors = append(errors, fmt.Errorf("duplicate ref %s in %s", key, name))
+			continue
+		}
+		seen[key] = true
+		opps = append(opps, opp)
+	}
+
+	gc := u.graphClient
+	rs := u.newRunState(name, salesforce.ObjectType_Opportunity, len(opps), gc.MaxGraphsPerCall(), 1)
+	rs.hashes = hashes
+	graphs, childRSs := u.opportunityGraphs(o, opps, rs)
+
+	batches := utils.SplitIntoBatches(graphs, gc.MaxGraphsPerCall())
+	split := splitByNumThreads(batches, u.NumThreads)
+	errorsChan := make(chan []error)
+	for _, bs := range split {
+		go func(bs [][]*opportunityGraph) {
+			errorsChan <- runGraphThread(ctx, u, rs, gc, bs)
+		}(bs)
+	}
+	for range split {
+		errors = append(errors, <-errorsChan...)
+	}
+	for _, r := range append(childRSs, rs) {
+		r.progress.Done()
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("interrupted with %d errors: %w", len(errors), context.Cause(ctx))
+	}
+	for _, r := range append(childRSs, rs) {
+		if err := r.persistError(); err != nil {
+			return err
+		}
+	}
+	logging.For("upload").Info("done with object type", "object_type", rs.name, "retained", len(opps), "graphs", len(graphs), "errors", len(errors))
+	return handleErrors(errors)
+}
+
+// opportunityGraphs puts each opportunity in a graph with its selected
+// payments and allocations. Allocations that refer to anything other than
+// their opportunity and an uploaded GAU are left to their own phase. It also
+// returns the run states that the payments and allocations are tracked with.
+func (u *Uploader) opportunityGraphs(o *conversion.Output, opps []*sfenterprise.Opportunity, oppRS *runState) ([]*opportunityGraph, []*runState) {
+	graphs := make([]*opportunityGraph, len(opps))
+	byOpp := map[string]*opportunityGraph{}
+	for i, opp := range opps {
+		key := *opp.Etap_MultiObject_EtapRef__c
+		hash := oppRS.hashes[key]
+		graphs[i] = &opportunityGraph{
+			graph: &restclient.Graph{Nodes: []*restclient.Node{{ObjectType: salesforce.ObjectType_Opportunity, Record: opp}}},
+			nodes: []*graphNode{{rs: oppRS, key: key, hash: func(string) string { return hash }}},
+		}
+		byOpp[restclient.PlaceholderPrefix+key] = graphs[i]
+	}
+	add := func(g *opportunityGraph, rs *runState, sot salesforce.ObjectType, key string, record any, hash func(oppID string) string) {
+		g.graph.Nodes = append(g.graph.Nodes, &restclient.Node{ObjectType: sot, Record: record})
+		g.nodes = append(g.nodes, &graphNode{rs: rs, key: key, hash: hash})
+	}
+
+	var paymentRS, allocationRS *runState
+	runStates := []*runState{}
+	payments, allocations := 0, 0
+	if u.selection.includesType(salesforce.ObjectType_Payment) {
+		for _, p := range o.Payments {
+			if p.Npe01__Opportunity__c != nil && byOpp[string(*p.Npe01__Opportunity__c)] != nil {
+				payments++
+			}
+		}
+	}
+	u.stateChangeMutex.Lock()
+	gauIDs := map[*sfenterprise.Npsp__Allocation__c]sfenterprise.ID{}
+	if u.selection.includesType(salesforce.ObjectType_GAUAllocation) {
+		for _, a := range o.GAUAllocations {
+			if a.Npsp__Opportunity__c == nil || byOpp[string(*a.Npsp__Opportunity__c)] == nil || a.Npsp__Campaign__c != nil || a.Npsp__Recurring_Donation__c != nil || a.Npsp__General_Accounting_Unit__c == nil {
+				continue
+			}
+			gau := string(*a.Npsp__General_Accounting_Unit__c)
+			if !strings.HasPrefix(gau, restclient.PlaceholderPrefix) {
+				continue
+			}
+			if id := u.IDMap[strings.TrimPrefix(gau, restclient.PlaceholderPrefix)]; id != "" {
+				gauIDs[a] = sfenterprise.ID(id)
+				allocations++
+			}
+		}
+	}
+	u.stateChangeMutex.Unlock()
+	// Their calls are made (and counted) with the opportunities'.
+	if payments > 0 {
+		paymentRS = u.newRunState(fmt.Sprintf("%T", o.Payments[0]), salesforce.ObjectType_Payment, payments, 1, 0)
+		runStates = append(runStates, paymentRS)
+	}
+	if allocations > 0 {
+		allocationRS = u.newRunState(fmt.Sprintf("%T", o.GAUAllocations[0]), salesforce.ObjectType_GAUAllocation, allocations, 1, 0)
+		runStates = append(runStates, allocationRS)
+	}
+
+	if paymentRS != nil {
+		for _, p := range o.Payments {
+			if p.Npe01__Opportunity__c == nil {
+				continue
+			}
+			g := byOpp[string(*p.Npe01__Opportunity__c)]
+			if g == nil {
+				continue
+			}
+			add(g, paymentRS, salesforce.ObjectType_Payment, *p.Etap_Payment_Ref__c, p, func(oppID string) string {
+				resolved := *p
+				resolved.Npe01__Opportunity__c = idPtr(oppID)
+				return hashOrEmpty(&resolved, *p.Etap_Payment_Ref__c)
+			})
+		}
+	}
+	if allocationRS != nil {
+		for _, a := range o.GAUAllocations {
+			gauID, ok := gauIDs[a]
+			if !ok {
+				continue
+			}
+			// The allocation itself is resolved by its own phase, and its
+			// placeholders can only be replaced once.
+			sent := *a
+			sent.Npsp__General_Accounting_Unit__c = &gauID
+			add(byOpp[string(*a.Npsp__Opportunity__c)], allocationRS, salesforce.ObjectType_GAUAllocation, *a.Etap_MultiObject_EtapRef__c, &sent, func(oppID string) string {
+				resolved := sent
+				resolved.Npsp__Opportunity__c = idPtr(oppID)
+				return hashOrEmpty(&resolved, *a.Etap_MultiObject_EtapRef__c)
+			})
+		}
+	}
+	return graphs, runStates
+}
+
+func runGraphThread(ctx context.Context, u *Uploader, rs *runState, gc graphClient, batches [][]*opportunityGraph) []error {
+	maxErrorsPerThread := u.MaxErrors / u.NumThreads
+	if maxErrorsPerThread < 1 {
+		return []error{fmt.Errorf("max errors per thread must be at least 1")}
+	}
+	errors := []error{}
+	for _, batch := range batches {
+		if len(errors) >= maxErrorsPerThread {
+			rs.anyThreadDead.Store(true)
+			return errors
+		}
+		if rs.anyThreadDead.Load() || ctx.Err() != nil {
+			return errors
+		}
+		graphs := make([]*restclient.Graph, len(batch))
+		for i, g := range batch {
+			graphs[i] = g.graph
+		}
+		if !u.apiGate.wait(ctx) || !rs.limiter.acquire(ctx) {
+			return errors
+		}
+		results, err := withRetries(ctx, rs, rs.maxRetries, func() ([]*restclient.GraphResult, error) {
+			return gc.UpsertGraphs(ctx, graphs)
+		})
+		rs.limiter.release()
+		if err != nil {
+			err = fmt.Errorf("saving %d opportunity graphs: %w", len(batch), err)
+			for _, g := range batch {
+				for _, n := range g.nodes {
+					u.failed(n.rs, n.key, err)
+				}
+			}
+			stopOnChildPersistErrors(rs, batch)
+			errors = append(errors, err)
+			continue
+		}
+		if len(results) != len(batch) {
+			return append(errors, fmt.Errorf("expected %d results for opportunity graphs, got %d", len(batch), len(results)))
+		}
+		for i, g := range batch {
+			r := results[i]
+			for j, n := range g.nodes {
+				if r.Errors[j] != nil {
+					errors = append(errors, fmt.Errorf("%s: %w", n.key, r.Errors[j]))
+					u.failed(n.rs, n.key, r.Errors[j])
+					continue
+				}
+				u.succeededWithHash(n.rs, n.key, r.IDs[j], n.hash(r.IDs[0]))
+			}
+		}
+		stopOnChildPersistErrors(rs, batch)
+	}
+	return errors
+}
+
+// stopOnChildPersistErrors stops the opportunities' run if a payment or
+// allocation in the batch couldn't be persisted, since their runs have no
+// threads of their own.
+func stopOnChildPersistErrors(rs *runState, batch []*opportunityGraph) {
+	for _, g := range batch {
+		for _, n := range g.nodes {
+			if err := n.rs.persistError(); err != nil && n.rs != rs {
+				rs.stopOnPersistError(err)
+				return
+			}
+		}
+	}
+}
+
+func hashOrEmpty(t any, key string) string {
+	hash, err := recordHash(t)
+	if err != nil {
+		// The record is still uploaded, and will just be sent again by its phase.
+		logging.For("upload").Debug("couldn't hash record", "ref", key, "error", err)
+	}
+	return hash
+}
+
+func idPtr(id string) *sfenterprise.ID {
+	sid := sfenterprise.ID(id)
+	return &sid
+}
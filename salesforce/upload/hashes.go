@@ -0,0 +1,129 @@
+package upload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Silicon-Ally/etap2sf/conv/conversion"
+	"github.com/Silicon-Ally/etap2sf/logging"
+	"github.com/Silicon-Ally/etap2sf/salesforce"
+	enterprise "github.com/Silicon-Ally/etap2sf/salesforce/clients/enterprise"
+)
+
+// recordHash is a hash of the fields that would be sent to Salesforce for the
+// record, so that a record that converts to the same fields as it did last
+// time doesn't need to be sent again. It's empty for records that can't be
+// compared this way: notes are created rather than upserted, so re-sending a
+// changed one would make a second copy.
+func recordHash(t any) (string, error) {
+	if _, ok := t.(*conversion.Note); ok {
+		return "", nil
+	}
+	fields, err := enterprise.StructToFieldsMap(t)
+	if err != nil {
+		return "", fmt.Errorf("getting fields of %T: %w", t, err)
+	}
+	// Maps are marshalled with their keys sorted, so the hash is stable.
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return "", fmt.Errorf("marshalling fields of %T: %w", t, err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// changeCounts is how the records of an object type compare with what was
+// uploaded before.
+type changeCounts struct {
+	// New records have never been uploaded (or failed the last time).
+	New int
+	// Changed records were uploaded, but their fields have changed since, or
+	// were uploaded before hashes were kept.
+	Changed int
+	// Unchanged records were uploaded with the same fields, and are skipped.
+	Unchanged int
+}
+
+// classify works out which of the records need to be sent, which of those
+// were uploaded before, and the hashes to store once they've been uploaded.
+// It's called with the state change mutex held.
+func classify[T any](u *Uploader, ts []T, idFn func(t T) string, hard bool) (retained []T, hashes map[string]string, resend map[string]bool, counts *changeCounts) {
+	hashes = map[string]string{}
+	resend = map[string]bool{}
+	counts = &changeCounts{}
+	for _, t := range ts {
+		id := idFn(t)
+		hash, err := recordHash(t)
+		if err != nil {
+			// The upload itself will fail with a better error.
+			logging.For("upload").Debug("couldn't hash record", "ref", id, "error", err)
+		}
+		if hash != "" {
+			hashes[id] = hash
+		}
+		switch {
+		case !u.Succeeded[id]:
+			counts.New++
+		case hash != "" && u.Hashes[id] != hash:
+			counts.Changed++
+		default:
+			counts.Unchanged++
+			if !hard {
+				continue
+			}
+		}
+		if u.Succeeded[id] {
+			resend[id] = true
+		}
+		retained = append(retained, t)
+	}
+	return retained, hashes, resend, counts
+}
+
+// addChangeCounts adds the counts from a run over the records of the type to
+// the totals for the upload.
+func (u *Uploader) addChangeCounts(sot salesforce.ObjectType, name string, c *changeCounts) {
+	logging.For("upload").Info("compared records with the last upload", "object_type", name, "new", c.New, "changed", c.Changed, "unchanged", c.Unchanged)
+	if sot == "" {
+		return
+	}
+	u.stateChangeMutex.Lock()
+	defer u.stateChangeMutex.Unlock()
+	if u.changes == nil {
+		u.changes = map[salesforce.ObjectType]*changeCounts{}
+	}
+	total, ok := u.changes[sot]
+	if !ok {
+		total = &changeCounts{}
+		u.changes[sot] = total
+	}
+	total.New += c.New
+	total.Changed += c.Changed
+	total.Unchanged += c.Unchanged
+}
+
+// changeSummary is a table of the new, changed and unchanged records of each
+// object type in the upload.
+func (u *Uploader) changeSummary() string {
+	u.stateChangeMutex.Lock()
+	defer u.stateChangeMutex.Unlock()
+	if len(u.changes) == 0 {
+		return ""
+	}
+	sots := make([]salesforce.ObjectType, 0, len(u.changes))
+	for sot := range u.changes {
+		sots = append(sots, sot)
+	}
+	sort.Slice(sots, func(i, j int) bool { return sots[i] < sots[j] })
+	sb := strings.Builder{}
+	fmt.Fprintf(&sb, "%-24s %10s %10s %10s\n", "Object Type", "New", "Changed", "Unchanged")
+	for _, sot := range sots {
+		c := u.changes[sot]
+		fmt.Fprintf(&sb, "%-24s %10d %10d %10d\n", sot, c.New, c.Changed, c.Unchanged)
+	}
+	return sb.String()
+}
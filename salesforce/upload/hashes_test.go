@@ -0,0 +1,124 @@
+package upload
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/Silicon-Ally/etap2sf/conv/conversion"
+	"github.com/Silicon-Ally/etap2sf/salesforce/generated/sfenterprise"
+)
+
+func paymentKey(p *sfenterprise.Npe01__OppPayment__c) string { return *p.Etap_Payment_Ref__c }
+
+func TestClassify(t *testing.T) {
+	payment := func(key, oppID string) *sfenterprise.Npe01__OppPayment__c {
+		return &sfenterprise.Npe01__OppPayment__c{Etap_Payment_Ref__c: ptr(key), Npe01__Opportunity__c: idPtr(oppID)}
+	}
+	hashOf := func(p *sfenterprise.Npe01__OppPayment__c) string {
+		hash, err := recordHash(p)
+		if err != nil {
+			t.Fatalf("hashing %s: %v", paymentKey(p), err)
+		}
+		return hash
+	}
+	// 1.0.4 was uploaded before hashes were kept.
+	newPayment := payment("1.0.1", "006000000000001")
+	failed := payment("1.0.2", "006000000000002")
+	changed := payment("1.0.3", "006000000000003")
+	unhashed := payment("1.0.4", "006000000000004")
+	unchanged := payment("1.0.5", "006000000000005")
+	payments := []*sfenterprise.Npe01__OppPayment__c{newPayment, failed, changed, unhashed, unchanged}
+
+	tests := []struct {
+		name       string
+		hard       bool
+		wantKeys   []string
+		wantResend []string
+	}{
+		{
+			name:       "unchanged records are skipped",
+			wantKeys:   []string{"1.0.1", "1.0.2", "1.0.3", "1.0.4"},
+			wantResend: []string{"1.0.3", "1.0.4"},
+		},
+		{
+			name:       "hard sends unchanged records too",
+			hard:       true,
+			wantKeys:   []string{"1.0.1", "1.0.2", "1.0.3", "1.0.4", "1.0.5"},
+			wantResend: []string{"1.0.3", "1.0.4", "1.0.5"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			u, err := GetUploaderForLocalValidation()
+			if err != nil {
+				t.Fatalf("creating uploader: %v", err)
+			}
+			u.Failed["1.0.2"] = true
+			for _, p := range []*sfenterprise.Npe01__OppPayment__c{changed, unhashed, unchanged} {
+				u.Succeeded[paymentKey(p)] = true
+			}
+			u.Hashes["1.0.3"] = hashOf(payment("1.0.3", "006000000000099"))
+			u.Hashes["1.0.5"] = hashOf(unchanged)
+
+			retained, hashes, resend, counts := classify(u, payments, paymentKey, test.hard)
+
+			keys := []string{}
+			for _, p := range retained {
+				keys = append(keys, paymentKey(p))
+			}
+			if !equalKeys(keys, test.wantKeys) {
+				t.Errorf("retained %v, want %v", keys, test.wantKeys)
+			}
+			resent := []string{}
+			for key := range resend {
+				resent = append(resent, key)
+			}
+			if !equalKeys(resent, test.wantResend) {
+				t.Errorf("resend %v, want %v", resent, test.wantResend)
+			}
+			if want := (changeCounts{New: 2, Changed: 2, Unchanged: 1}); *counts != want {
+				t.Errorf("counts = %+v, want %+v", *counts, want)
+			}
+			for _, p := range payments {
+				if hashes[paymentKey(p)] != hashOf(p) {
+					t.Errorf("hash of %s = %q, want %q", paymentKey(p), hashes[paymentKey(p)], hashOf(p))
+				}
+			}
+		})
+	}
+}
+
+func TestClassify_NotesAreNeverHashed(t *testing.T) {
+	u, err := GetUploaderForLocalValidation()
+	if err != nil {
+		t.Fatalf("creating uploader: %v", err)
+	}
+	notes := []*conversion.Note{{Ref: "1.0.1"}, {Ref: "1.0.2"}}
+	// The first was uploaded, and has since changed, which can't be told.
+	u.Succeeded["1.0.1"] = true
+	noteKey := func(n *conversion.Note) string { return n.Ref }
+
+	retained, hashes, _, counts := classify(u, notes, noteKey, false)
+	if len(retained) != 1 || retained[0].Ref != "1.0.2" {
+		t.Errorf("retained %v, want only the note that wasn't uploaded", retained)
+	}
+	if len(hashes) != 0 {
+		t.Errorf("hashes = %v, want none", hashes)
+	}
+	if want := (changeCounts{New: 1, Unchanged: 1}); *counts != want {
+		t.Errorf("counts = %+v, want %+v", *counts, want)
+	}
+}
+
+func equalKeys(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	sort.Strings(got)
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package upload
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Silicon-Ally/etap2sf/logging"
+	enterprise "github.com/Silicon-Ally/etap2sf/salesforce/clients/enterprise"
+)
+
+// InterruptibleContext returns a context that is cancelled on Ctrl-C (or
+// SIGTERM). Cancelling it lets the upload finish the records it's in the middle
+// of, save its state and restore the NPSP triggers before returning. A second
+// Ctrl-C exits immediately. Calling the returned function stops listening for
+// signals, without treating it as an interruption.
+func InterruptibleContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		// Either way, go back to the default handling, so that the next
+		// signal kills us.
+		defer signal.Stop(signals)
+		select {
+		case <-signals:
+			logging.For("upload").Warn("interrupted, finishing in-flight records and restoring NPSP triggers - interrupt again to exit immediately")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// offerToRestoreNPSPTriggers checks for triggers that a previous run disabled
+// and never re-enabled (i.e. because it was killed), and asks whether to
+// re-enable them now. If they aren't, this run adds to the same journal, and
+// re-enables them all when it's done.
+func offerToRestoreNPSPTriggers(c *enterprise.Client) error {
+	j, err := enterprise.PendingNPSPTriggerJournal()
+	if err != nil {
+		return err
+	}
+	if j == nil {
+		return nil
+	}
+	fmt.Printf("A previous upload disabled %d NPSP trigger handlers at %s and never re-enabled them.\n", len(j.TriggerIDs), j.DisabledAt.Format(time.RFC1123))
+	fmt.Printf("Re-enable them now? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+		fmt.Printf("Leaving them disabled - they'll be re-enabled when this upload finishes.\n")
+		return nil
+	}
+	if err := c.RestoreNPSPTriggers(j); err != nil {
+		return fmt.Errorf("restoring npsp triggers: %w", err)
+	}
+	fmt.Printf("Re-enabled %d NPSP trigger handlers.\n", len(j.TriggerIDs))
+	return nil
+}
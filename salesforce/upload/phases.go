@@ -0,0 +1,376 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/Silicon-Ally/etap2sf/config"
+	"github.com/Silicon-Ally/etap2sf/conv/conversion"
+	"github.com/Silicon-Ally/etap2sf/logging"
+	"github.com/Silicon-Ally/etap2sf/salesforce"
+	"github.com/Silicon-Ally/etap2sf/salesforce/generated/sfenterprise"
+	"go.uber.org/multierr"
+)
+
+const defaultMaxParallelPhases = 3
+
+// phase uploads the records of one object type. A phase only starts once the
+// phases it depends on (the ones whose IDs its placeholders refer to) have
+// finished without leaving any of their records failed.
+type phase struct {
+	name string
+	// objectType is empty for phases that don't upload records.
+	objectType salesforce.ObjectType
+	dependsOn  []string
+	// keys are the external keys of the records in the phase, which is how
+	// their success or failure is tracked.
+	keys func(o *conversion.Output) []string
+	// filter drops the records whose keys aren't selected from the output.
+	filter func(o *conversion.Output, keep func(key string) bool)
+	run    func(ctx context.Context, u *Uploader, o *conversion.Output) error
+}
+
+// newPhase declares a phase which upserts records of a single type.
+//   - records is the field of the output that holds the records.
+//   - resolve replaces the placeholders in the records with the IDs of the
+//     records they refer to, which must be uploaded by the dependencies. It's
+//     nil if the records have no placeholders.
+//   - key is the external key that the records are upserted on.
+func newPhase[T any](
+	name string,
+	sot salesforce.ObjectType,
+	dependsOn []string,
+	records func(o *conversion.Output) *[]T,
+	resolve func(o *conversion.Output, idMap map[string]string) []error,
+	key func(t T) string,
+	upsertFn func(c client, t T) (string, error),
+) *phase {
+	return declarePhase(name, sot, dependsOn, records, resolve, key, func(ctx context.Context, u *Uploader, ts []T) error {
+		return upsert(ctx, u, sot, ts, key, func(t T) (string, error) { return upsertFn(u.client, t) })
+	})
+}
+
+// newNotePhase declares a phase over the output's notes. Notes are kept with
+// their refs and links, rather than as the records themselves, so they're
+// always uploaded one at a time, whatever backend is configured for the type.
+// Notes and their links are created rather than upserted, so they're never
+// sent again once they've succeeded, even with --hard, which would duplicate
+// them.
+func newNotePhase(
+	name string,
+	sot salesforce.ObjectType,
+	dependsOn []string,
+	resolve func(o *conversion.Output, idMap map[string]string) []error,
+	key func(n *conversion.Note) string,
+	fn func(c client, n *conversion.Note) (string, error),
+) *phase {
+	records := func(o *conversion.Output) *[]*conversion.Note { return &o.ContentNotes }
+	return declarePhase(name, sot, dependsOn, records, resolve, key, func(ctx context.Context, u *Uploader, ns []*conversion.Note) error {
+		return run(ctx, u, sot, ns, key, func(n *conversion.Note) (string, error) { return fn(u.client, n) }, false)
+	})
+}
+
+func declarePhase[T any](
+	name string,
+	sot salesforce.ObjectType,
+	dependsOn []string,
+	records func(o *conversion.Output) *[]T,
+	resolve func(o *conversion.Output, idMap map[string]string) []error,
+	key func(t T) string,
+	upload func(ctx context.Context, u *Uploader, ts []T) error,
+) *phase {
+	return &phase{
+		name:       name,
+		objectType: sot,
+		dependsOn:  dependsOn,
+		keys: func(o *conversion.Output) []string {
+			ts := *records(o)
+			keys := make([]string, len(ts))
+			for i, t := range ts {
+				keys[i] = key(t)
+			}
+			return keys
+		},
+		filter: func(o *conversion.Output, keep func(key string) bool) {
+			ts := records(o)
+			kept := []T{}
+			for _, t := range *ts {
+				if keep(key(t)) {
+					kept = append(kept, t)
+				}
+			}
+			*ts = kept
+		},
+		run: func(ctx context.Context, u *Uploader, o *conversion.Output) error {
+			if resolve != nil {
+				u.stateChangeMutex.Lock()
+				errs := resolve(o, u.IDMap)
+				u.stateChangeMutex.Unlock()
+				if err := handleErrors(errs); err != nil {
+					return fmt.Errorf("replacing ids: %w", err)
+				}
+			}
+			return upload(ctx, u, *records(o))
+		},
+	}
+}
+
+// phases are the object types in the upload, and what each depends on. They're
+// declared in an order that would work if they were uploaded one at a time,
+// which is also the order that they're started in.
+var phases = []*phase{
+	newPhase("campaigns", salesforce.ObjectType_Campaign, nil,
+		func(o *conversion.Output) *[]*sfenterprise.Campaign { return &o.Campaigns },
+		nil,
+		func(c *sfenterprise.Campaign) string { return *c.Etap_MultiObject_EtapRef__c },
+		client.UpsertCampaign),
+	newPhase("gaus", salesforce.ObjectType_GeneralAccountingUnit, nil,
+		func(o *conversion.Output) *[]*sfenterprise.Npsp__General_Accounting_Unit__c {
+			return &o.GeneralAccountingUnits
+		},
+		nil,
+		func(c *sfenterprise.Npsp__General_Accounting_Unit__c) string { return *c.Etap_Fund_Ref__c },
+		client.UpsertGeneralAccountingUnit),
+	newPhase("accounts", salesforce.ObjectType_Account, nil,
+		func(o *conversion.Output) *[]*sfenterprise.Account { return &o.Accounts },
+		nil,
+		func(a *sfenterprise.Account) string { return *a.Etap_MultiObject_EtapRef__c },
+		client.UpsertAccount),
+	newPhase("households", salesforce.ObjectType_Household, nil,
+		func(o *conversion.Output) *[]*sfenterprise.Npo02__Household__c { return &o.Households },
+		nil,
+		func(h *sfenterprise.Npo02__Household__c) string { return *h.Etap_MultiObject_EtapRef__c },
+		client.UpsertHousehold),
+	newPhase("contacts", salesforce.ObjectType_Contact, []string{"accounts", "households"},
+		func(o *conversion.Output) *[]*sfenterprise.Contact { return &o.Contacts },
+		(*conversion.Output).ReplaceAllIDsInContacts,
+		func(a *sfenterprise.Contact) string { return *a.Etap_Account_Ref__c },
+		client.UpsertContact),
+	newPhase("relationships", salesforce.ObjectType_Relationship, []string{"contacts"},
+		func(o *conversion.Output) *[]*sfenterprise.Npe4__Relationship__c { return &o.Relationships },
+		(*conversion.Output).ReplaceAllIDsInRelationships,
+		// No longer need differentiation here - we add a 1-of-2 2-of-2 suffix to differentiate
+		func(a *sfenterprise.Npe4__Relationship__c) string { return *a.Etap_Relationship_Ref__c },
+		client.UpsertRelationship),
+	newPhase("affiliations", salesforce.ObjectType_Affiliation, []string{"accounts", "contacts"},
+		func(o *conversion.Output) *[]*sfenterprise.Npe5__Affiliation__c { return &o.Affiliations },
+		(*conversion.Output).ReplaceAllIDsInAffiliations,
+		func(a *sfenterprise.Npe5__Affiliation__c) string { return *a.Etap_Relationship_Ref__c },
+		client.UpsertAffiliation),
+	newPhase("recurring donations", salesforce.ObjectType_RecurringDonation, []string{"campaigns", "accounts", "contacts"},
+		func(o *conversion.Output) *[]*sfenterprise.Npe03__Recurring_Donation__c { return &o.RecurringDonations },
+		(*conversion.Output).ReplaceAllIDsInRecurringDonations,
+		func(a *sfenterprise.Npe03__Recurring_Donation__c) string { return *a.Etap_RecurringGiftSchedule_Ref__c },
+		client.UpsertRecurringDonation),
+	// Opportunities also depend on GAUs, since their allocations are uploaded
+	// along with them by the rest-graph backend.
+	withGraphs(newPhase("opportunities", salesforce.ObjectType_Opportunity, []string{"campaigns", "gaus", "accounts", "contacts", "recurring donations"},
+		func(o *conversion.Output) *[]*sfenterprise.Opportunity { return &o.Opportunities },
+		(*conversion.Output).ReplaceAllIDsInOpportunities,
+		func(a *sfenterprise.Opportunity) string { return *a.Etap_MultiObject_EtapRef__c },
+		client.UpsertOpportunity)),
+	newPhase("payments", salesforce.ObjectType_Payment, []string{"opportunities"},
+		func(o *conversion.Output) *[]*sfenterprise.Npe01__OppPayment__c { return &o.Payments },
+		(*conversion.Output).ReplaceAllIDsInPayments,
+		func(a *sfenterprise.Npe01__OppPayment__c) string { return *a.Etap_Payment_Ref__c },
+		client.UpsertPayment),
+	newPhase("gau allocations", salesforce.ObjectType_GAUAllocation, []string{"campaigns", "gaus", "recurring donations", "opportunities"},
+		func(o *conversion.Output) *[]*sfenterprise.Npsp__Allocation__c { return &o.GAUAllocations },
+		(*conversion.Output).ReplaceAllIDsInGAUAllocations,
+		func(a *sfenterprise.Npsp__Allocation__c) string { return *a.Etap_MultiObject_EtapRef__c },
+		client.UpsertGAUAllocation),
+	newPhase("partial soft credits", salesforce.ObjectType_PartialSoftCredit, []string{"contacts", "opportunities"},
+		func(o *conversion.Output) *[]*sfenterprise.Npsp__Partial_Soft_Credit__c { return &o.PartialSoftCredits },
+		(*conversion.Output).ReplaceAllIDsInPartialSoftCredits,
+		func(t *sfenterprise.Npsp__Partial_Soft_Credit__c) string { return *t.Etap_SoftCredit_Ref__c },
+		client.UpsertPartialSoftCredit),
+	newPhase("account soft credits", salesforce.ObjectType_AccountSoftCredit, []string{"accounts", "opportunities"},
+		func(o *conversion.Output) *[]*sfenterprise.Npsp__Account_Soft_Credit__c { return &o.AccountSoftCredits },
+		(*conversion.Output).ReplaceAllIDsInAccountSoftCredits,
+		func(t *sfenterprise.Npsp__Account_Soft_Credit__c) string { return *t.Etap_SoftCredit_Ref__c },
+		client.UpsertAccountSoftCredit),
+	newPhase("additional contexts", salesforce.ObjectType_AdditionalContext, nil,
+		func(o *conversion.Output) *[]*sfenterprise.Etap_AdditionalContext__c { return &o.AdditionalContexts },
+		nil,
+		func(t *sfenterprise.Etap_AdditionalContext__c) string { return *t.Name },
+		client.UpsertAdditionalContext),
+	// A task's WhatId can refer to any of the records that a journal entry is
+	// converted into, so it depends on all of them.
+	newPhase("tasks", salesforce.ObjectType_Task, []string{"campaigns", "accounts", "contacts", "recurring donations", "opportunities", "additional contexts"},
+		func(o *conversion.Output) *[]*sfenterprise.Task { return &o.Tasks },
+		(*conversion.Output).ReplaceAllIDsInTasks,
+		func(t *sfenterprise.Task) string { return *t.Etap_MultiObject_EtapRef__c },
+		client.UpsertTask),
+	newPhase("content versions", salesforce.ObjectType_ContentVersion, nil,
+		func(o *conversion.Output) *[]*sfenterprise.ContentVersion { return &o.ContentVersions },
+		nil,
+		func(t *sfenterprise.ContentVersion) string { return *t.Etap_MultiObject_EtapRef__c },
+		client.UpsertContentVersion),
+	{
+		// Links are made from the content versions' IDs, but need the IDs of
+		// their content documents, which Salesforce creates with the versions.
+		name:      "content document ids",
+		dependsOn: []string{"content versions", "accounts", "contacts", "opportunities", "tasks"},
+		run: func(ctx context.Context, u *Uploader, o *conversion.Output) error {
+			u.stateChangeMutex.Lock()
+			errs := o.ReplaceAllIDsInContentDocumentLinks(u.IDMap)
+			u.stateChangeMutex.Unlock()
+			if err := handleErrors(errs); err != nil {
+				return fmt.Errorf("replacing content document link ids: %w", err)
+			}
+			return u.lookupContentDocumentIDsFromContentVersionIDs(ctx, o)
+		},
+	},
+	newPhase("content document links", salesforce.ObjectType_ContentDocumentLink, []string{"content document ids"},
+		func(o *conversion.Output) *[]*sfenterprise.ContentDocumentLink { return &o.ContentDocumentLinks },
+		nil,
+		func(t *sfenterprise.ContentDocumentLink) string {
+			return (string(*t.ContentDocumentId) + string(*t.LinkedEntityId))
+		},
+		client.UpsertContentDocumentLink),
+	newNotePhase("content notes", salesforce.ObjectType_ContentNote, nil,
+		nil,
+		func(n *conversion.Note) string { return n.Ref },
+		func(c client, n *conversion.Note) (string, error) { return c.CreateContentNote(n.ContentNote) }),
+	// A note's ID is also the ID of its content document, so it's linked to
+	// its record directly.
+	newNotePhase("content note links", salesforce.ObjectType_ContentDocumentLink, []string{"content notes", "accounts", "contacts"},
+		(*conversion.Output).ReplaceAllIDsInContentNotes,
+		func(n *conversion.Note) string { return n.Ref + "-CDL" },
+		func(c client, n *conversion.Note) (string, error) { return c.UpsertContentDocumentLink(n.Link) }),
+}
+
+// ObjectTypesInUploadOrder are the object types that the upload creates, in
+// an order where each comes after the types it refers to.
+func ObjectTypesInUploadOrder() []salesforce.ObjectType {
+	result := []salesforce.ObjectType{}
+	for _, p := range phases {
+		if p.objectType != "" {
+			result = append(result, p.objectType)
+		}
+	}
+	return result
+}
+
+// checkPhases makes sure that every dependency is declared before the phases
+// that depend on it, which rules out cycles.
+func checkPhases(phases []*phase) error {
+	declared := map[string]bool{}
+	for _, p := range phases {
+		if declared[p.name] {
+			return fmt.Errorf("phase %q is declared twice", p.name)
+		}
+		for _, d := range p.dependsOn {
+			if !declared[d] {
+				return fmt.Errorf("phase %q depends on %q, which isn't declared before it", p.name, d)
+			}
+		}
+		declared[p.name] = true
+	}
+	return nil
+}
+
+// runPhases runs each phase as soon as its dependencies are done, with up to
+// upload.max_parallel_phases running at once. A phase whose dependencies failed
+// isn't started, but phases that don't depend on the failure carry on.
+func (u *Uploader) runPhases(ctx context.Context, output *conversion.Output, phases []*phase) error {
+	if err := checkPhases(phases); err != nil {
+		return err
+	}
+	maxParallel := config.Get().Upload.MaxParallelPhases
+	if maxParallel <= 0 {
+		maxParallel = defaultMaxParallelPhases
+	}
+	logger := logging.For("upload")
+	selected := u.selection.selectedPhases(phases)
+	sem := make(chan struct{}, maxParallel)
+	done := map[string]chan struct{}{}
+	byName := map[string]*phase{}
+	for _, p := range phases {
+		done[p.name] = make(chan struct{})
+		byName[p.name] = p
+	}
+	var (
+		mu      sync.Mutex
+		results = map[string]error{}
+		wg      sync.WaitGroup
+	)
+	for _, p := range phases {
+		wg.Add(1)
+		go func(p *phase) {
+			defer wg.Done()
+			defer close(done[p.name])
+			setResult := func(err error) {
+				mu.Lock()
+				results[p.name] = err
+				mu.Unlock()
+			}
+			for _, d := range p.dependsOn {
+				<-done[d]
+			}
+			if !selected[p.name] {
+				logger.Debug("skipping phase that isn't selected", "phase", p.name)
+				return
+			}
+			for _, d := range p.dependsOn {
+				if !selected[d] {
+					// Its records were uploaded by an earlier run. If they
+					// weren't, resolving the IDs fails, and with it the whole
+					// phase.
+					continue
+				}
+				mu.Lock()
+				depErr := results[d]
+				mu.Unlock()
+				if depErr != nil {
+					setResult(fmt.Errorf("not started because %s failed", d))
+					return
+				}
+				if n := u.unresolvedFailures(byName[d], output); n > 0 {
+					setResult(fmt.Errorf("not started because %d %s have unresolved failures", n, d))
+					return
+				}
+			}
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if err := ctx.Err(); err != nil {
+				setResult(context.Cause(ctx))
+				return
+			}
+			logger.Info("starting phase", "phase", p.name)
+			if err := p.run(ctx, u, output); err != nil {
+				setResult(fmt.Errorf("uploading %s: %w", p.name, err))
+				return
+			}
+			setResult(nil)
+		}(p)
+	}
+	wg.Wait()
+	var err error
+	for _, p := range phases {
+		if results[p.name] != nil {
+			logger.Error("phase failed", "phase", p.name, "error", results[p.name])
+			err = multierr.Append(err, fmt.Errorf("%s: %w", p.name, results[p.name]))
+		}
+	}
+	return err
+}
+
+// unresolvedFailures is the number of records in the phase that failed, and
+// haven't since succeeded.
+func (u *Uploader) unresolvedFailures(p *phase, output *conversion.Output) int {
+	if p.keys == nil {
+		return 0
+	}
+	keys := p.keys(output)
+	u.stateChangeMutex.Lock()
+	defer u.stateChangeMutex.Unlock()
+	n := 0
+	for _, k := range keys {
+		if u.Failed[k] {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,201 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Silicon-Ally/etap2sf/conv/conversion"
+	"github.com/Silicon-Ally/etap2sf/salesforce"
+)
+
+// phaseRecorder records the order that fake phases start and finish in, and
+// how many run at once.
+type phaseRecorder struct {
+	mu         sync.Mutex
+	events     []string
+	running    int
+	maxRunning int
+	finished   map[string]bool
+}
+
+// fakePhase declares a phase that records itself, fails with err if it's
+// set, and leaves the records with failedKeys failed.
+func (r *phaseRecorder) fakePhase(name string, dependsOn []string, err error, failedKeys ...string) *phase {
+	return &phase{
+		name:       name,
+		objectType: salesforce.ObjectType(name),
+		dependsOn:  dependsOn,
+		keys:       func(*conversion.Output) []string { return failedKeys },
+		run: func(ctx context.Context, u *Uploader, o *conversion.Output) error {
+			r.mu.Lock()
+			r.events = append(r.events, "start "+name)
+			r.running++
+			if r.running > r.maxRunning {
+				r.maxRunning = r.running
+			}
+			r.mu.Unlock()
+			// Long enough for the phases that can run alongside it to start.
+			time.Sleep(20 * time.Millisecond)
+			u.stateChangeMutex.Lock()
+			for _, k := range failedKeys {
+				u.Failed[k] = true
+			}
+			u.stateChangeMutex.Unlock()
+			r.mu.Lock()
+			r.events = append(r.events, "finish "+name)
+			r.running--
+			r.finished[name] = true
+			r.mu.Unlock()
+			return err
+		},
+	}
+}
+
+func (r *phaseRecorder) index(event string) int {
+	for i, e := range r.events {
+		if e == event {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestRunPhases(t *testing.T) {
+	errFailed := errors.New("phase failed")
+	tests := []struct {
+		name   string
+		phases func(r *phaseRecorder) []*phase
+		// ran are the phases that should run, and wantErrs are what the error
+		// should contain (it's nil if there are none).
+		ran      []string
+		wantErrs []string
+		// parallel is how many phases should run at once, if it's set. There's
+		// no config in tests, so the limit is the default.
+		parallel int
+	}{
+		{
+			name: "dependencies run first",
+			phases: func(r *phaseRecorder) []*phase {
+				return []*phase{
+					r.fakePhase("a", nil, nil),
+					r.fakePhase("b", []string{"a"}, nil),
+					r.fakePhase("c", []string{"a", "b"}, nil),
+					r.fakePhase("d", nil, nil),
+				}
+			},
+			ran: []string{"a", "b", "c", "d"},
+		},
+		{
+			name: "phases after a failure are skipped",
+			phases: func(r *phaseRecorder) []*phase {
+				return []*phase{
+					r.fakePhase("a", nil, errFailed),
+					r.fakePhase("b", []string{"a"}, nil),
+					r.fakePhase("c", []string{"b"}, nil),
+					r.fakePhase("d", nil, nil),
+				}
+			},
+			ran:      []string{"a", "d"},
+			wantErrs: []string{"a: uploading a: phase failed", "b: not started because a failed", "c: not started because b failed"},
+		},
+		{
+			name: "phases after failed records are skipped",
+			phases: func(r *phaseRecorder) []*phase {
+				return []*phase{
+					r.fakePhase("a", nil, nil, "1.0.1", "1.0.2"),
+					r.fakePhase("b", []string{"a"}, nil),
+					r.fakePhase("c", nil, nil),
+				}
+			},
+			ran:      []string{"a", "c"},
+			wantErrs: []string{"b: not started because 2 a have unresolved failures"},
+		},
+		{
+			name: "parallel phases are limited",
+			phases: func(r *phaseRecorder) []*phase {
+				ps := []*phase{}
+				for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+					ps = append(ps, r.fakePhase(name, nil, nil))
+				}
+				return ps
+			},
+			ran:      []string{"a", "b", "c", "d", "e", "f"},
+			parallel: defaultMaxParallelPhases,
+		},
+		{
+			name: "undeclared dependency",
+			phases: func(r *phaseRecorder) []*phase {
+				return []*phase{
+					r.fakePhase("a", nil, nil),
+					r.fakePhase("b", []string{"missing"}, nil),
+				}
+			},
+			wantErrs: []string{`phase "b" depends on "missing", which isn't declared before it`},
+		},
+		{
+			name: "dependency declared after its dependent",
+			phases: func(r *phaseRecorder) []*phase {
+				return []*phase{
+					r.fakePhase("b", []string{"a"}, nil),
+					r.fakePhase("a", nil, nil),
+				}
+			},
+			wantErrs: []string{`phase "b" depends on "a", which isn't declared before it`},
+		},
+		{
+			name: "phase declared twice",
+			phases: func(r *phaseRecorder) []*phase {
+				return []*phase{
+					r.fakePhase("a", nil, nil),
+					r.fakePhase("a", nil, nil),
+				}
+			},
+			wantErrs: []string{`phase "a" is declared twice`},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			u, err := GetUploaderForLocalValidation()
+			if err != nil {
+				t.Fatalf("creating uploader: %v", err)
+			}
+			r := &phaseRecorder{finished: map[string]bool{}}
+			phases := test.phases(r)
+
+			err = u.runPhases(context.Background(), &conversion.Output{}, phases)
+			if len(test.wantErrs) == 0 && err != nil {
+				t.Errorf("runPhases: %v", err)
+			}
+			for _, want := range test.wantErrs {
+				if err == nil || !strings.Contains(err.Error(), want) {
+					t.Errorf("runPhases = %v, want an error containing %q", err, want)
+				}
+			}
+			if len(r.finished) != len(test.ran) {
+				t.Errorf("ran %v, want %v", r.events, test.ran)
+			}
+			for _, name := range test.ran {
+				if !r.finished[name] {
+					t.Errorf("phase %s didn't run", name)
+				}
+			}
+			for _, p := range phases {
+				for _, d := range p.dependsOn {
+					if start := r.index("start " + p.name); start >= 0 && start < r.index("finish "+d) {
+						t.Errorf("phase %s started before its dependency %s finished: %v", p.name, d, r.events)
+					}
+				}
+			}
+			if r.maxRunning > defaultMaxParallelPhases {
+				t.Errorf("%d phases ran at once, want at most %d", r.maxRunning, defaultMaxParallelPhases)
+			}
+			if test.parallel > 0 && r.maxRunning != test.parallel {
+				t.Errorf("%d phases ran at once, want %d", r.maxRunning, test.parallel)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package reconcile
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Silicon-Ally/etap2sf/etap/data"
+	"github.com/Silicon-Ally/etap2sf/etap/generated"
+	"github.com/Silicon-Ally/etap2sf/etap/generated/overrides"
+)
+
+// gift is the part of a journal entry that becomes an opportunity which the
+// reconciliation looks at.
+type gift struct {
+	ref        string
+	accountRef string
+	amount     float64
+	date       *generated.DateTime
+	campaign   *string
+	fund       *string
+}
+
+// toGift returns the journal entry as a gift, or nil if it doesn't become an
+// opportunity. Disbursements aren't migrated, so they're left out.
+func toGift(je *overrides.JournalEntry) *gift {
+	switch {
+	case je.Gift != nil:
+		g := je.Gift
+		return &gift{ref: deref(g.Ref), accountRef: deref(g.AccountRef), amount: derefAmount(g.Amount), date: g.Date, campaign: g.Campaign, fund: g.Fund}
+	case je.Pledge != nil:
+		p := je.Pledge
+		return &gift{ref: deref(p.Ref), accountRef: deref(p.AccountRef), amount: derefAmount(p.Amount), date: p.Date, campaign: p.Campaign, fund: p.Fund}
+	case je.RecurringGift != nil:
+		rg := je.RecurringGift
+		return &gift{ref: deref(rg.Ref), accountRef: deref(rg.AccountRef), amount: derefAmount(rg.Amount), date: rg.Date, campaign: rg.Campaign, fund: rg.Fund}
+	case je.SegmentedDonation != nil:
+		sd := je.SegmentedDonation
+		return &gift{ref: deref(sd.Ref), accountRef: deref(sd.AccountRef), amount: derefAmount(sd.TotalAmount), date: sd.Date}
+	}
+	return nil
+}
+
+// etapFigures computes the figures from the cached eTapestry export, the same
+// way that the conversion turns it into records.
+func etapFigures() (*figures, error) {
+	jes, err := data.GetJournalEntries()
+	if err != nil {
+		return nil, fmt.Errorf("getting journal entries: %w", err)
+	}
+	funds, err := data.GetFunds()
+	if err != nil {
+		return nil, fmt.Errorf("getting funds: %w", err)
+	}
+	fundRefs := map[string]string{}
+	for _, f := range funds {
+		if f.Name != nil && f.Ref != nil {
+			fundRefs[*f.Name] = *f.Ref
+		}
+	}
+	result := newFigures()
+	for _, je := range jes {
+		if p := je.Payment; p != nil {
+			amount := derefAmount(p.Amount)
+			result.payments[deref(p.Ref)] = &record{account: deref(p.AccountRef), amount: amount}
+			result.add(result.paymentsByYear, year(p.Date), amount)
+			continue
+		}
+		g := toGift(je)
+		if g == nil {
+			continue
+		}
+		result.gifts[g.ref] = &record{account: g.accountRef, amount: g.amount}
+		result.add(result.byAccount, g.accountRef, g.amount)
+		result.add(result.giftsByYear, year(g.date), g.amount)
+		if g.campaign != nil && *g.campaign != "" {
+			result.add(result.byCampaign, *g.campaign, g.amount)
+		}
+		// As in the conversion, gifts with a fund are allocated to it in
+		// full, unless they're negative.
+		if g.fund != nil && *g.fund != "" && g.amount >= 0 {
+			ref, ok := fundRefs[*g.fund]
+			if !ok {
+				return nil, fmt.Errorf("journal entry %q has unknown fund %q", g.ref, *g.fund)
+			}
+			result.add(result.byFund, ref, g.amount)
+			result.fundNames[ref] = *g.fund
+		}
+	}
+	return result, nil
+}
+
+// year is the year of an eTapestry date, or noValue if it doesn't have one.
+func year(d *generated.DateTime) string {
+	if d == nil || *d == "" {
+		return noValue
+	}
+	if len(*d) < len("2006-01-02") {
+		return noValue
+	}
+	t, err := time.Parse("2006-01-02", string(*d)[:len("2006-01-02")])
+	if err != nil {
+		return noValue
+	}
+	return fmt.Sprint(t.Year())
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func derefAmount(f *float64) float64 {
+	if f == nil {
+		return 0
+	}
+	return *f
+}
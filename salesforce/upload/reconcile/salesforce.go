@@ -0,0 +1,117 @@
+package reconcile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Silicon-Ally/etap2sf/conv/etapfields"
+	"github.com/Silicon-Ally/etap2sf/etap"
+	"github.com/Silicon-Ally/etap2sf/logging"
+	"github.com/Silicon-Ally/etap2sf/salesforce"
+	client "github.com/Silicon-Ally/etap2sf/salesforce/clients/enterprise"
+)
+
+// Only records that the migration uploaded have an eTapestry ref, which
+// leaves out i.e. the payments and allocations that NPSP creates on its own.
+var (
+	opportunityQuery = fmt.Sprintf(
+		"SELECT Id, %[1]s, Amount, Account.%[1]s, Contact.%[2]s FROM Opportunity WHERE %[1]s != NULL",
+		salesforce.MultiObjectExternalFieldKey, contactKey)
+	paymentQuery = fmt.Sprintf(
+		"SELECT Id, %[1]s, npe01__Payment_Amount__c FROM npe01__OppPayment__c WHERE %[1]s != NULL",
+		salesforce.MultiObjectExternalFieldKey)
+	giftsByYearQuery = fmt.Sprintf(
+		"SELECT CALENDAR_YEAR(CloseDate) grp, COUNT(Id) n, SUM(Amount) total FROM Opportunity WHERE %s != NULL GROUP BY CALENDAR_YEAR(CloseDate)",
+		salesforce.MultiObjectExternalFieldKey)
+	paymentsByYearQuery = fmt.Sprintf(
+		"SELECT CALENDAR_YEAR(npe01__Payment_Date__c) grp, COUNT(Id) n, SUM(npe01__Payment_Amount__c) total FROM npe01__OppPayment__c WHERE %s != NULL GROUP BY CALENDAR_YEAR(npe01__Payment_Date__c)",
+		salesforce.MultiObjectExternalFieldKey)
+	byFundQuery = fmt.Sprintf(
+		"SELECT npsp__General_Accounting_Unit__r.%s grp, COUNT(Id) n, SUM(npsp__Amount__c) total FROM npsp__Allocation__c WHERE %s != NULL GROUP BY npsp__General_Accounting_Unit__r.%[1]s",
+		fundKey, salesforce.MultiObjectExternalFieldKey)
+)
+
+const (
+	contactKey = "etap_Account_Ref__c"
+	fundKey    = "etap_Fund_Ref__c"
+)
+
+// campaignTypes are the eTapestry types whose campaign is kept on the
+// opportunities made from them, in a field of their own.
+var campaignTypes = []etap.ObjectType{etap.ObjectType_Gift, etap.ObjectType_Pledge, etap.ObjectType_RecurringGift}
+
+// salesforceFigures computes the figures from what's in the org. The totals
+// by year, campaign and fund are aggregate queries, and if one fails (i.e.
+// because a campaign field wasn't created), it's noted in skipped rather than
+// failing the reconciliation. Gifts by account are added up from the
+// opportunities themselves, since an aggregate query with a row per account
+// would be cut off at 2,000 accounts.
+func salesforceFigures(c *client.Client) (result *figures, skipped []string, err error) {
+	logger := logging.For("reconcile")
+	result = newFigures()
+
+	logger.Info("fetching opportunities")
+	rows, err := c.QueryRows(opportunityQuery)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting opportunities: %w", err)
+	}
+	for _, row := range rows {
+		account := row["contact."+strings.ToLower(contactKey)]
+		if account == "" {
+			account = row["account."+strings.ToLower(salesforce.MultiObjectExternalFieldKey)]
+		}
+		amount := parseAmount(row["amount"])
+		result.gifts[row[strings.ToLower(salesforce.MultiObjectExternalFieldKey)]] = &record{id: row["id"], account: account, amount: amount}
+		result.add(result.byAccount, account, amount)
+	}
+
+	logger.Info("fetching payments")
+	if rows, err = c.QueryRows(paymentQuery); err != nil {
+		return nil, nil, fmt.Errorf("getting payments: %w", err)
+	}
+	for _, row := range rows {
+		result.payments[row[strings.ToLower(salesforce.MultiObjectExternalFieldKey)]] = &record{id: row["id"], amount: parseAmount(row["npe01__payment_amount__c"])}
+	}
+
+	aggregate := func(name, query string, into map[string]*Totals) {
+		logger.Info("aggregating", "totals", name)
+		rows, err := c.QueryRows(query)
+		if err != nil {
+			logger.Warn("couldn't aggregate", "totals", name, "error", err)
+			skipped = append(skipped, fmt.Sprintf("%s: %v", name, err))
+			return
+		}
+		for _, row := range rows {
+			key := row["grp"]
+			if key == "" {
+				key = noValue
+			}
+			n, _ := strconv.Atoi(row["n"])
+			t := into[key]
+			if t == nil {
+				t = &Totals{}
+				into[key] = t
+			}
+			t.Count += n
+			t.Amount += parseAmount(row["total"])
+		}
+	}
+	aggregate(section_GiftsByYear, giftsByYearQuery, result.giftsByYear)
+	aggregate(section_PaymentsByYear, paymentsByYearQuery, result.paymentsByYear)
+	aggregate(section_ByFund, byFundQuery, result.byFund)
+	for _, ot := range campaignTypes {
+		field, _, err := etapfields.CreateSalesforceFieldNameAndLabel(ot.String(), "Campaign", false)
+		if err != nil {
+			return nil, nil, fmt.Errorf("getting the campaign field for %s: %w", ot, err)
+		}
+		query := fmt.Sprintf("SELECT %[1]s grp, COUNT(Id) n, SUM(Amount) total FROM Opportunity WHERE %[2]s != NULL AND %[1]s != NULL GROUP BY %[1]s", field, salesforce.MultiObjectExternalFieldKey)
+		aggregate(fmt.Sprintf("%s (%s)", section_ByCampaign, field), query, result.byCampaign)
+	}
+	return result, skipped, nil
+}
+
+func parseAmount(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
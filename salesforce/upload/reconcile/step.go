@@ -0,0 +1,342 @@
+// Package reconcile checks that the migration is complete, by adding up the
+// gifts and payments in the cached eTapestry export and comparing them with
+// what's in Salesforce: gift counts and sums per account, allocations per
+// fund, gifts per campaign, and gifts and payments per year. It also compares
+// the opportunities and payments themselves, by their eTapestry refs, so that
+// a mismatched total can be traced back to the records behind it.
+//
+// Gifts made by an account that the conversion folded into another (i.e. a
+// secondary role of a household) are credited to the other account in
+// Salesforce, so they show up as a mismatch for both.
+package reconcile
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Silicon-Ally/etap2sf/salesforce"
+	esfutils "github.com/Silicon-Ally/etap2sf/salesforce/clients/enterprise/utils"
+	"github.com/Silicon-Ally/etap2sf/salesforce/profile"
+)
+
+const (
+	section_ByAccount      = "gifts by account"
+	section_ByFund         = "allocations by fund"
+	section_ByCampaign     = "gifts by campaign"
+	section_GiftsByYear    = "gifts by year"
+	section_PaymentsByYear = "payments by year"
+
+	// noValue is the key of records without a year, fund or account.
+	noValue = "(none)"
+	// examplesPerSection is how many mismatches of each kind are in the summary.
+	examplesPerSection = 20
+)
+
+// Totals are how many records are in a group, and what their amounts add up to.
+type Totals struct {
+	Count  int
+	Amount float64
+}
+
+func (t *Totals) equal(o *Totals) bool {
+	return t.Count == o.Count && cents(t.Amount) == cents(o.Amount)
+}
+
+func cents(f float64) int64 {
+	return int64(math.Round(f * 100))
+}
+
+// record is an opportunity or payment, by its eTapestry ref.
+type record struct {
+	id      string
+	account string
+	amount  float64
+}
+
+// figures are the totals and records from one side of the migration.
+type figures struct {
+	byAccount      map[string]*Totals
+	byFund         map[string]*Totals
+	byCampaign     map[string]*Totals
+	giftsByYear    map[string]*Totals
+	paymentsByYear map[string]*Totals
+	// fundNames are the names of the funds in byFund, by their refs.
+	fundNames map[string]string
+	gifts     map[string]*record
+	payments  map[string]*record
+}
+
+func newFigures() *figures {
+	return &figures{
+		byAccount:      map[string]*Totals{},
+		byFund:         map[string]*Totals{},
+		byCampaign:     map[string]*Totals{},
+		giftsByYear:    map[string]*Totals{},
+		paymentsByYear: map[string]*Totals{},
+		fundNames:      map[string]string{},
+		gifts:          map[string]*record{},
+		payments:       map[string]*record{},
+	}
+}
+
+func (f *figures) add(into map[string]*Totals, key string, amount float64) {
+	if key == "" {
+		key = noValue
+	}
+	t := into[key]
+	if t == nil {
+		t = &Totals{}
+		into[key] = t
+	}
+	t.Count++
+	t.Amount += amount
+}
+
+type Report struct {
+	GeneratedAt time.Time
+	Sections    []*Section
+	Records     []*RecordMismatch
+	// Skipped are the totals that couldn't be computed in Salesforce, and why.
+	Skipped []string
+}
+
+// Section compares one breakdown of the totals, like gifts by year.
+type Section struct {
+	Name       string
+	Compared   int
+	Mismatches []*Mismatch
+}
+
+type Mismatch struct {
+	Key string
+	// Label describes the key, like the name of a fund.
+	Label      string `json:",omitempty"`
+	ETap       Totals
+	Salesforce Totals
+}
+
+type RecordProblem string
+
+const (
+	RecordProblem_Missing        RecordProblem = "missing from Salesforce"
+	RecordProblem_Extra          RecordProblem = "not in eTapestry"
+	RecordProblem_AmountDiffers  RecordProblem = "amount differs"
+	RecordProblem_AccountDiffers RecordProblem = "account differs"
+)
+
+// RecordMismatch is an opportunity or payment that doesn't match its
+// eTapestry journal entry.
+type RecordMismatch struct {
+	ObjectType salesforce.ObjectType
+	Ref        string
+	ID         string `json:",omitempty"`
+	Problem    RecordProblem
+	ETap       string `json:",omitempty"`
+	Salesforce string `json:",omitempty"`
+}
+
+func Run() error {
+	etapSide, err := etapFigures()
+	if err != nil {
+		return fmt.Errorf("adding up the eTapestry export: %w", err)
+	}
+	c, err := esfutils.NewSandboxClient()
+	if err != nil {
+		return fmt.Errorf("creating client: %w", err)
+	}
+	sfSide, skipped, err := salesforceFigures(c)
+	if err != nil {
+		return fmt.Errorf("adding up what's in Salesforce: %w", err)
+	}
+	report := compare(etapSide, sfSide)
+	report.Skipped = skipped
+
+	jsonPath, err := profile.DataPath("reconciliation.json")
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling report: %w", err)
+	}
+	if err := os.WriteFile(jsonPath, data, 0777); err != nil {
+		return fmt.Errorf("writing report to %s: %w", jsonPath, err)
+	}
+	summaryPath, err := profile.DataPath("reconciliation.txt")
+	if err != nil {
+		return err
+	}
+	summary := report.Summary()
+	if err := os.WriteFile(summaryPath, []byte(summary), 0777); err != nil {
+		return fmt.Errorf("writing summary to %s: %w", summaryPath, err)
+	}
+	fmt.Print(summary)
+	fmt.Printf("\nThe full report is in %s\n", jsonPath)
+	if n := report.discrepancies(); n > 0 {
+		return fmt.Errorf("found %d discrepancies between eTapestry and Salesforce", n)
+	}
+	return nil
+}
+
+func compare(etapSide, sfSide *figures) *Report {
+	report := &Report{GeneratedAt: time.Now()}
+	fundLabel := func(ref string) string { return etapSide.fundNames[ref] }
+	report.Sections = []*Section{
+		compareTotals(section_ByAccount, etapSide.byAccount, sfSide.byAccount, nil),
+		compareTotals(section_ByFund, etapSide.byFund, sfSide.byFund, fundLabel),
+		compareTotals(section_ByCampaign, etapSide.byCampaign, sfSide.byCampaign, nil),
+		compareTotals(section_GiftsByYear, etapSide.giftsByYear, sfSide.giftsByYear, nil),
+		compareTotals(section_PaymentsByYear, etapSide.paymentsByYear, sfSide.paymentsByYear, nil),
+	}
+	report.Records = append(report.Records, compareRecords(salesforce.ObjectType_Opportunity, etapSide.gifts, sfSide.gifts, true)...)
+	report.Records = append(report.Records, compareRecords(salesforce.ObjectType_Payment, etapSide.payments, sfSide.payments, false)...)
+	return report
+}
+
+func compareTotals(name string, etapSide, sfSide map[string]*Totals, label func(key string) string) *Section {
+	keys := map[string]bool{}
+	for k := range etapSide {
+		keys[k] = true
+	}
+	for k := range sfSide {
+		keys[k] = true
+	}
+	s := &Section{Name: name, Compared: len(keys)}
+	for k := range keys {
+		e, sf := etapSide[k], sfSide[k]
+		if e == nil {
+			e = &Totals{}
+		}
+		if sf == nil {
+			sf = &Totals{}
+		}
+		if e.equal(sf) {
+			continue
+		}
+		m := &Mismatch{Key: k, ETap: *e, Salesforce: *sf}
+		if label != nil {
+			m.Label = label(k)
+		}
+		s.Mismatches = append(s.Mismatches, m)
+	}
+	sort.Slice(s.Mismatches, func(i, j int) bool { return s.Mismatches[i].Key < s.Mismatches[j].Key })
+	return s
+}
+
+func compareRecords(sot salesforce.ObjectType, etapSide, sfSide map[string]*record, checkAccount bool) []*RecordMismatch {
+	result := []*RecordMismatch{}
+	for ref, e := range etapSide {
+		sf, ok := sfSide[ref]
+		if !ok {
+			result = append(result, &RecordMismatch{ObjectType: sot, Ref: ref, Problem: RecordProblem_Missing, ETap: formatAmount(e.amount)})
+			continue
+		}
+		if cents(e.amount) != cents(sf.amount) {
+			result = append(result, &RecordMismatch{ObjectType: sot, Ref: ref, ID: sf.id, Problem: RecordProblem_AmountDiffers, ETap: formatAmount(e.amount), Salesforce: formatAmount(sf.amount)})
+		}
+		if checkAccount && e.account != sf.account {
+			result = append(result, &RecordMismatch{ObjectType: sot, Ref: ref, ID: sf.id, Problem: RecordProblem_AccountDiffers, ETap: e.account, Salesforce: sf.account})
+		}
+	}
+	for ref, sf := range sfSide {
+		if _, ok := etapSide[ref]; !ok {
+			result = append(result, &RecordMismatch{ObjectType: sot, Ref: ref, ID: sf.id, Problem: RecordProblem_Extra, Salesforce: formatAmount(sf.amount)})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Problem != result[j].Problem {
+			return result[i].Problem < result[j].Problem
+		}
+		return result[i].Ref < result[j].Ref
+	})
+	return result
+}
+
+func formatAmount(f float64) string {
+	return fmt.Sprintf("$%.2f", f)
+}
+
+func (r *Report) discrepancies() int {
+	n := len(r.Records)
+	for _, s := range r.Sections {
+		n += len(s.Mismatches)
+	}
+	return n
+}
+
+// Summary is a human readable version of the report, with the first few
+// mismatches of each kind.
+func (r *Report) Summary() string {
+	sb := strings.Builder{}
+	fmt.Fprintf(&sb, "Reconciliation of eTapestry with Salesforce at %s\n\n", r.GeneratedAt.Format(time.RFC1123))
+	fmt.Fprintf(&sb, "%-24s %10s %10s\n", "Totals", "Compared", "Mismatched")
+	for _, s := range r.Sections {
+		fmt.Fprintf(&sb, "%-24s %10d %10d\n", s.Name, s.Compared, len(s.Mismatches))
+	}
+
+	byKind := map[salesforce.ObjectType]map[RecordProblem][]*RecordMismatch{}
+	for _, m := range r.Records {
+		if byKind[m.ObjectType] == nil {
+			byKind[m.ObjectType] = map[RecordProblem][]*RecordMismatch{}
+		}
+		byKind[m.ObjectType][m.Problem] = append(byKind[m.ObjectType][m.Problem], m)
+	}
+	problems := []RecordProblem{RecordProblem_Missing, RecordProblem_Extra, RecordProblem_AmountDiffers, RecordProblem_AccountDiffers}
+	fmt.Fprintf(&sb, "\n%-24s %10s %10s %10s %10s\n", "Records", "Missing", "Extra", "Amount", "Account")
+	for _, sot := range []salesforce.ObjectType{salesforce.ObjectType_Opportunity, salesforce.ObjectType_Payment} {
+		fmt.Fprintf(&sb, "%-24s", sot)
+		for _, p := range problems {
+			fmt.Fprintf(&sb, " %10d", len(byKind[sot][p]))
+		}
+		sb.WriteString("\n")
+	}
+
+	for _, s := range r.Sections {
+		if len(s.Mismatches) == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "\n%s - mismatches:\n", s.Name)
+		for i, m := range s.Mismatches {
+			if i == examplesPerSection {
+				fmt.Fprintf(&sb, "  ... and %d more\n", len(s.Mismatches)-i)
+				break
+			}
+			key := m.Key
+			if m.Label != "" {
+				key += " (" + m.Label + ")"
+			}
+			fmt.Fprintf(&sb, "  %-40s eTapestry: %d for %s, Salesforce: %d for %s\n", key, m.ETap.Count, formatAmount(m.ETap.Amount), m.Salesforce.Count, formatAmount(m.Salesforce.Amount))
+		}
+	}
+	for _, sot := range []salesforce.ObjectType{salesforce.ObjectType_Opportunity, salesforce.ObjectType_Payment} {
+		for _, p := range problems {
+			ms := byKind[sot][p]
+			if len(ms) == 0 {
+				continue
+			}
+			fmt.Fprintf(&sb, "\n%s %s:\n", sot, p)
+			for i, m := range ms {
+				if i == examplesPerSection {
+					fmt.Fprintf(&sb, "  ... and %d more\n", len(ms)-i)
+					break
+				}
+				fmt.Fprintf(&sb, "  %-30s %-20s eTapestry: %-20s Salesforce: %s\n", m.Ref, m.ID, m.ETap, m.Salesforce)
+			}
+		}
+	}
+	if len(r.Skipped) > 0 {
+		sb.WriteString("\nThese totals couldn't be computed in Salesforce, and are compared as if it had none:\n")
+		for _, s := range r.Skipped {
+			fmt.Fprintf(&sb, "  %s\n", s)
+		}
+	}
+	if r.discrepancies() == 0 {
+		sb.WriteString("\nEverything matches.\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,289 @@
+// Package rollback deletes the records that the upload created, and nothing
+// else. Records are only deleted if both their external key is set and their ID
+// is one that the upload recorded, so records that were already in the org are
+// left alone. Types are deleted in the reverse of the order they're uploaded
+// in, so nothing is deleted while other migrated records still refer to it.
+package rollback
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Silicon-Ally/etap2sf/logging"
+	"github.com/Silicon-Ally/etap2sf/salesforce"
+	client "github.com/Silicon-Ally/etap2sf/salesforce/clients/enterprise"
+	esfutils "github.com/Silicon-Ally/etap2sf/salesforce/clients/enterprise/utils"
+	"github.com/Silicon-Ally/etap2sf/salesforce/profile"
+	"github.com/Silicon-Ally/etap2sf/salesforce/upload"
+	"github.com/Silicon-Ally/etap2sf/salesforce/upload/state"
+	"github.com/Silicon-Ally/etap2sf/utils"
+)
+
+const batchSize = 200
+
+type Options struct {
+	// DryRun only reports what would be deleted.
+	DryRun bool
+	// ObjectTypes limits the rollback to these types. All are rolled back if it's empty.
+	ObjectTypes []salesforce.ObjectType
+}
+
+// Plan is what a rollback deletes (or, in a dry run, would delete).
+type Plan struct {
+	GeneratedAt time.Time
+	DryRun      bool
+	Types       []*TypePlan
+}
+
+type TypePlan struct {
+	ObjectType salesforce.ObjectType
+	// IDs are the records that were created by the upload.
+	IDs []string
+	// Skipped is how many records with an external key weren't created by the
+	// upload (as far as its state knows), and so are left alone.
+	Skipped int
+	Deleted int
+	Errors  []string `json:",omitempty"`
+}
+
+// logEntry is a line of the rollback log, which records every batch that was
+// deleted. Since deleted records are also removed from the upload state, a
+// rollback that's interrupted carries on where it left off when it's re-run.
+type logEntry struct {
+	Time       time.Time
+	ObjectType salesforce.ObjectType
+	IDs        []string
+	Errors     []string `json:",omitempty"`
+}
+
+func Run(opts *Options) error {
+	c, err := esfutils.NewSandboxClient()
+	if err != nil {
+		return fmt.Errorf("creating client: %w", err)
+	}
+	store, err := upload.OpenState()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	plan, err := rollback(c, store, opts)
+	if plan != nil {
+		if werr := writePlan(plan); werr != nil && err == nil {
+			err = werr
+		}
+		fmt.Print(plan.Summary())
+	}
+	return err
+}
+
+func rollback(c *client.Client, store *state.Store, opts *Options) (*Plan, error) {
+	// IDs that the upload created, and the refs they were created for.
+	refsByID := map[string][]string{}
+	err := store.Records(func(r *state.Record) error {
+		if r.Status == state.Status_Succeeded && r.SalesforceID != "" {
+			refsByID[r.SalesforceID] = append(refsByID[r.SalesforceID], r.Ref)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading upload state: %w", err)
+	}
+
+	include := map[salesforce.ObjectType]bool{}
+	for _, sot := range opts.ObjectTypes {
+		include[sot] = true
+	}
+	order := upload.ObjectTypesInUploadOrder()
+	plan := &Plan{GeneratedAt: time.Now(), DryRun: opts.DryRun}
+	for i := len(order) - 1; i >= 0; i-- {
+		sot := order[i]
+		if len(include) > 0 && !include[sot] {
+			continue
+		}
+		tp, err := planType(c, sot, refsByID)
+		if err != nil {
+			return nil, fmt.Errorf("planning rollback of %s: %w", sot, err)
+		}
+		if tp != nil {
+			plan.Types = append(plan.Types, tp)
+		}
+	}
+	if opts.DryRun {
+		return plan, nil
+	}
+	if err := confirm(plan); err != nil {
+		return nil, err
+	}
+	for _, tp := range plan.Types {
+		if err := deleteType(c, store, tp, refsByID); err != nil {
+			return plan, fmt.Errorf("rolling back %s: %w", tp.ObjectType, err)
+		}
+	}
+	return plan, nil
+}
+
+// confirm asks before anything is deleted. Production orgs need the profile's
+// name typed in, rather than just a yes.
+func confirm(plan *Plan) error {
+	total := 0
+	for _, tp := range plan.Types {
+		fmt.Printf("%-24s %10d\n", tp.ObjectType, len(tp.IDs))
+		total += len(tp.IDs)
+	}
+	p, err := profile.Active()
+	if err != nil {
+		return err
+	}
+	if p.Production {
+		return profile.ConfirmWrite(fmt.Sprintf("permanently delete these %d records", total))
+	}
+	fmt.Printf("Permanently delete these %d records from Salesforce? [y/N] ", total)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+		return fmt.Errorf("rollback cancelled")
+	}
+	return nil
+}
+
+// planType finds the records of the given type that the upload created.
+func planType(c *client.Client, sot salesforce.ObjectType, refsByID map[string][]string) (*TypePlan, error) {
+	switch sot {
+	case salesforce.ObjectType_ContentDocumentLink:
+		// Links are deleted along with their content documents.
+		return nil, nil
+	case salesforce.ObjectType_ContentVersion:
+		// Versions can't be deleted directly - their documents are deleted instead.
+		records, err := c.GetRecordsByExternalKey(sot, []string{"ContentDocumentId"})
+		if err != nil {
+			return nil, err
+		}
+		tp := &TypePlan{ObjectType: sot}
+		for _, r := range records {
+			if _, ok := refsByID[r["id"]]; ok && r["contentdocumentid"] != "" {
+				tp.IDs = append(tp.IDs, r["contentdocumentid"])
+				// So that the versions' state is cleared when their documents are deleted.
+				refsByID[r["contentdocumentid"]] = append(refsByID[r["contentdocumentid"]], refsByID[r["id"]]...)
+			} else {
+				tp.Skipped++
+			}
+		}
+		sort.Strings(tp.IDs)
+		return tp, nil
+	}
+	records, err := c.GetRecordsByExternalKey(sot, nil)
+	if err != nil {
+		return nil, err
+	}
+	tp := &TypePlan{ObjectType: sot}
+	for _, r := range records {
+		if _, ok := refsByID[r["id"]]; ok {
+			tp.IDs = append(tp.IDs, r["id"])
+		} else {
+			tp.Skipped++
+		}
+	}
+	sort.Strings(tp.IDs)
+	return tp, nil
+}
+
+func deleteType(c *client.Client, store *state.Store, tp *TypePlan, refsByID map[string][]string) error {
+	logger := logging.For("rollback")
+	progress := logging.NewProgress("rollback", string(tp.ObjectType), len(tp.IDs))
+	defer progress.Done()
+	for _, batch := range utils.SplitIntoBatches(tp.IDs, batchSize) {
+		errs, err := c.DeleteBatch(batch)
+		if err != nil {
+			return err
+		}
+		entry := &logEntry{Time: time.Now(), ObjectType: tp.ObjectType}
+		deletedRefs := []string{}
+		for i, id := range batch {
+			if errs[i] != nil {
+				msg := fmt.Sprintf("%s: %v", id, errs[i])
+				tp.Errors = append(tp.Errors, msg)
+				entry.Errors = append(entry.Errors, msg)
+				progress.Failed()
+				continue
+			}
+			entry.IDs = append(entry.IDs, id)
+			deletedRefs = append(deletedRefs, refsByID[id]...)
+			tp.Deleted++
+			progress.Succeeded()
+		}
+		if err := appendToLog(entry); err != nil {
+			return err
+		}
+		if err := store.Delete(deletedRefs); err != nil {
+			return fmt.Errorf("removing deleted records from the upload state: %w", err)
+		}
+		if len(entry.Errors) > 0 {
+			logger.Warn("some records couldn't be deleted", "object_type", string(tp.ObjectType), "errors", len(entry.Errors), "first", entry.Errors[0])
+		}
+	}
+	return nil
+}
+
+func appendToLog(entry *logEntry) error {
+	path, err := profile.DataPath("rollback-log.jsonl")
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshalling rollback log entry: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return fmt.Errorf("opening rollback log: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("writing rollback log: %w", err)
+	}
+	return f.Sync()
+}
+
+func writePlan(plan *Plan) error {
+	name := "rollback-report.json"
+	if plan.DryRun {
+		name = "rollback-plan.json"
+	}
+	path, err := profile.DataPath(name)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling rollback plan: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0777); err != nil {
+		return fmt.Errorf("writing rollback plan to %s: %w", path, err)
+	}
+	return nil
+}
+
+// Summary is a human readable version of the plan.
+func (p *Plan) Summary() string {
+	sb := strings.Builder{}
+	if p.DryRun {
+		sb.WriteString("Dry run of a rollback - nothing was deleted.\n\n")
+		fmt.Fprintf(&sb, "%-24s %10s %10s\n", "Object Type", "Delete", "Leave")
+		for _, t := range p.Types {
+			fmt.Fprintf(&sb, "%-24s %10d %10d\n", t.ObjectType, len(t.IDs), t.Skipped)
+		}
+		sb.WriteString("\nThe IDs that would be deleted are in data/rollback-plan.json.\n")
+		return sb.String()
+	}
+	fmt.Fprintf(&sb, "%-24s %10s %10s %10s\n", "Object Type", "Deleted", "Errors", "Left")
+	for _, t := range p.Types {
+		fmt.Fprintf(&sb, "%-24s %10d %10d %10d\n", t.ObjectType, t.Deleted, len(t.Errors), t.Skipped)
+	}
+	sb.WriteString("\nEvery deleted batch is logged in data/rollback-log.jsonl. Re-run to retry anything that failed.\n")
+	return sb.String()
+}
@@ -0,0 +1,139 @@
+package upload
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Silicon-Ally/etap2sf/salesforce"
+)
+
+// Selection limits an upload to some of the records, i.e. to re-upload a few
+// records after fixing them without walking through everything else.
+type Selection struct {
+	// ObjectTypes are the types to upload. Every type is uploaded if it's empty.
+	ObjectTypes []salesforce.ObjectType
+	// Refs are the eTapestry refs of the records to upload. Every record (of
+	// the selected types) is uploaded if it's nil.
+	Refs map[string]bool
+	// Hard re-uploads the selected records even if they've already been
+	// uploaded. Records that aren't selected are never touched.
+	Hard bool
+}
+
+// Select limits the upload to the given records.
+func (u *Uploader) Select(s *Selection) {
+	u.selection = s
+}
+
+func (u *Uploader) hard() bool {
+	return u.selection != nil && u.selection.Hard
+}
+
+// includesType reports whether records of the given type are selected.
+func (s *Selection) includesType(sot salesforce.ObjectType) bool {
+	if s == nil || len(s.ObjectTypes) == 0 {
+		return true
+	}
+	for _, t := range s.ObjectTypes {
+		if t == sot {
+			return true
+		}
+	}
+	return false
+}
+
+// includesKey reports whether the record with the given external key is
+// selected. Keys are usually the eTapestry ref itself, but some have a suffix,
+// like relationships (which become two records) and content document links.
+func (s *Selection) includesKey(key string) bool {
+	if s == nil || s.Refs == nil {
+		return true
+	}
+	return s.Refs[key] || s.Refs[refOfKey(key)]
+}
+
+// refOfKey is the eTapestry ref at the start of an external key. Refs are made
+// of digits and dots, like 489.0.12345678.
+func refOfKey(key string) string {
+	i := strings.IndexFunc(key, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		return key
+	}
+	return strings.TrimRight(key[:i], ".")
+}
+
+// selectedPhases works out which phases are selected: the phases whose types
+// are selected, and the phases that don't upload records themselves (like
+// looking up content document IDs) which anything selected depends on.
+func (s *Selection) selectedPhases(phases []*phase) map[string]bool {
+	selected := map[string]bool{}
+	for _, p := range phases {
+		if p.objectType != "" && s.includesType(p.objectType) {
+			selected[p.name] = true
+		}
+	}
+	// Phases are declared after their dependencies, so walking backwards
+	// reaches every phase after everything that depends on it.
+	for i := len(phases) - 1; i >= 0; i-- {
+		if !selected[phases[i].name] {
+			continue
+		}
+		for _, d := range phases[i].dependsOn {
+			for _, dp := range phases {
+				if dp.name == d && dp.objectType == "" {
+					selected[d] = true
+				}
+			}
+		}
+	}
+	return selected
+}
+
+// ParseObjectTypes parses a comma separated list of object types, like "Task,Opportunity".
+func ParseObjectTypes(s string) ([]salesforce.ObjectType, error) {
+	if s == "" {
+		return nil, nil
+	}
+	known := map[string]salesforce.ObjectType{}
+	for _, sot := range ObjectTypesInUploadOrder() {
+		known[strings.ToLower(string(sot))] = sot
+	}
+	result := []salesforce.ObjectType{}
+	for _, name := range strings.Split(s, ",") {
+		sot, ok := known[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("unknown or unsupported object type %q", name)
+		}
+		result = append(result, sot)
+	}
+	return result, nil
+}
+
+// ReadRefsFile reads a file of eTapestry refs, one per line. Blank lines and
+// lines starting with # are ignored, as is anything after the first comma or
+// whitespace, so the first column of a CSV works too.
+func ReadRefsFile(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening refs file: %w", err)
+	}
+	defer f.Close()
+	refs := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if i := strings.IndexAny(line, ", \t"); i >= 0 {
+			line = line[:i]
+		}
+		refs[strings.Trim(line, `"`)] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return refs, nil
+}
@@ -0,0 +1,84 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// legacyUploader is the subset of the uploader that used to be saved to
+// data/uploader.json.
+type legacyUploader struct {
+	NumThreads int
+	MaxErrors  int
+	Failed     map[string]bool
+	Succeeded  map[string]bool
+	IDMap      map[string]string
+	Wetrun     bool
+	DoShuffles bool
+}
+
+// ImportJSON imports the state from an uploader.json file written by an older
+// version of the uploader, in a single transaction, and renames the file so
+// that it isn't imported again. It does nothing if the file doesn't exist.
+func (s *Store) ImportJSON(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("reading %s: %w", path, err)
+	}
+	u := &legacyUploader{}
+	if err := json.Unmarshal(data, u); err != nil {
+		return false, fmt.Errorf("unmarshalling %s: %w", path, err)
+	}
+	now := time.Now()
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(metaBucket)
+		if meta.Get(migratedKey) != nil {
+			return fmt.Errorf("state was already imported from %s, remove it to continue", meta.Get(migratedKey))
+		}
+		b := tx.Bucket(recordsBucket)
+		put := func(r *Record) error {
+			data, err := json.Marshal(r)
+			if err != nil {
+				return fmt.Errorf("marshalling state of %s: %w", r.Ref, err)
+			}
+			return b.Put([]byte(r.Ref), data)
+		}
+		for ref, ok := range u.Succeeded {
+			if !ok {
+				continue
+			}
+			if err := put(&Record{Ref: ref, Status: Status_Succeeded, SalesforceID: u.IDMap[ref], Attempts: 1, UpdatedAt: now}); err != nil {
+				return err
+			}
+		}
+		for ref, ok := range u.Failed {
+			if !ok || u.Succeeded[ref] {
+				continue
+			}
+			if err := put(&Record{Ref: ref, Status: Status_Failed, Error: "imported from uploader.json", Attempts: 1, UpdatedAt: now}); err != nil {
+				return err
+			}
+		}
+		settings, err := json.Marshal(&Settings{NumThreads: u.NumThreads, MaxErrors: u.MaxErrors, Wetrun: u.Wetrun, DoShuffles: u.DoShuffles})
+		if err != nil {
+			return fmt.Errorf("marshalling settings: %w", err)
+		}
+		if err := meta.Put(settingsKey, settings); err != nil {
+			return err
+		}
+		return meta.Put(migratedKey, []byte(path))
+	})
+	if err != nil {
+		return false, fmt.Errorf("importing %s: %w", path, err)
+	}
+	if err := os.Rename(path, path+".imported"); err != nil {
+		return false, fmt.Errorf("renaming %s after importing it: %w", path, err)
+	}
+	return true, nil
+}
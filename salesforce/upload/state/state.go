@@ -0,0 +1,211 @@
+// Package state is the uploader's persistent record of which records have
+// been uploaded (and as what Salesforce ID), and which failed and why. It's
+// kept in an embedded bbolt database, so each result is written in its own
+// transaction, and a crash can't leave the state half written.
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Silicon-Ally/etap2sf/salesforce"
+	bolt "go.etcd.io/bbolt"
+)
+
+type Status string
+
+const (
+	Status_Succeeded Status = "succeeded"
+	Status_Failed    Status = "failed"
+)
+
+// Record is the upload state of a single record, keyed by its external key.
+type Record struct {
+	Ref          string
+	ObjectType   salesforce.ObjectType `json:",omitempty"`
+	Status       Status
+	SalesforceID string `json:",omitempty"`
+	// Hash is a hash of the fields that the record was last uploaded with.
+	Hash string `json:",omitempty"`
+	// Error is the most recent error, if the record failed. If it was an error
+	// that Salesforce returned for the record, its status code and the fields
+	// it was about are in ErrorCode and ErrorFields.
+	Error       string   `json:",omitempty"`
+	ErrorCode   string   `json:",omitempty"`
+	ErrorFields []string `json:",omitempty"`
+	Attempts    int
+	UpdatedAt   time.Time
+}
+
+// Settings are the uploader settings that persist between runs.
+type Settings struct {
+	NumThreads int
+	MaxErrors  int
+	Wetrun     bool
+	DoShuffles bool
+}
+
+var (
+	recordsBucket = []byte("records")
+	metaBucket    = []byte("meta")
+	settingsKey   = []byte("settings")
+	migratedKey   = []byte("migrated-from")
+)
+
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens (or creates) the store at the given path.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening upload state at %s (is another upload running?): %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{recordsBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return fmt.Errorf("creating bucket %s: %w", b, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// RecordSuccess records that the record with the given ref was uploaded as
+// the given ID, with fields that hash to the given hash (if it's known).
+func (s *Store) RecordSuccess(ref string, sot salesforce.ObjectType, id, hash string) error {
+	return s.update(ref, func(r *Record) {
+		r.Status = Status_Succeeded
+		r.SalesforceID = id
+		r.Hash = hash
+		if sot != "" {
+			r.ObjectType = sot
+		}
+		r.Error = ""
+		r.ErrorCode = ""
+		r.ErrorFields = nil
+	})
+}
+
+// RecordFailure records that uploading the record with the given ref failed.
+func (s *Store) RecordFailure(ref string, sot salesforce.ObjectType, uploadErr error) error {
+	return s.update(ref, func(r *Record) {
+		r.Status = Status_Failed
+		if sot != "" {
+			r.ObjectType = sot
+		}
+		r.Error = ""
+		r.ErrorCode = ""
+		r.ErrorFields = nil
+		if uploadErr != nil {
+			r.Error = uploadErr.Error()
+		}
+		if re, ok := salesforce.AsRecordError(uploadErr); ok {
+			r.ErrorCode = re.StatusCode
+			r.ErrorFields = re.Fields
+		}
+	})
+}
+
+func (s *Store) update(ref string, fn func(r *Record)) error {
+	// Batch combines the writes from concurrent uploader threads into fewer
+	// transactions. If it has to retry, the earlier attempt was rolled back.
+	return s.db.Batch(func(tx *bolt.Tx) error {
+		b := tx.Bucket(recordsBucket)
+		r := &Record{Ref: ref}
+		if data := b.Get([]byte(ref)); data != nil {
+			if err := json.Unmarshal(data, r); err != nil {
+				return fmt.Errorf("unmarshalling state of %s: %w", ref, err)
+			}
+		}
+		fn(r)
+		r.Attempts++
+		r.UpdatedAt = time.Now()
+		data, err := json.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("marshalling state of %s: %w", ref, err)
+		}
+		return b.Put([]byte(ref), data)
+	})
+}
+
+// Records calls fn with every record in the store.
+func (s *Store) Records(fn func(r *Record) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(recordsBucket).ForEach(func(k, v []byte) error {
+			r := &Record{}
+			if err := json.Unmarshal(v, r); err != nil {
+				return fmt.Errorf("unmarshalling state of %s: %w", k, err)
+			}
+			return fn(r)
+		})
+	})
+}
+
+// Get returns the state of the record with the given ref, or nil if it's never been uploaded.
+func (s *Store) Get(ref string) (*Record, error) {
+	var r *Record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(recordsBucket).Get([]byte(ref))
+		if data == nil {
+			return nil
+		}
+		r = &Record{}
+		return json.Unmarshal(data, r)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting state of %s: %w", ref, err)
+	}
+	return r, nil
+}
+
+// Delete removes the records with the given refs, i.e. once they've been deleted from Salesforce.
+func (s *Store) Delete(refs []string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(recordsBucket)
+		for _, ref := range refs {
+			if err := b.Delete([]byte(ref)); err != nil {
+				return fmt.Errorf("deleting state of %s: %w", ref, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Settings returns the saved settings, or nil if there aren't any yet.
+func (s *Store) Settings() (*Settings, error) {
+	var settings *Settings
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(metaBucket).Get(settingsKey)
+		if data == nil {
+			return nil
+		}
+		settings = &Settings{}
+		return json.Unmarshal(data, settings)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading settings: %w", err)
+	}
+	return settings, nil
+}
+
+func (s *Store) SaveSettings(settings *Settings) error {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("marshalling settings: %w", err)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metaBucket).Put(settingsKey, data)
+	})
+}
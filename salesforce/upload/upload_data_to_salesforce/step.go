@@ -2,12 +2,37 @@ package upload_data_to_salesforce
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/Silicon-Ally/etap2sf/conv/conversion"
+	"github.com/Silicon-Ally/etap2sf/etap/generated/overrides"
+	"github.com/Silicon-Ally/etap2sf/logging"
 	"github.com/Silicon-Ally/etap2sf/salesforce/upload"
 )
 
+// Filters select which records a selective upload re-uploads.
+type Filters struct {
+	upload.Selection
+	// From and To select the records converted from journal entries dated
+	// between them, inclusive. Either can be zero.
+	From, To time.Time
+}
+
+func (f *Filters) byDate() bool {
+	return !f.From.IsZero() || !f.To.IsZero()
+}
+
 func Run(partial bool) error {
+	return RunSelected(partial, nil)
+}
+
+// RunSelected uploads only the records that the filters select, or
+// everything if they're nil.
+func RunSelected(partial bool, filters *Filters) error {
+	// This is set up first, so that an interruption before the upload starts
+	// stops it before the NPSP triggers are disabled, or has them restored.
+	ctx, stop := upload.InterruptibleContext()
+	defer stop()
 	input, err := conversion.GetInput()
 	if err != nil {
 		return fmt.Errorf("failed to get input: %v", err)
@@ -15,17 +40,68 @@ func Run(partial bool) error {
 	if partial {
 		input = input.Sample()
 	}
+	var selection *upload.Selection
+	if filters != nil {
+		selection = &filters.Selection
+		if filters.byDate() {
+			refs := journalEntryRefsBetween(input.JournalEntries, filters.From, filters.To)
+			if selection.Refs == nil {
+				selection.Refs = refs
+			} else {
+				// Both were given, so only the refs in the file that are also in the range are selected.
+				for ref := range selection.Refs {
+					if !refs[ref] {
+						delete(selection.Refs, ref)
+					}
+				}
+			}
+		}
+		logging.For("upload").Info("selective upload", "object_types", fmt.Sprint(selection.ObjectTypes), "refs", len(selection.Refs), "hard", selection.Hard)
+	}
 	output, err := input.Convert()
 	if err != nil {
 		return fmt.Errorf("failed to convert to output: %v", err)
 	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("interrupted before uploading: %w", err)
+	}
 	doShuffles := !partial
 	uploader, err := upload.GetOrCreateUploader(doShuffles)
 	if err != nil {
 		return fmt.Errorf("getting or creating uploader: %w", err)
 	}
-	if err := uploader.Upload(output); err != nil {
+	if selection != nil {
+		uploader.Select(selection)
+	}
+	if err := uploader.Upload(ctx, output); err != nil {
 		return fmt.Errorf("uploading: %w", err)
 	}
 	return nil
 }
+
+// journalEntryRefsBetween returns the refs of the journal entries dated
+// between from and to, inclusive, ignoring the time of day.
+func journalEntryRefsBetween(jes []*overrides.JournalEntry, from, to time.Time) map[string]bool {
+	refs := map[string]bool{}
+	undated := 0
+	for _, je := range jes {
+		d := je.Date()
+		if d == nil || len(*d) < len("2006-01-02") {
+			undated++
+			continue
+		}
+		date, err := time.Parse("2006-01-02", string(*d)[:len("2006-01-02")])
+		if err != nil {
+			undated++
+			continue
+		}
+		if (!from.IsZero() && date.Before(from)) || (!to.IsZero() && date.After(to)) {
+			continue
+		}
+		refs[je.Ref()] = true
+	}
+	if undated > 0 {
+		logging.For("upload").Warn("journal entries without a date can't be selected by date", "count", undated)
+	}
+	return refs
+}
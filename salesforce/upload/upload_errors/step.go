@@ -0,0 +1,184 @@
+// Package upload_errors reports on the records that failed to upload, from the
+// errors kept in the upload state. Failures are grouped by object type,
+// Salesforce status code and field, with a few examples of each, and every
+// failure is exported to a CSV that can be worked through record by record.
+package upload_errors
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Silicon-Ally/etap2sf/salesforce"
+	"github.com/Silicon-Ally/etap2sf/salesforce/profile"
+	"github.com/Silicon-Ally/etap2sf/salesforce/upload"
+	"github.com/Silicon-Ally/etap2sf/salesforce/upload/state"
+)
+
+const examplesPerGroup = 3
+
+// Group is the failures of one object type with the same status code, about the same field.
+type Group struct {
+	ObjectType salesforce.ObjectType
+	StatusCode string
+	Field      string
+	Count      int
+	Examples   []*state.Record
+}
+
+type Report struct {
+	GeneratedAt time.Time
+	Failures    []*state.Record
+	Groups      []*Group
+}
+
+func Run() error {
+	store, err := upload.OpenState()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	report, err := build(store)
+	if err != nil {
+		return err
+	}
+	csvPath, err := profile.DataPath("upload-errors.csv")
+	if err != nil {
+		return err
+	}
+	if err := report.writeCSV(csvPath); err != nil {
+		return err
+	}
+	summaryPath, err := profile.DataPath("upload-errors.txt")
+	if err != nil {
+		return err
+	}
+	summary := report.Summary()
+	if err := os.WriteFile(summaryPath, []byte(summary), 0777); err != nil {
+		return fmt.Errorf("writing summary to %s: %w", summaryPath, err)
+	}
+	fmt.Print(summary)
+	fmt.Printf("\nWrote every failure to %s, and this summary to %s.\n", csvPath, summaryPath)
+	return nil
+}
+
+func build(store *state.Store) (*Report, error) {
+	report := &Report{GeneratedAt: time.Now()}
+	err := store.Records(func(r *state.Record) error {
+		if r.Status == state.Status_Failed {
+			report.Failures = append(report.Failures, r)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading upload state: %w", err)
+	}
+	sort.Slice(report.Failures, func(i, j int) bool {
+		a, b := report.Failures[i], report.Failures[j]
+		if a.ObjectType != b.ObjectType {
+			return a.ObjectType < b.ObjectType
+		}
+		if a.ErrorCode != b.ErrorCode {
+			return a.ErrorCode < b.ErrorCode
+		}
+		return a.Ref < b.Ref
+	})
+
+	type groupKey struct {
+		sot   salesforce.ObjectType
+		code  string
+		field string
+	}
+	groups := map[groupKey]*Group{}
+	for _, r := range report.Failures {
+		code := r.ErrorCode
+		if code == "" {
+			code = "OTHER"
+		}
+		fields := r.ErrorFields
+		if len(fields) == 0 {
+			fields = []string{""}
+		}
+		// A record with an error about several fields is counted once for each.
+		for _, f := range fields {
+			k := groupKey{sot: r.ObjectType, code: code, field: f}
+			g, ok := groups[k]
+			if !ok {
+				g = &Group{ObjectType: r.ObjectType, StatusCode: code, Field: f}
+				groups[k] = g
+				report.Groups = append(report.Groups, g)
+			}
+			g.Count++
+			if len(g.Examples) < examplesPerGroup {
+				g.Examples = append(g.Examples, r)
+			}
+		}
+	}
+	sort.SliceStable(report.Groups, func(i, j int) bool {
+		return report.Groups[i].Count > report.Groups[j].Count
+	})
+	return report, nil
+}
+
+// Summary is the groups of failures, largest first.
+func (r *Report) Summary() string {
+	sb := strings.Builder{}
+	if len(r.Failures) == 0 {
+		sb.WriteString("No records have failed to upload.\n")
+		return sb.String()
+	}
+	fmt.Fprintf(&sb, "%d records failed to upload, in %d groups.\n\n", len(r.Failures), len(r.Groups))
+	fmt.Fprintf(&sb, "%-24s %-36s %-32s %8s\n", "Object Type", "Status Code", "Field", "Count")
+	for _, g := range r.Groups {
+		fmt.Fprintf(&sb, "%-24s %-36s %-32s %8d\n", orNone(string(g.ObjectType)), g.StatusCode, orNone(g.Field), g.Count)
+	}
+	for _, g := range r.Groups {
+		fmt.Fprintf(&sb, "\n%s %s %s (%d):\n", orNone(string(g.ObjectType)), g.StatusCode, orNone(g.Field), g.Count)
+		for _, e := range g.Examples {
+			fmt.Fprintf(&sb, "  %s: %s\n", e.Ref, e.Error)
+		}
+	}
+	return sb.String()
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func (r *Report) writeCSV(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", path, err)
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	if err := w.Write([]string{"Ref", "ObjectType", "StatusCode", "Fields", "Message", "Attempts", "LastAttempt"}); err != nil {
+		return fmt.Errorf("writing csv header: %w", err)
+	}
+	for _, e := range r.Failures {
+		row := []string{
+			e.Ref,
+			string(e.ObjectType),
+			e.ErrorCode,
+			strings.Join(e.ErrorFields, ";"),
+			e.Error,
+			strconv.Itoa(e.Attempts),
+			e.UpdatedAt.Format(time.RFC3339),
+		}
+		if err := w.Write(row); err != nil {
+			return fmt.Errorf("writing csv row for %s: %w", e.Ref, err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
@@ -1,17 +1,22 @@
 package upload
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"log"
-	"os"
-	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 
+	"github.com/Silicon-Ally/etap2sf/config"
 	"github.com/Silicon-Ally/etap2sf/conv/conversion"
+	"github.com/Silicon-Ally/etap2sf/logging"
+	"github.com/Silicon-Ally/etap2sf/salesforce"
+	"github.com/Silicon-Ally/etap2sf/salesforce/apilimits"
 	esfutils "github.com/Silicon-Ally/etap2sf/salesforce/clients/enterprise/utils"
 	"github.com/Silicon-Ally/etap2sf/salesforce/generated/sfenterprise"
+	"github.com/Silicon-Ally/etap2sf/salesforce/profile"
+	"github.com/Silicon-Ally/etap2sf/salesforce/upload/state"
 	"github.com/Silicon-Ally/etap2sf/utils"
 )
 
@@ -32,6 +37,8 @@ type client interface {
 	UpsertAdditionalContext(*sfenterprise.Etap_AdditionalContext__c) (string, error)
 	UpsertContentVersion(*sfenterprise.ContentVersion) (string, error)
 	UpsertContentDocumentLink(*sfenterprise.ContentDocumentLink) (string, error)
+	UpsertHousehold(*sfenterprise.Npo02__Household__c) (string, error)
+	CreateContentNote(*sfenterprise.ContentNote) (string, error)
 	LookupContentDocumentByVersion(sfenterprise.ID) (sfenterprise.ID, error)
 }
 
@@ -45,55 +52,152 @@ type Uploader struct {
 	Wetrun           bool
 	DoShuffles       bool
 	Todo             int
-	name             string
 	stateChangeMutex sync.Mutex
-	anyThreadDead    bool
 	cleanups         []func() error
+	batchClients     map[salesforce.ObjectType]batchClient
+	// graphClient uploads opportunities along with their payments and
+	// allocations. It's nil unless the rest-graph backend is configured.
+	graphClient graphClient
+	// store is where the results are persisted. It's nil for local validation.
+	store *state.Store
+	// selection limits the upload to some of the records. It's nil to upload everything.
+	selection *Selection
+	// Hashes are the hashes of the fields that the succeeded records were
+	// uploaded with, to tell which have changed since.
+	Hashes map[string]string
+	// changes counts the new, changed and unchanged records of each type.
+	changes map[salesforce.ObjectType]*changeCounts
+	// apiGate slows down and then stops the upload as the org nears its daily
+	// API limit. It's nil outside of Upload.
+	apiGate *apiGate
 }
 
-var uploaderMemoPath = filepath.Join(utils.ProjectRoot(), "data", "uploader.json")
+const (
+	uploadStateFile = "upload-state.db"
+	// uploaderMemoFile is where older versions kept their state, which is
+	// imported into the state store.
+	uploaderMemoFile = "uploader.json"
+)
+
+// OpenState opens the store of what's been uploaded to the active profile's
+// org, first importing the state from uploader.json if an older version left
+// one behind. Only one process can have it open at a time.
+func OpenState() (*state.Store, error) {
+	uploadStatePath, err := profile.DataPath(uploadStateFile)
+	if err != nil {
+		return nil, err
+	}
+	uploaderMemoPath, err := profile.DataPath(uploaderMemoFile)
+	if err != nil {
+		return nil, err
+	}
+	store, err := state.Open(uploadStatePath)
+	if err != nil {
+		return nil, err
+	}
+	imported, err := store.ImportJSON(uploaderMemoPath)
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("importing old uploader state: %w", err)
+	}
+	if imported {
+		logging.For("upload").Info("imported uploader state into the state store", "from", uploaderMemoPath, "to", uploadStatePath)
+	}
+	return store, nil
+}
 
 func GetOrCreateUploader(doShuffles bool) (*Uploader, error) {
-	u := &Uploader{}
-	data, err := os.ReadFile(uploaderMemoPath)
-	if os.IsNotExist(err) {
-		u.NumThreads = 1
-		u.MaxErrors = 1
-		u.Failed = map[string]bool{}
-		u.Succeeded = map[string]bool{}
-		u.IDMap = map[string]string{}
-		u.Wetrun = true
-		u.DoShuffles = doShuffles
-	} else if err != nil {
-		return nil, fmt.Errorf("reading uploader from %s: %w", uploaderMemoPath, err)
-	} else {
-		if err := json.Unmarshal(data, u); err != nil {
-			return nil, fmt.Errorf("unmarshalling uploader: %w", err)
-		}
+	if err := profile.ConfirmWrite("upload records"); err != nil {
+		return nil, err
+	}
+	u := &Uploader{
+		NumThreads: 1,
+		MaxErrors:  1,
+		Failed:     map[string]bool{},
+		Succeeded:  map[string]bool{},
+		IDMap:      map[string]string{},
+		Hashes:     map[string]string{},
+		Wetrun:     true,
+		DoShuffles: doShuffles,
+	}
+	store, err := OpenState()
+	if err != nil {
+		return nil, err
+	}
+	if err := u.loadState(store); err != nil {
+		store.Close()
+		return nil, err
 	}
+	u.store = store
+	u.cleanups = append(u.cleanups, store.Close)
 	client, err := esfutils.NewSandboxClient()
 	if err != nil {
+		cleanup(u)
 		return nil, fmt.Errorf("creating client: %w", err)
 	}
+	if err := offerToRestoreNPSPTriggers(client); err != nil {
+		cleanup(u)
+		return nil, fmt.Errorf("checking for npsp triggers left disabled: %w", err)
+	}
 	undoFn, err := client.DisableNPSPRelationshipTriggers()
 	if err != nil {
+		cleanup(u)
 		return nil, fmt.Errorf("disabling npsp triggers: %w", err)
 	}
-	u.cleanups = append(u.cleanups, undoFn)
+	// The triggers are restored before the store is closed.
+	u.cleanups = append([]func() error{undoFn}, u.cleanups...)
 	u.client = client
+	if err := u.setUpBackends(client); err != nil {
+		cleanup(u)
+		return nil, err
+	}
 	return u, nil
 }
 
+// loadState reads the settings and results of previous runs from the store.
+func (u *Uploader) loadState(store *state.Store) error {
+	settings, err := store.Settings()
+	if err != nil {
+		return err
+	}
+	if settings != nil {
+		u.NumThreads = settings.NumThreads
+		u.MaxErrors = settings.MaxErrors
+		u.Wetrun = settings.Wetrun
+		u.DoShuffles = settings.DoShuffles
+	}
+	if c := config.Get().Upload; c.NumThreads > 0 {
+		u.NumThreads = c.NumThreads
+	}
+	if c := config.Get().Upload; c.MaxErrors > 0 {
+		u.MaxErrors = c.MaxErrors
+	}
+	return store.Records(func(r *state.Record) error {
+		switch r.Status {
+		case state.Status_Succeeded:
+			u.Succeeded[r.Ref] = true
+			u.IDMap[r.Ref] = r.SalesforceID
+			if r.Hash != "" {
+				u.Hashes[r.Ref] = r.Hash
+			}
+		case state.Status_Failed:
+			u.Failed[r.Ref] = true
+		}
+		return nil
+	})
+}
+
+// save persists the uploader's settings. The result of each record is
+// persisted as soon as it's known, in succeeded and failed.
 func save(u *Uploader) error {
-	if !u.Wetrun {
+	if !u.Wetrun || u.store == nil {
 		return nil
 	}
-	data, err := json.MarshalIndent(u, "", "  ")
-	if err != nil {
-		return fmt.Errorf("marshalling uploader: %w", err)
-	}
-	if err := os.WriteFile(uploaderMemoPath, data, 0777); err != nil {
-		return fmt.Errorf("writing uploader to %s: %w", uploaderMemoPath, err)
+	u.stateChangeMutex.Lock()
+	settings := &state.Settings{NumThreads: u.NumThreads, MaxErrors: u.MaxErrors, Wetrun: u.Wetrun, DoShuffles: u.DoShuffles}
+	u.stateChangeMutex.Unlock()
+	if err := u.store.SaveSettings(settings); err != nil {
+		return fmt.Errorf("saving uploader settings: %w", err)
 	}
 	return nil
 }
@@ -101,109 +205,117 @@ func save(u *Uploader) error {
 func cleanup(u *Uploader) {
 	for _, fn := range u.cleanups {
 		if err := fn(); err != nil {
-			fmt.Printf("error cleaning up: %v", err)
+			logging.For("upload").Error("failed to clean up", "error", err)
 		}
 	}
 }
 
-func (u *Uploader) Upload(output *conversion.Output) error {
+func (u *Uploader) Upload(ctx context.Context, output *conversion.Output) error {
 	defer cleanup(u)
 	defer func() {
 		if err := save(u); err != nil {
-			log.Printf("failed to save output: %v", err)
+			logging.For("upload").Error("failed to save output", "error", err)
 		}
 	}()
-	if err := u.uploadCampaigns(output); err != nil {
-		return fmt.Errorf("uploading campaigns: %w", err)
-	}
-	if err := u.uploadGAUs(output); err != nil {
-		return fmt.Errorf("uploading gaus: %w", err)
-	}
-	if err := u.uploadAccounts(output); err != nil {
-		return fmt.Errorf("uploading accounts: %w", err)
-	}
-	if err := handleErrors(output.ReplaceAllIDsInContacts(u.IDMap)); err != nil {
-		return fmt.Errorf("replacing contact ids: %w", err)
-	}
-	if err := u.uploadContacts(output); err != nil {
-		return fmt.Errorf("uploading contacts: %w", err)
-	}
-	if err := handleErrors(output.ReplaceAllIDsInRelationships(u.IDMap)); err != nil {
-		return fmt.Errorf("replacing relationship ids: %w", err)
-	}
-	if err := handleErrors(output.ReplaceAllIDsInAffiliations(u.IDMap)); err != nil {
-		return fmt.Errorf("replacing affiliation ids: %w", err)
-	}
-	if err := u.uploadRelationships(output); err != nil {
-		return fmt.Errorf("uploading relationships: %w", err)
-	}
-	if err := u.uploadAffiliations(output); err != nil {
-		return fmt.Errorf("uploading affiliations: %w", err)
-	}
-	if err := handleErrors(output.ReplaceAllIDsInRecurringDonations(u.IDMap)); err != nil {
-		return fmt.Errorf("replacing recurring donation ids: %w", err)
-	}
-	if err := u.uploadRecurringDonations(output); err != nil {
-		return fmt.Errorf("uploading recurring donations: %w", err)
-	}
-	if err := handleErrors(output.ReplaceAllIDsInOpportunities(u.IDMap)); err != nil {
-		return fmt.Errorf("replacing opportunity ids: %w", err)
-	}
-	if err := u.uploadOpportunities(output); err != nil {
-		return fmt.Errorf("uploading opportunities: %w", err)
-	}
-	if err := handleErrors(output.ReplaceAllIDsInPayments(u.IDMap)); err != nil {
-		return fmt.Errorf("replacing payment ids: %w", err)
-	}
-	if err := u.uploadPayments(output); err != nil {
-		return fmt.Errorf("uploading payments: %w", err)
-	}
-	if err := handleErrors(output.ReplaceAllIDsInGAUAllocations(u.IDMap)); err != nil {
-		return fmt.Errorf("replacing gau allocation ids: %w", err)
-	}
-	if err := u.uploadGAUAllocations(output); err != nil {
-		return fmt.Errorf("uploading gau allocations: %w", err)
-	}
-	if err := handleErrors(output.ReplaceAllIDsInPartialSoftCredits(u.IDMap)); err != nil {
-		return fmt.Errorf("replacing partial soft credit ids: %w", err)
-	}
-	if err := u.uploadPartialSoftCredits(output); err != nil {
-		return fmt.Errorf("uploading partial soft credits: %w", err)
-	}
-	if err := handleErrors(output.ReplaceAllIDsInAccountSoftCredits(u.IDMap)); err != nil {
-		return fmt.Errorf("replacing account soft credit ids: %w", err)
-	}
-	if err := u.uploadAccountSoftCredits(output); err != nil {
-		return fmt.Errorf("uploading account soft credits: %w", err)
-	}
-	if err := u.uploadAdditionalContexts(output); err != nil {
-		return fmt.Errorf("uploading additional contexts: %w", err)
-	}
-	if err := handleErrors(output.ReplaceAllIDsInTasks(u.IDMap)); err != nil {
-		return fmt.Errorf("replacing task ids: %w", err)
-	}
-	if err := u.uploadTasks(output); err != nil {
-		return fmt.Errorf("uploading tasks: %w", err)
-	}
-	if err := u.uploadContentVersions(output); err != nil {
-		return fmt.Errorf("uploading content versions: %w", err)
-	}
-	if err := handleErrors(output.ReplaceAllIDsInContentDocumentLinks(u.IDMap)); err != nil {
-		return fmt.Errorf("replacing content document link ids: %w", err)
-	}
-	if err := u.lookupContentDocumentIDsFromContentVersionIDs(output); err != nil {
-		return fmt.Errorf("looking up content document ids from content version ids: %w", err)
+	u.stateChangeMutex.Lock()
+	u.Todo = 0
+	u.stateChangeMutex.Unlock()
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	u.apiGate = newAPIGate(cancel)
+	if addr := config.Get().Upload.APILimits.MetricsAddr; addr != "" {
+		apilimits.Serve(addr)
+		logging.For("upload").Info("serving api usage metrics", "addr", addr)
+	}
+	if err := u.checkAPIUsage(); err != nil {
+		return err
+	}
+	if u.selection != nil && u.selection.Refs != nil {
+		for _, p := range phases {
+			if p.filter != nil {
+				p.filter(output, u.selection.includesKey)
+			}
+		}
 	}
-	if err := u.uploadContentDocumentLinks(output); err != nil {
-		return fmt.Errorf("uploading content document links: %w", err)
+	err := u.runPhases(ctx, output, phases)
+	fmt.Print(u.changeSummary())
+	if err != nil {
+		return err
 	}
 	fmt.Printf("Your upload has completed successfully. Congratulations!")
 	return nil
 }
 
-func runErrorsOnly[T any](u *Uploader, ts []T, idFn func(t T) string, fn func(t T) (string, error)) error {
-	u.anyThreadDead = false
+// runState is the state of one pass over the records of a single type. Phases
+// that don't depend on each other run at the same time, each with their own.
+type runState struct {
+	name string
+	// objectType is empty if the run doesn't upload records.
+	objectType    salesforce.ObjectType
+	progress      *logging.Progress
+	anyThreadDead atomic.Bool
+	// limiter adapts how many threads are uploading to the lock contention
+	// they run into, and maxRetries is how often a retriable error is retried.
+	limiter    *limiter
+	maxRetries int
+	// hashes are the hashes of the records' fields, which are stored with
+	// them when they're uploaded, and resend are the records which were
+	// uploaded before, but have changed since.
+	hashes map[string]string
+	resend map[string]bool
+	// perBatch and callsPerBatch are how many records go in each call (or
+	// batch of calls), and remaining is how many records are left, which
+	// together estimate the calls left in the run.
+	perBatch      int
+	callsPerBatch int
+	remaining     atomic.Int64
+	// persistErr is the first result that couldn't be persisted. The record
+	// may be in Salesforce without the store knowing, so the run stops.
+	persistMu  sync.Mutex
+	persistErr error
+}
+
+// stopOnPersistError stops the run's threads, as if one had died, and keeps
+// err for the run to return.
+func (rs *runState) stopOnPersistError(err error) {
+	rs.persistMu.Lock()
+	if rs.persistErr == nil {
+		rs.persistErr = err
+	}
+	rs.persistMu.Unlock()
+	rs.anyThreadDead.Store(true)
+}
+
+func (rs *runState) persistError() error {
+	rs.persistMu.Lock()
+	defer rs.persistMu.Unlock()
+	return rs.persistErr
+}
+
+func (u *Uploader) newRunState(name string, sot salesforce.ObjectType, todo, perBatch, callsPerBatch int) *runState {
+	u.stateChangeMutex.Lock()
+	u.Todo += todo
+	u.stateChangeMutex.Unlock()
+	rs := &runState{
+		name:          name,
+		objectType:    sot,
+		limiter:       newLimiter(name, u.NumThreads),
+		maxRetries:    maxRetriesFromConfig(),
+		perBatch:      perBatch,
+		callsPerBatch: callsPerBatch,
+	}
+	rs.remaining.Store(int64(todo))
+	if u.Wetrun {
+		rs.progress = logging.NewProgress("upload", name, todo)
+		u.reportEstimate(rs, todo)
+	}
+	return rs
+}
+
+func runErrorsOnly[T any](ctx context.Context, u *Uploader, rs *runState, ts []T, idFn func(t T) string, fn func(t T) (string, error)) error {
+	rs.anyThreadDead.Store(false)
 	retained := []T{}
+	u.stateChangeMutex.Lock()
 	for _, t := range ts {
 		tt := t
 		id := idFn(t)
@@ -211,30 +323,37 @@ func runErrorsOnly[T any](u *Uploader, ts []T, idFn func(t T) string, fn func(t
 			retained = append(retained, tt)
 		}
 	}
+	u.stateChangeMutex.Unlock()
 	if len(retained) == 0 {
 		return fmt.Errorf("no errors to run errors only on")
 	}
 	for i, t := range retained {
+		if err := ctx.Err(); err != nil {
+			return context.Cause(ctx)
+		}
 		tt := t
-		fmt.Printf("RUNNING ERRORS ONLY %d/%d\n", i, len(retained))
-		if errs := runThread(u, []T{tt}, idFn, fn, false); len(errs) > 0 {
+		logging.For("upload").Info("retrying failed record on its own", "object_type", rs.name, "index", i, "total", len(retained))
+		errs := runThread(ctx, u, rs, []T{tt}, idFn, fn, false)
+		if err := rs.persistError(); err != nil {
+			return err
+		}
+		if len(errs) > 0 {
 			return fmt.Errorf("running errors only: %w", handleErrors(errs))
 		}
 	}
 	return nil
 }
 
-func run[T any](u *Uploader, ts []T, idFn func(t T) string, fn func(t T) (string, error), hard bool) error {
-	u.anyThreadDead = false
+func run[T any](ctx context.Context, u *Uploader, sot salesforce.ObjectType, ts []T, idFn func(t T) string, fn func(t T) (string, error), hard bool) error {
 	defer func() {
 		if r := recover(); r != nil {
 			if err := save(u); err != nil {
-				log.Printf("failed to save output: %v", err)
+				logging.For("upload").Error("failed to save output", "error", err)
 			}
 			panic(r)
 		} else {
 			if err := save(u); err != nil {
-				log.Printf("failed to save output: %v", err)
+				logging.For("upload").Error("failed to save output", "error", err)
 			}
 		}
 	}()
@@ -246,57 +365,104 @@ func run[T any](u *Uploader, ts []T, idFn func(t T) string, fn func(t T) (string
 		// because opportunities are looked up per-user.
 		utils.Shuffle(ts)
 	}
-	u.name = fmt.Sprintf("%T", ts[0])
-	retained := []T{}
-	for _, t := range ts {
-		id := idFn(t)
-		if !u.Succeeded[id] || hard {
-			retained = append(retained, t)
+	for pass := 0; ; pass++ {
+		u.stateChangeMutex.Lock()
+		retained, hashes, resend, counts := classify(u, ts, idFn, hard)
+		failed := map[string]bool{}
+		for _, t := range retained {
+			id := idFn(t)
+			failed[id] = u.Failed[id]
 		}
-	}
-	sort.Slice(retained, func(i, j int) bool {
-		idI := idFn(retained[i])
-		idJ := idFn(retained[j])
-		if u.Failed[idI] != u.Failed[idJ] {
-			return u.Failed[idI] && !u.Failed[idJ]
+		u.stateChangeMutex.Unlock()
+		if pass == 0 {
+			u.addChangeCounts(sot, fmt.Sprintf("%T", ts[0]), counts)
 		}
-		// We don't have a stable sort for a reason - our entity keys are highly correlated
-		// so inserting objects (like relationships) can cause a lot of lock contention if
-		// doing so multi-threaded in a stable way.
-		return false
-	})
-	u.Todo = len(retained)
+		if len(retained) == 0 {
+			return nil
+		}
+		sort.Slice(retained, func(i, j int) bool {
+			idI := idFn(retained[i])
+			idJ := idFn(retained[j])
+			if failed[idI] != failed[idJ] {
+				return failed[idI] && !failed[idJ]
+			}
+			// We don't have a stable sort for a reason - our entity keys are highly correlated
+			// so inserting objects (like relationships) can cause a lot of lock contention if
+			// doing so multi-threaded in a stable way.
+			return false
+		})
+		rs := u.newRunState(fmt.Sprintf("%T", ts[0]), sot, len(retained), 1, 1)
+		rs.hashes = hashes
+		rs.resend = resend
 
-	split := splitByNumThreads(retained, u.NumThreads)
-	errorsChan := make(chan []error)
-	for _, ts := range split {
-		go func(ts []T) {
-			errorsChan <- runThread(u, ts, idFn, fn, hard)
-		}(ts)
-	}
-	errors := []error{}
-	for range split {
-		errors = append(errors, <-errorsChan...)
-	}
-	if len(errors) > 0 {
-		if err := runErrorsOnly(u, retained, idFn, fn); err != nil {
-			return fmt.Errorf("running errors only: %w", err)
+		split := splitByNumThreads(retained, u.NumThreads)
+		errorsChan := make(chan []error)
+		for _, ts := range split {
+			go func(ts []T) {
+				errorsChan <- runThread(ctx, u, rs, ts, idFn, fn, hard)
+			}(ts)
+		}
+		errors := []error{}
+		for range split {
+			errors = append(errors, <-errorsChan...)
+		}
+		if err := ctx.Err(); err != nil {
+			// The workers have finished the records they were on, and the deferred
+			// save records what they got through.
+			rs.progress.Done()
+			return fmt.Errorf("interrupted with %d errors: %w", len(errors), context.Cause(ctx))
+		}
+		if err := rs.persistError(); err != nil {
+			rs.progress.Done()
+			return err
+		}
+		if len(errors) > 0 {
+			if err := runErrorsOnly(ctx, u, rs, retained, idFn, fn); err != nil {
+				return fmt.Errorf("running errors only: %w", err)
+			}
+			// Another pass over what's left, now that the errors are resolved.
+			ts = retained
+			continue
 		}
-		return run(u, retained, idFn, fn, hard)
+		rs.progress.Done()
+		logging.For("upload").Info("done with object type", "object_type", rs.name, "retained", len(retained), "errors", len(errors))
+		return handleErrors(errors)
 	}
-	fmt.Printf("Done with %s (%d retained, resulted in %d errors)\n", u.name, len(retained), len(errors))
-	return handleErrors(errors)
 }
 
+// handleErrors combines the errors from a step into one, counting them by
+// Salesforce status code. The error for each record is kept in the upload
+// state, which `etap2sf upload-errors` reports on.
 func handleErrors(errors []error) error {
-	if len(errors) > 0 {
-		filePath, _ := utils.WriteErrorsToTempFile(errors, "upload-errors")
-		return fmt.Errorf("step yielded %d errors:\n\tall errors: %s\n\tfirst error: %w", len(errors), filePath, errors[0])
+	if len(errors) == 0 {
+		return nil
 	}
-	return nil
+	byCode := map[string]int{}
+	for _, err := range errors {
+		code := "OTHER"
+		if re, ok := salesforce.AsRecordError(err); ok {
+			code = re.StatusCode
+		}
+		byCode[code]++
+	}
+	codes := make([]string, 0, len(byCode))
+	for code := range byCode {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		if byCode[codes[i]] != byCode[codes[j]] {
+			return byCode[codes[i]] > byCode[codes[j]]
+		}
+		return codes[i] < codes[j]
+	})
+	counts := make([]string, len(codes))
+	for i, code := range codes {
+		counts[i] = fmt.Sprintf("%s: %d", code, byCode[code])
+	}
+	return fmt.Errorf("step yielded %d errors (%s) - run `etap2sf upload-errors` for a report\n\tfirst error: %w", len(errors), strings.Join(counts, ", "), errors[0])
 }
 
-func runThread[T any](u *Uploader, ts []T, idFn func(t T) string, fn func(t T) (string, error), hard bool) []error {
+func runThread[T any](ctx context.Context, u *Uploader, rs *runState, ts []T, idFn func(t T) string, fn func(t T) (string, error), hard bool) []error {
 	maxErrorsPerThread := u.MaxErrors / u.NumThreads
 	if maxErrorsPerThread < 1 {
 		return []error{fmt.Errorf("max errors per thread must be at least 1")}
@@ -304,67 +470,90 @@ func runThread[T any](u *Uploader, ts []T, idFn func(t T) string, fn func(t T) (
 	errors := []error{}
 	for _, t := range ts {
 		if len(errors) >= maxErrorsPerThread {
-			u.anyThreadDead = true
+			rs.anyThreadDead.Store(true)
 			return errors
 		}
-		if u.anyThreadDead {
+		if rs.anyThreadDead.Load() || ctx.Err() != nil {
 			return errors
 		}
 		id := idFn(t)
 		u.stateChangeMutex.Lock()
 		dupe, ok := u.IDMap[id]
 		u.stateChangeMutex.Unlock()
-		if !hard {
+		if !hard && !rs.resend[id] {
 			if ok {
-				errors = append(errors, fmt.Errorf("duplicate ref %s (already uploaded as %s)", id, dupe))
-				u.failed(idFn(t))
+				err := fmt.Errorf("duplicate ref %s (already uploaded as %s)", id, dupe)
+				errors = append(errors, err)
+				u.failed(rs, idFn(t), err)
 			}
 		}
-		resultID, err := fn(t)
+		if !u.apiGate.wait(ctx) || !rs.limiter.acquire(ctx) {
+			return errors
+		}
+		resultID, err := withRetries(ctx, rs, rs.maxRetries, func() (string, error) { return fn(t) })
+		rs.limiter.release()
 		if err != nil {
 			errors = append(errors, err)
-			u.failed(idFn(t))
+			u.failed(rs, idFn(t), err)
 		} else {
-			u.succeeded(idFn(t), resultID)
+			u.succeeded(rs, idFn(t), resultID)
 		}
 	}
 	return errors
 }
 
-func (u *Uploader) succeeded(id, resultID string) {
+func (u *Uploader) succeeded(rs *runState, id, resultID string) {
+	u.succeededWithHash(rs, id, resultID, rs.hashes[id])
+}
+
+// succeededWithHash records a success with the given hash, for records whose
+// hash can't be worked out until they've been uploaded.
+func (u *Uploader) succeededWithHash(rs *runState, id, resultID, hash string) {
+	rs.progress.Succeeded()
+	rs.recordDone()
 	u.stateChangeMutex.Lock()
-	defer u.stateChangeMutex.Unlock()
 	u.Succeeded[id] = true
 	delete(u.Failed, id)
 	u.Todo--
 	u.IDMap[id] = resultID
-	u.report()
-	if len(u.Succeeded)%25 != 0 {
-		return
+	if hash != "" {
+		u.Hashes[id] = hash
 	}
-	if err := save(u); err != nil {
-		log.Printf("failed to save uploader: %v", err)
+	u.stateChangeMutex.Unlock()
+	if err := u.persist(id, func(s *state.Store) error { return s.RecordSuccess(id, rs.objectType, resultID, hash) }); err != nil {
+		rs.stopOnPersistError(err)
 	}
 }
 
-func (u *Uploader) failed(id string) {
+func (u *Uploader) failed(rs *runState, id string, err error) {
+	rs.progress.Failed()
+	rs.recordDone()
 	u.stateChangeMutex.Lock()
-	defer u.stateChangeMutex.Unlock()
 	u.Failed[id] = true
 	delete(u.Succeeded, id)
 	u.Todo--
-	u.report()
+	u.stateChangeMutex.Unlock()
+	if err := u.persist(id, func(s *state.Store) error { return s.RecordFailure(id, rs.objectType, err) }); err != nil {
+		rs.stopOnPersistError(err)
+	}
 }
 
-func (u *Uploader) report() {
-	if u.Wetrun {
-		fmt.Printf("%s: Success %d, Failed %d, Remaining %d\n", u.name, len(u.Succeeded), len(u.Failed), u.Todo)
+// persist records the result of a record in the store, as soon as it's known.
+func (u *Uploader) persist(id string, fn func(s *state.Store) error) error {
+	if !u.Wetrun || u.store == nil {
+		return nil
 	}
+	if err := fn(u.store); err != nil {
+		return fmt.Errorf("persisting upload state for %s: %w", id, err)
+	}
+	return nil
 }
 
-func (u *Uploader) lookupContentDocumentIDsFromContentVersionIDs(output *conversion.Output) error {
+func (u *Uploader) lookupContentDocumentIDsFromContentVersionIDs(ctx context.Context, output *conversion.Output) error {
 	return run(
+		ctx,
 		u,
+		"",
 		output.ContentDocumentLinks,
 		func(c *sfenterprise.ContentDocumentLink) string { return string(*c.ContentDocumentId) + "-CDL" },
 		func(cdl *sfenterprise.ContentDocumentLink) (string, error) {
@@ -390,135 +579,3 @@ func splitByNumThreads[T any](ts []T, n int) [][]T {
 	}
 	return result
 }
-
-func (u *Uploader) uploadCampaigns(output *conversion.Output) error {
-	return run(
-		u,
-		output.Campaigns,
-		func(c *sfenterprise.Campaign) string { return *c.Etap_MultiObject_EtapRef__c },
-		u.client.UpsertCampaign,
-		false)
-}
-func (u *Uploader) uploadGAUs(output *conversion.Output) error {
-	return run(
-		u,
-		output.GeneralAccountingUnits,
-		func(c *sfenterprise.Npsp__General_Accounting_Unit__c) string { return *c.Etap_Fund_Ref__c },
-		u.client.UpsertGeneralAccountingUnit,
-		false)
-}
-func (u *Uploader) uploadAccounts(output *conversion.Output) error {
-	return run(
-		u,
-		output.Accounts,
-		func(a *sfenterprise.Account) string { return *a.Etap_MultiObject_EtapRef__c },
-		u.client.UpsertAccount,
-		false)
-}
-func (u *Uploader) uploadContacts(output *conversion.Output) error {
-	return run(
-		u,
-		output.Contacts,
-		func(a *sfenterprise.Contact) string { return *a.Etap_Account_Ref__c },
-		u.client.UpsertContact,
-		false)
-}
-func (u *Uploader) uploadRelationships(output *conversion.Output) error {
-	return run(
-		u,
-		output.Relationships,
-		// No longer need differentiation here - we add a 1-of-2 2-of-2 suffix to differentiate
-		func(a *sfenterprise.Npe4__Relationship__c) string { return *a.Etap_Relationship_Ref__c },
-		u.client.UpsertRelationship,
-		false)
-}
-func (u *Uploader) uploadOpportunities(output *conversion.Output) error {
-	return run(
-		u,
-		output.Opportunities,
-		func(a *sfenterprise.Opportunity) string { return *a.Etap_MultiObject_EtapRef__c },
-		u.client.UpsertOpportunity,
-		false)
-}
-func (u *Uploader) uploadAffiliations(output *conversion.Output) error {
-	return run(
-		u,
-		output.Affiliations,
-		func(a *sfenterprise.Npe5__Affiliation__c) string { return *a.Etap_Relationship_Ref__c },
-		u.client.UpsertAffiliation,
-		false)
-}
-func (u *Uploader) uploadRecurringDonations(output *conversion.Output) error {
-	return run(
-		u,
-		output.RecurringDonations,
-		func(a *sfenterprise.Npe03__Recurring_Donation__c) string { return *a.Etap_RecurringGiftSchedule_Ref__c },
-		u.client.UpsertRecurringDonation,
-		false)
-}
-func (u *Uploader) uploadPayments(output *conversion.Output) error {
-	return run(
-		u,
-		output.Payments,
-		func(a *sfenterprise.Npe01__OppPayment__c) string { return *a.Etap_Payment_Ref__c },
-		u.client.UpsertPayment,
-		false)
-}
-func (u *Uploader) uploadGAUAllocations(output *conversion.Output) error {
-	return run(
-		u,
-		output.GAUAllocations,
-		func(a *sfenterprise.Npsp__Allocation__c) string { return *a.Etap_MultiObject_EtapRef__c },
-		u.client.UpsertGAUAllocation,
-		false)
-}
-func (u *Uploader) uploadTasks(output *conversion.Output) error {
-	return run(
-		u,
-		output.Tasks,
-		func(t *sfenterprise.Task) string { return *t.Etap_MultiObject_EtapRef__c },
-		u.client.UpsertTask,
-		false)
-}
-func (u *Uploader) uploadPartialSoftCredits(output *conversion.Output) error {
-	return run(
-		u,
-		output.PartialSoftCredits,
-		func(t *sfenterprise.Npsp__Partial_Soft_Credit__c) string { return *t.Etap_SoftCredit_Ref__c },
-		u.client.UpsertPartialSoftCredit,
-		false)
-}
-func (u *Uploader) uploadAccountSoftCredits(output *conversion.Output) error {
-	return run(
-		u,
-		output.AccountSoftCredits,
-		func(t *sfenterprise.Npsp__Account_Soft_Credit__c) string { return *t.Etap_SoftCredit_Ref__c },
-		u.client.UpsertAccountSoftCredit,
-		false)
-}
-func (u *Uploader) uploadAdditionalContexts(output *conversion.Output) error {
-	return run(
-		u,
-		output.AdditionalContexts,
-		func(t *sfenterprise.Etap_AdditionalContext__c) string { return *t.Name },
-		u.client.UpsertAdditionalContext,
-		false)
-}
-func (u *Uploader) uploadContentVersions(output *conversion.Output) error {
-	return run(
-		u,
-		output.ContentVersions,
-		func(t *sfenterprise.ContentVersion) string { return *t.Etap_MultiObject_EtapRef__c },
-		u.client.UpsertContentVersion,
-		false)
-}
-func (u *Uploader) uploadContentDocumentLinks(output *conversion.Output) error {
-	return run(
-		u,
-		output.ContentDocumentLinks,
-		func(t *sfenterprise.ContentDocumentLink) string {
-			return (string(*t.ContentDocumentId) + string(*t.LinkedEntityId))
-		},
-		u.client.UpsertContentDocumentLink,
-		false)
-}
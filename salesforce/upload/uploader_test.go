@@ -0,0 +1,165 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Silicon-Ally/etap2sf/conv/conversion"
+	"github.com/Silicon-Ally/etap2sf/salesforce"
+	enterprise "github.com/Silicon-Ally/etap2sf/salesforce/clients/enterprise"
+	"github.com/Silicon-Ally/etap2sf/salesforce/fakesf"
+	"github.com/Silicon-Ally/etap2sf/salesforce/generated/sfenterprise"
+	"github.com/Silicon-Ally/etap2sf/salesforce/upload/state"
+)
+
+// newFakeSalesforceUploader returns an uploader that uploads through the real
+// enterprise client to a fakesf server, rather than to an org. Like the one
+// for local validation, its state isn't saved.
+func newFakeSalesforceUploader(t *testing.T) (*fakesf.Server, *Uploader, *enterprise.Client) {
+	t.Helper()
+	s := fakesf.New()
+	t.Cleanup(s.Close)
+	u, err := GetUploaderForLocalValidation()
+	if err != nil {
+		t.Fatalf("creating uploader: %v", err)
+	}
+	client, err := enterprise.New(&enterprise.Config{ConnConfig: s.ConnConfig(), APIVersion: "58.0"})
+	if err != nil {
+		t.Fatalf("creating client for fake salesforce: %v", err)
+	}
+	u.client = client
+	// The other types have placeholders, which can't be resolved until the
+	// conversion is generated.
+	u.Select(&Selection{ObjectTypes: []salesforce.ObjectType{
+		salesforce.ObjectType_Campaign,
+		salesforce.ObjectType_GeneralAccountingUnit,
+		salesforce.ObjectType_Account,
+	}})
+	return s, u, client
+}
+
+func ptr[T any](t T) *T {
+	return &t
+}
+
+func testOutput(accountKeys ...string) *conversion.Output {
+	o := &conversion.Output{
+		Campaigns: []*sfenterprise.Campaign{
+			{Etap_MultiObject_EtapRef__c: ptr("1.0.100")},
+		},
+		GeneralAccountingUnits: []*sfenterprise.Npsp__General_Accounting_Unit__c{
+			{Etap_Fund_Ref__c: ptr("General Fund")},
+		},
+	}
+	for _, key := range accountKeys {
+		o.Accounts = append(o.Accounts, &sfenterprise.Account{Etap_MultiObject_EtapRef__c: ptr(key)})
+	}
+	return o
+}
+
+func TestUpload_FakeSalesforce(t *testing.T) {
+	s, u, _ := newFakeSalesforceUploader(t)
+	// Several threads, alongside the campaigns and GAUs phases, share the
+	// client, which the race detector (run in CI) checks.
+	u.NumThreads, u.MaxErrors = 4, 4
+	keys := []string{}
+	for i := 1; i <= 8; i++ {
+		keys = append(keys, fmt.Sprintf("1.0.%d", i))
+	}
+
+	if err := u.Upload(context.Background(), testOutput(keys...)); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	for objectType, want := range map[string]int{"Campaign": 1, "npsp__General_Accounting_Unit__c": 1, "Account": len(keys)} {
+		if got := len(s.Records(objectType)); got != want {
+			t.Errorf("%s records = %d, want %d", objectType, got, want)
+		}
+	}
+	for _, r := range s.Records("Account") {
+		key := r[salesforce.MultiObjectExternalFieldKey]
+		if !u.Succeeded[key] || u.IDMap[key] != r["Id"] {
+			t.Errorf("account %s: succeeded %t with id %q, want %q", key, u.Succeeded[key], u.IDMap[key], r["Id"])
+		}
+	}
+
+	// Nothing has changed, so nothing is sent again.
+	upserts := s.Calls("upsert")
+	if err := u.Upload(context.Background(), testOutput(keys...)); err != nil {
+		t.Fatalf("second Upload: %v", err)
+	}
+	if got := s.Calls("upsert"); got != upserts {
+		t.Errorf("second upload made %d upsert calls, want none", got-upserts)
+	}
+}
+
+func TestUpload_RetriesLockedRows(t *testing.T) {
+	s, u, _ := newFakeSalesforceUploader(t)
+	s.Inject(&fakesf.Injection{
+		Operation:  "upsert",
+		ObjectType: "Account",
+		StatusCode: "UNABLE_TO_LOCK_ROW",
+		Message:    "unable to obtain exclusive access to this record",
+		Times:      1,
+	})
+
+	if err := u.Upload(context.Background(), testOutput("1.0.1")); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if !u.Succeeded["1.0.1"] || len(s.Records("Account")) != 1 {
+		t.Errorf("account wasn't uploaded after its row lock")
+	}
+	// One call for each record, and one for the retry.
+	if got := s.Calls("upsert"); got != 4 {
+		t.Errorf("upsert calls = %d, want 4", got)
+	}
+}
+
+func TestUpload_BatchExternalKeyCollision(t *testing.T) {
+	s, u, client := newFakeSalesforceUploader(t)
+	u.MaxErrors = 10
+	u.batchClients = map[salesforce.ObjectType]batchClient{salesforce.ObjectType_Account: client}
+
+	// External IDs are case insensitive, so Salesforce fails both.
+	err := u.Upload(context.Background(), testOutput("1.0.1", "1.0.3A", "1.0.3a"))
+	if err == nil {
+		t.Fatal("Upload succeeded, want the colliding accounts to fail")
+	}
+	for _, key := range []string{"1.0.3A", "1.0.3a"} {
+		if !u.Failed[key] {
+			t.Errorf("account %s didn't fail", key)
+		}
+	}
+	if !u.Succeeded["1.0.1"] {
+		t.Errorf("account 1.0.1 wasn't uploaded along with the collisions")
+	}
+	if got := len(s.Records("Account")); got != 1 {
+		t.Errorf("account records = %d, want 1", got)
+	}
+	// Campaigns and GAUs don't depend on accounts, so they're uploaded anyway.
+	if got := len(s.Records("Campaign")); got != 1 {
+		t.Errorf("campaign records = %d, want 1", got)
+	}
+}
+
+func TestUpload_StopsWhenStateCantBePersisted(t *testing.T) {
+	s, u, _ := newFakeSalesforceUploader(t)
+	store, err := state.Open(filepath.Join(t.TempDir(), uploadStateFile))
+	if err != nil {
+		t.Fatalf("opening state: %v", err)
+	}
+	// Every write fails once the store is closed.
+	store.Close()
+	u.store, u.Wetrun = store, true
+	u.Select(&Selection{ObjectTypes: []salesforce.ObjectType{salesforce.ObjectType_Account}})
+
+	err = u.Upload(context.Background(), testOutput("1.0.1", "1.0.2", "1.0.3"))
+	if err == nil || !strings.Contains(err.Error(), "persisting upload state for 1.0.") {
+		t.Fatalf("Upload = %v, want an error persisting the first account", err)
+	}
+	if got := len(s.Records("Account")); got != 1 {
+		t.Errorf("account records = %d, want the upload to stop after the first", got)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/Silicon-Ally/etap2sf/salesforce/clients/oauth"
+	"github.com/Silicon-Ally/etap2sf/salesforce/profile"
 	"github.com/Silicon-Ally/etap2sf/utils"
 )
 
@@ -29,8 +31,74 @@ func (scc *SalesforceConnectionConfig) GetPassword() string      { return scc.Pa
 func (scc *SalesforceConnectionConfig) GetSecurityToken() string { return scc.SecurityToken }
 func (scc *SalesforceConnectionConfig) GetLoginURL() string      { return scc.LoginURL }
 
+// SalesforceLogin is how to log into Salesforce, either with a username and
+// password (a *SalesforceConnectionConfig), or through a connected app (an
+// *oauth.Config).
+type SalesforceLogin interface {
+	GetUsername() string
+	GetPassword() string
+	GetSecurityToken() string
+	GetLoginURL() string
+}
+
+// GetSalesforceLogin reads how to log into the active profile's org.
+func GetSalesforceLogin() (SalesforceLogin, error) {
+	p, err := profile.Active()
+	if err != nil {
+		return nil, err
+	}
+	a := p.Auth
+	switch oauth.Flow(a.Flow) {
+	case "", "password":
+		scc, err := GetSalesforceConnectionConfig()
+		if err != nil {
+			return nil, err
+		}
+		return scc, nil
+	case oauth.Flow_JWTBearer:
+		if a.PrivateKeyFile == "" {
+			return nil, fmt.Errorf("profile %q needs a private_key_file for the %s flow", p.Name, a.Flow)
+		}
+		key, err := read(a.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		return &oauth.Config{
+			Flow:          oauth.Flow_JWTBearer,
+			ClientID:      a.ClientID,
+			Username:      a.Username,
+			PrivateKeyPEM: []byte(key),
+			LoginURL:      a.LoginURL,
+			TokenURL:      a.TokenURL,
+			Audience:      a.Audience,
+		}, nil
+	case oauth.Flow_ClientCredentials:
+		if a.ClientSecretFile == "" {
+			return nil, fmt.Errorf("profile %q needs a client_secret_file for the %s flow", p.Name, a.Flow)
+		}
+		secret, err := read(a.ClientSecretFile)
+		if err != nil {
+			return nil, err
+		}
+		return &oauth.Config{
+			Flow:         oauth.Flow_ClientCredentials,
+			ClientID:     a.ClientID,
+			ClientSecret: secret,
+			LoginURL:     a.LoginURL,
+			TokenURL:     a.TokenURL,
+		}, nil
+	default:
+		return nil, fmt.Errorf("profile %q has unknown auth flow %q, expected password, %s or %s", p.Name, a.Flow, oauth.Flow_JWTBearer, oauth.Flow_ClientCredentials)
+	}
+}
+
+// GetSalesforceConnectionConfig reads the credentials of the active profile's org.
 func GetSalesforceConnectionConfig() (*SalesforceConnectionConfig, error) {
-	fileName := "salesforce-connection-config.txt"
+	p, err := profile.Active()
+	if err != nil {
+		return nil, err
+	}
+	fileName := p.ConnectionConfig
 	all, err := read(fileName)
 	if err != nil {
 		return nil, err
@@ -10,19 +10,32 @@ import (
 	"sort"
 	"strings"
 	"unicode"
+
+	"github.com/Silicon-Ally/etap2sf/config"
 )
 
 const defaultProjectRoot = "unset"
 
+// ProjectRoot is the location that you are running this code from - the root of
+// the git repo on your local machine. It's set with `project_root` in the config
+// file, or the ETAP2SF_PROJECT_ROOT environment variable.
 func ProjectRoot() string {
-	// TODO(you) This should be the location that you are running this code from - the root of the git repo on your local machine.
-	// return "/path/to/where/you/put/this/repository"
+	if root := config.Get().ProjectRoot; root != "" {
+		return root
+	}
 	return defaultProjectRoot
 }
 
-func MemoizeOperation(fileName string, fn func() ([]byte, error)) ([]byte, error) {
+func CheckProjectRoot() error {
 	if ProjectRoot() == defaultProjectRoot {
-		return nil, fmt.Errorf("project root is not set, please set it to the root of the git repo on your local machine - see `func ProjectRoot()` in `utils/utils.go`")
+		return fmt.Errorf("project root is not set, please set `project_root` in %s (see etap2sf.example.yaml) or the %s environment variable to the root of the git repo on your local machine", config.Path(), config.ProjectRootEnvVar)
+	}
+	return nil
+}
+
+func MemoizeOperation(fileName string, fn func() ([]byte, error)) ([]byte, error) {
+	if err := CheckProjectRoot(); err != nil {
+		return nil, err
 	}
 	filePath := filepath.Join(ProjectRoot(), "data", fileName)
 	result, err := os.ReadFile(filePath)
@@ -44,6 +57,34 @@ func MemoizeOperation(fileName string, fn func() ([]byte, error)) ([]byte, error
 	return result, nil
 }
 
+// OverwriteMemoization replaces the memoized result of an operation, i.e. when
+// the data has been refreshed from its source.
+func OverwriteMemoization(fileName string, data []byte) error {
+	if err := CheckProjectRoot(); err != nil {
+		return err
+	}
+	filePath := filepath.Join(ProjectRoot(), "data", fileName)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0777); err != nil {
+		return fmt.Errorf("failed to create dir for %s: %w", fileName, err)
+	}
+	if err := os.WriteFile(filePath, data, 0777); err != nil {
+		return fmt.Errorf("failed to write %s: %w", fileName, err)
+	}
+	return nil
+}
+
+// ClearMemoization removes the memoized result of an operation, so that it's
+// recomputed the next time it's needed.
+func ClearMemoization(fileName string) error {
+	if err := CheckProjectRoot(); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(ProjectRoot(), "data", fileName)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", fileName, err)
+	}
+	return nil
+}
+
 func AlphanumericOnly(s string) string {
 	result := strings.Builder{}
 	for _, r := range s {